@@ -1,25 +1,78 @@
 // Package memcache provides a generic in-memory key-value cache.
 //
-// The capacity of a cache is the total number of keys it is allowed to hold.
+// The capacity of a cache is the total weight of the items it is allowed to
+// hold. Unless a weigher is provided via [WithWeigher], every item weighs 1 and
+// the capacity is the total number of keys.
 package memcache
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/closeable"
-	"github.com/wafer-bw/memcache/internal/data"
-	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
-	"github.com/wafer-bw/memcache/internal/eviction/allkeyslru"
-	"github.com/wafer-bw/memcache/internal/eviction/noevict"
-	"github.com/wafer-bw/memcache/internal/eviction/volatilelru"
+	"github.com/wafer-bw/memcache/internal/dispatch"
 	"github.com/wafer-bw/memcache/internal/expire"
+	"github.com/wafer-bw/memcache/internal/hashing"
 	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/sharded"
+	"github.com/wafer-bw/memcache/internal/singleflight"
+	"github.com/wafer-bw/memcache/internal/workpool"
 )
 
 var (
-	ErrInvalidInterval = errors.New("provided interval must be greater than 0")
+	ErrInvalidPolicy        = errors.New("provided policy must have a NewStore function")
+	ErrInvalidInterval      = errors.New("provided interval must be greater than 0")
+	ErrInvalidSampleSize    = errors.New("provided sample size must be greater than 0")
+	ErrInvalidExpirePercent = errors.New("provided expire percent must be greater than 0 and less than or equal to 1")
+	ErrInvalidWeigher       = errors.New("provided weigher must not be nil")
+	ErrInvalidMaxWeight     = errors.New("provided max weight must be greater than 0")
+	ErrInvalidShards        = errors.New("provided shards must be greater than 0")
+	ErrInvalidHasher        = errors.New("provided hasher must not be nil")
+	ErrInvalidCallback      = errors.New("provided callback must not be nil")
+	ErrInvalidBufferSize    = errors.New("provided buffer size must be greater than 0")
+	ErrInvalidLoader        = errors.New("provided loader must not be nil")
+	ErrInvalidRefresher     = errors.New("provided refresher must not be nil")
+	ErrInvalidWorkers       = errors.New("provided workers must be greater than 0")
+	ErrInvalidBeta          = errors.New("provided beta must be greater than 0")
+	ErrInvalidClock         = errors.New("provided clock must not be nil")
+	ErrInvalidRandom        = errors.New("provided random source must not be nil")
+	ErrInvalidPath          = errors.New("provided path must not be empty")
+	ErrInvalidSnapshot      = errors.New("snapshot is invalid")
+	ErrInvalidCodec         = errors.New("provided codec must not be nil")
+	ErrInvalidFsyncPolicy   = errors.New("provided fsync policy must be FsyncAlways, FsyncEverySecond or FsyncNever")
+	ErrInvalidRewriteSize   = errors.New("provided rewrite size must be greater than 0")
+	ErrInvalidJournal       = errors.New("journal is invalid")
+)
+
+// Clock tells the time to a [Cache], its store and its expirer, see
+// [WithClock].
+type Clock = data.Clock
+
+// Ticker delivers the ticks of a [Clock].
+type Ticker = data.Ticker
+
+// Reason describes why a value was removed from a [Cache]. It is passed to the
+// callbacks provided via [WithOnEvict], [WithOnExpire] and [WithOnDelete].
+type Reason = data.Reason
+
+const (
+	// ReasonEvicted values were evicted by the policy of the cache in order to
+	// remain within its capacity.
+	ReasonEvicted = data.Evicted
+	// ReasonExpired values had expired when they were deleted, whether
+	// passively, actively, or via [Cache.Delete] or [Cache.Flush].
+	ReasonExpired = data.Expired
+	// ReasonDeleted values were deleted via [Cache.Delete] or [Cache.Flush]
+	// before they expired.
+	ReasonDeleted = data.Deleted
 )
 
 type InvalidCapacityError struct {
@@ -32,7 +85,7 @@ func (e InvalidCapacityError) Error() string {
 	return fmt.Sprintf("capacity %d must be greater than %d for %s caches", e.Capacity, e.Minimum, e.Policy)
 }
 
-// Option functions can be passed to open functions like [OpenNoEvictionCache]
+// Option functions can be passed to open functions like [Open]
 // to control optional properties of the returned [Cache].
 type Option[K comparable, V any] func(*Cache[K, V]) error
 
@@ -53,18 +106,54 @@ func WithPassiveExpiration[K comparable, V any]() Option[K, V] {
 //
 // This comes with a minor performance cost as every tick requires a read lock
 // to collect all expired keys followed by a write lock to delete them.
+//
+// If the store of the cache maintains an index of keys ordered by expiry, by
+// implementing an ExpiredKeys(now time.Time) []K method, only the expired keys
+// are visited each tick instead of every key in the cache.
 func WithActiveExpiration[K comparable, V any](interval time.Duration) Option[K, V] {
 	return func(c *Cache[K, V]) error {
 		if interval <= 0 {
 			return ErrInvalidInterval
 		}
-		c.expirer = expire.AllKeys[K, V]{}
+		c.expirer = nil // chosen once the store is known.
+		c.activeExpirationInterval = interval
+		return nil
+	}
+}
+
+// WithSampledActiveExpiration enables the active deletion of expired keys at
+// the provided interval by sampling random keys instead of scanning all of them.
+//
+// Every tick, sampleSize random keys are checked and the expired ones deleted.
+// If more than expirePercent (a fraction between 0 and 1) of the sampled keys
+// were expired, sampling is repeated. The number of rounds and the time spent
+// per tick are bounded so that a mostly expired cache cannot monopolize the
+// expirer.
+//
+// This is preferable to [WithActiveExpiration] for large caches because each
+// tick only takes short-lived locks rather than copying every key.
+func WithSampledActiveExpiration[K comparable, V any](interval time.Duration, sampleSize int, expirePercent float32) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if interval <= 0 {
+			return ErrInvalidInterval
+		}
+		if sampleSize <= 0 {
+			return ErrInvalidSampleSize
+		}
+		if expirePercent <= 0 || expirePercent > 1 {
+			return ErrInvalidExpirePercent
+		}
+		c.expirer = &expire.RandomSample[K, V]{
+			SampleSize:    sampleSize,
+			ExpirePercent: expirePercent,
+		}
 		c.activeExpirationInterval = interval
 		return nil
 	}
 }
 
-// WithCapacity sets the maximum number of keys that the cache can hold.
+// WithCapacity sets the maximum number of keys that the cache can hold, or
+// their maximum total weight if a weigher is provided via [WithWeigher].
 //
 // This option is made available to set the capacity of policies that do not
 // need or use a capacity by default.
@@ -77,106 +166,357 @@ func WithCapacity[K comparable, V any](capacity int) Option[K, V] {
 	}
 }
 
-// Cache is a generic in-memory key-value cache.
-type Cache[K comparable, V any] struct {
-	closer                   ports.Closer
-	store                    ports.Storer[K, V]
-	expirer                  ports.Expirer[K, V]
-	capacity                 int
-	passiveExpiration        bool
-	activeExpirationInterval time.Duration
+// WithWeigher sets the function used to weigh each value set in the cache,
+// such as its size in bytes. Values weighing less than 1 are counted as
+// weighing 1.
+//
+// Stores evict as many keys as needed for the total weight of the cache to
+// remain within its capacity, which should be set via [WithMaxWeight]. Values
+// weighing more than the capacity, or the capacity of a shard when opened with
+// [WithShards], are never stored.
+func WithWeigher[K comparable, V any](weigher func(key K, value V) int64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if weigher == nil {
+			return ErrInvalidWeigher
+		}
+		c.weigher = weigher
+		return nil
+	}
 }
 
-// OpenNoEvictionCache opens a new in-memory key-value cache.
+// WithMaxWeight sets the maximum total weight of the values that the cache can
+// hold as weighed by [WithWeigher], replacing any capacity provided otherwise.
+func WithMaxWeight[K comparable, V any](maxWeight int64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if maxWeight <= 0 {
+			return ErrInvalidMaxWeight
+		}
+		c.capacity = int(min(maxWeight, math.MaxInt))
+		return nil
+	}
+}
+
+// WithShards splits the cache into n independent stores, each holding an even
+// share of its capacity and guarded by its own locks, to reduce lock contention
+// under parallel use. Keys are assigned to a shard by their hash, see
+// [WithHasher].
 //
-// This policy will ignore any additional keys that would cause the cache to
-// breach its capacity.
+// Eviction happens per shard, so the key a policy evicts is only chosen from
+// the keys in the same shard as the key being set.
+func WithShards[K comparable, V any](n int) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if n <= 0 {
+			return ErrInvalidShards
+		}
+		c.shards = n
+		return nil
+	}
+}
+
+// WithHasher sets the function used to hash keys into shards when the cache is
+// opened with [WithShards].
 //
-// The capacity for this policy must be 0 (default) or set to a greater value
-// via [WithCapacity].
-func OpenNoEvictionCache[K comparable, V any](options ...Option[K, V]) (*Cache[K, V], error) {
-	c := &Cache[K, V]{
-		closer:   closeable.New(),
-		capacity: noevict.DefaultCapacity,
+// Strings and integers are hashed efficiently by default. Keys of any other
+// type are hashed via their %#v representation which is slow, so a dedicated
+// hasher should be provided for them.
+func WithHasher[K comparable, V any](hasher func(key K) uint64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if hasher == nil {
+			return ErrInvalidHasher
+		}
+		c.hasher = hasher
+		return nil
 	}
+}
 
-	for _, option := range options {
-		if option == nil {
-			continue
+// WithOnEvict sets a callback which is called with each key & value evicted by
+// the policy of the cache in order to remain within its capacity.
+//
+// Callbacks are called after the cache has released its locks, so they may
+// safely use the cache, but by default they run on the goroutine that caused
+// the removal and delay it until they return, see [WithAsyncCallbacks].
+func WithOnEvict[K comparable, V any](callback func(key K, value V, reason Reason)) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if callback == nil {
+			return ErrInvalidCallback
 		}
-		if err := option(c); err != nil {
-			return nil, err
+		c.onEvict = callback
+		return nil
+	}
+}
+
+// WithOnExpire sets a callback which is called with each expired key & value
+// deleted from the cache. Expired keys are only deleted when found via
+// [WithPassiveExpiration] or [WithActiveExpiration], or when deleted via
+// [Cache.Delete] or [Cache.Flush].
+//
+// See [WithOnEvict] for when callbacks are called.
+func WithOnExpire[K comparable, V any](callback func(key K, value V, reason Reason)) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if callback == nil {
+			return ErrInvalidCallback
 		}
+		c.onExpire = callback
+		return nil
 	}
+}
 
-	if c.capacity < noevict.MinimumCapacity {
-		return nil, InvalidCapacityError{
-			Policy:   noevict.PolicyName,
-			Capacity: c.capacity,
-			Minimum:  noevict.MinimumCapacity,
+// WithOnDelete sets a callback which is called with each unexpired key & value
+// deleted via [Cache.Delete] or [Cache.Flush], including values deleted by
+// setting a value too heavy to be stored.
+//
+// See [WithOnEvict] for when callbacks are called.
+func WithOnDelete[K comparable, V any](callback func(key K, value V, reason Reason)) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if callback == nil {
+			return ErrInvalidCallback
 		}
+		c.onDelete = callback
+		return nil
 	}
+}
 
-	c.store = noevict.New[K, V](c.capacity)
+// WithAsyncCallbacks runs the callbacks provided via [WithOnEvict],
+// [WithOnExpire] and [WithOnDelete] on a background goroutine, in the order
+// keys were removed, so that slow callbacks do not delay cache operations.
+//
+// Up to bufferSize removals are queued. When the queue is full the callback is
+// run by the goroutine which removed the key instead, bounding memory use.
+// [Cache.Close] waits for all queued callbacks to return, after which
+// callbacks are run synchronously again.
+func WithAsyncCallbacks[K comparable, V any](bufferSize int) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if bufferSize <= 0 {
+			return ErrInvalidBufferSize
+		}
+		c.callbackBufferSize = bufferSize
+		return nil
+	}
+}
 
-	if c.activeExpirationInterval > 0 {
-		go c.runActiveExpirer(c.activeExpirationInterval)
+// WithRefresh enables serving stale values set via [Cache.SetExStale] while
+// they are refreshed in the background using refresher.
+//
+// When [Cache.Get] finds a value which is stale but not yet expired, it returns
+// the stale value and schedules a single refresh of its key. refresher is
+// passed the stale value and returns the fresh value along with its soft & hard
+// ttl, where a ttl of 0 or less disables becoming stale or expiring
+// respectively. If refresher returns an error the stale value is kept, and
+// refreshed again the next time it is read. Refreshed values are discarded if
+// the key was set, deleted or expired while it was being refreshed.
+//
+// At most workers refreshes run at a time. Refreshes scheduled while every
+// worker is busy are skipped, to be retried on a later read. [Cache.Close]
+// cancels the ctx passed to refresher and waits for running refreshes.
+//
+// If the cache was opened with [WithActiveExpiration] or
+// [WithSampledActiveExpiration], every tick also refreshes stale keys ahead of
+// them being read, which requires copying every item in the cache.
+func WithRefresh[K comparable, V any](refresher func(ctx context.Context, key K, stale V) (V, time.Duration, time.Duration, error), workers int) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if refresher == nil {
+			return ErrInvalidRefresher
+		}
+		if workers <= 0 {
+			return ErrInvalidWorkers
+		}
+		c.refresher = refresher
+		c.refreshWorkers = workers
+		return nil
 	}
+}
 
-	return c, nil
+// WithEarlyExpiration enables probabilistic early expiration of values loaded
+// via [Cache.GetOrLoad], using the XFetch algorithm to prevent stampedes of
+// callers all reloading a popular key the moment it expires.
+//
+// [Cache.Get] randomly reports values as missing ahead of their expiry, with a
+// probability that rises as expiry approaches and with how long the value took
+// to load, so that usually a single caller reloads it before it expires. beta
+// scales how early values may be reported missing; 1 is a good default, with
+// values above 1 favoring earlier reloads. See [WithRandom] to make early
+// expiration deterministic in tests.
+func WithEarlyExpiration[K comparable, V any](beta float64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if beta <= 0 {
+			return ErrInvalidBeta
+		}
+		c.beta = beta
+		return nil
+	}
 }
 
-// OpenAllKeysLRUCache opens a new in-memory key-value cache.
+// WithClock sets the clock used to tell the time when setting, reading and
+// expiring keys, and to tick active expiration. Defaults to the system clock.
 //
-// This policy evicts the least recently used key when the cache would breach
-// its capacity.
+// This is intended for tests, which can use the fake clock provided by
+// [github.com/wafer-bw/memcache/memcachetest.NewClock] to expire keys without
+// sleeping.
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if clock == nil {
+			return ErrInvalidClock
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// WithRandom sets the source of the random numbers in [0, 1) used by
+// [WithEarlyExpiration] to decide whether to report values missing early.
+// Defaults to [math/rand.Float64]. random must be safe for concurrent use.
 //
-// The capacity for this policy must be greater than 0.
-func OpenAllKeysLRUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
-	c := &Cache[K, V]{
-		closer:   closeable.New(),
-		capacity: capacity,
-		expirer:  expire.AllKeys[K, V]{},
+// This is intended for tests, which can use a fixed random number to make
+// early expiration deterministic.
+func WithRandom[K comparable, V any](random func() float64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if random == nil {
+			return ErrInvalidRandom
+		}
+		c.random = random
+		return nil
 	}
+}
 
-	for _, option := range options {
-		if option == nil {
-			continue
+// WithPeriodicSnapshot enables writing a snapshot of the cache to the file at
+// path every interval, and once more when the cache is closed, see
+// [Cache.Snapshot]. If a snapshot exists at path when the cache is opened, it
+// is restored, so that restarted processes start with a warm cache.
+//
+// Snapshots are written to a temporary file in the same directory as path
+// which is then renamed to path, so a crash while writing never leaves a
+// partially written snapshot behind. Errors writing periodic snapshots are
+// ignored, leaving the previous snapshot in place.
+func WithPeriodicSnapshot[K comparable, V any](path string, interval time.Duration) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if path == "" {
+			return ErrInvalidPath
 		}
-		if err := option(c); err != nil {
-			return nil, err
+		if interval <= 0 {
+			return ErrInvalidInterval
 		}
+		c.snapshotPath = path
+		c.snapshotInterval = interval
+		return nil
 	}
+}
 
-	if c.capacity < allkeyslru.MinimumCapacity {
-		return nil, InvalidCapacityError{
-			Policy:   allkeyslru.PolicyName,
-			Capacity: c.capacity,
-			Minimum:  allkeyslru.MinimumCapacity,
+// WithCodec sets the codec used to encode keys and values when snapshotting the
+// cache, see [Cache.Snapshot]. Defaults to [codec.Gob].
+//
+// Snapshots can only be restored by caches using a codec of the same name.
+func WithCodec[K comparable, V any](c codec.Codec[K, V]) Option[K, V] {
+	return func(cache *Cache[K, V]) error {
+		if c == nil {
+			return ErrInvalidCodec
 		}
+		cache.codec = c
+		return nil
 	}
+}
 
-	c.store = allkeyslru.New[K, V](c.capacity)
-
-	if c.activeExpirationInterval > 0 {
-		go c.runActiveExpirer(c.activeExpirationInterval)
+// WithJournal enables recording every write to the cache in an append-only
+// journal at path, which is replayed when the cache is opened so that writes
+// survive the process restarting. Writes are synced to disk according to
+// fsync. Once the journal has grown to at least rewriteSize bytes, and to at
+// least twice its size after it was last rewritten, it is rewritten in the
+// background from the current items of the cache.
+//
+// Sets, deletes and flushes are recorded, but expirations and evictions are
+// not, since replaying the writes which led to them repeats them. Each write
+// records the item its keys hold once it has been applied, so that replaying
+// the journal restores the last write to each key even when concurrent writes
+// to it were recorded in a different order than they were applied. A journal whose last record was only partially
+// written, for example because the machine crashed, is truncated to its last
+// complete record when replayed, whereas opening a journal which is otherwise
+// corrupt returns [ErrInvalidJournal] and leaves it as is. Keys and values are
+// encoded using the codec of the cache, see [WithCodec], and writes whose key
+// or value cannot be encoded are not recorded.
+//
+// If writing a record fails, the journal is truncated back to its last
+// complete record. If that fails too, no further writes are recorded.
+//
+// If the cache was also opened with [WithPeriodicSnapshot], the snapshot is
+// restored before the journal is replayed.
+func WithJournal[K comparable, V any](path string, fsync FsyncPolicy, rewriteSize int64) Option[K, V] {
+	return func(c *Cache[K, V]) error {
+		if path == "" {
+			return ErrInvalidPath
+		}
+		if fsync < FsyncAlways || fsync > FsyncNever {
+			return ErrInvalidFsyncPolicy
+		}
+		if rewriteSize <= 0 {
+			return ErrInvalidRewriteSize
+		}
+		c.journalPath = path
+		c.journalFsync = fsync
+		c.journalRewriteSize = rewriteSize
+		return nil
 	}
+}
 
-	return c, nil
+// Cache is a generic in-memory key-value cache.
+type Cache[K comparable, V any] struct {
+	clock                    Clock
+	stats                    counters
+	random                   func() float64
+	beta                     float64
+	closer                   ports.Closer
+	loads                    singleflight.Group[K, V]
+	refresher                func(ctx context.Context, key K, stale V) (V, time.Duration, time.Duration, error)
+	refreshers               *workpool.Pool
+	refreshWorkers           int
+	refreshingMu             sync.Mutex
+	refreshing               map[K]struct{}
+	dispatcher               *dispatch.Dispatcher
+	codec                    codec.Codec[K, V]
+	snapshotPath             string
+	snapshotInterval         time.Duration
+	snapshotting             sync.WaitGroup
+	journal                  *journal[K, V]
+	journalPath              string
+	journalFsync             FsyncPolicy
+	journalRewriteSize       int64
+	journalSyncing           sync.WaitGroup
+	onEvict                  func(key K, value V, reason Reason)
+	onExpire                 func(key K, value V, reason Reason)
+	onDelete                 func(key K, value V, reason Reason)
+	callbackBufferSize       int
+	store                    Store[K, V]
+	swapper                  Swapper[K, V]
+	swapping                 sync.Mutex
+	versions                 atomic.Uint64
+	expirer                  ports.Expirer[K, V]
+	weigher                  func(key K, value V) int64
+	hasher                   func(key K) uint64
+	policyName               string
+	capacity                 int
+	shards                   int
+	passiveExpiration        bool
+	activeExpirationInterval time.Duration
 }
 
-// OpenVolatileLRUCache opens a new in-memory key-value cache.
+// Open opens a new in-memory key-value cache backed by a store created by the
+// provided policy.
 //
-// This policy evicts the least recently used key with a ttl when the cache
-// would breach its capacity. If no keys have a ttl, then the least recently
-// used key is evicted.
-//
-// The capacity for this policy must be greater than 0.
-func OpenVolatileLRUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+// The capacity of the cache defaults to the policy's default capacity and can
+// be changed via [WithCapacity]. It must not be less than the policy's minimum
+// capacity.
+func Open[K comparable, V any](policy Policy[K, V], options ...Option[K, V]) (*Cache[K, V], error) {
+	if policy.NewStore == nil {
+		return nil, ErrInvalidPolicy
+	}
+
 	c := &Cache[K, V]{
-		closer:   closeable.New(),
-		capacity: capacity,
-		expirer:  expire.AllKeys[K, V]{},
+		closer:     closeable.New(),
+		clock:      data.SystemClock{},
+		random:     rand.Float64,
+		codec:      codec.Gob[K, V](),
+		policyName: policy.Name,
+		capacity:   policy.DefaultCapacity,
+		shards:     1,
+		hasher:     hashing.Default[K](),
 	}
 
 	for _, option := range options {
@@ -188,23 +528,110 @@ func OpenVolatileLRUCache[K comparable, V any](capacity int, options ...Option[K
 		}
 	}
 
-	if c.capacity < volatilelru.MinimumCapacity {
+	if c.capacity < policy.MinimumCapacity {
+		return nil, InvalidCapacityError{
+			Policy:   policy.Name,
+			Capacity: c.capacity,
+			Minimum:  policy.MinimumCapacity,
+		}
+	}
+
+	if c.shards > 1 && c.capacity > 0 && c.capacity/c.shards < max(policy.MinimumCapacity, 1) {
 		return nil, InvalidCapacityError{
-			Policy:   volatilelru.PolicyName,
+			Policy:   policy.Name,
 			Capacity: c.capacity,
-			Minimum:  volatilelru.MinimumCapacity,
+			Minimum:  max(policy.MinimumCapacity, 1) * c.shards,
 		}
 	}
 
-	c.store = volatilelru.New[K, V](c.capacity)
+	c.store = c.newStore(policy)
+
+	if c.snapshotPath != "" {
+		// restored before starting any goroutines so that none are leaked if
+		// restoring fails.
+		if err := c.restoreFile(c.snapshotPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.journalPath != "" {
+		journal, err := c.openJournal(c.journalPath)
+		if err != nil {
+			return nil, err
+		}
+		c.journal = journal
+	}
+
+	if c.callbackBufferSize > 0 && c.hasCallbacks() {
+		c.dispatcher = dispatch.New(c.callbackBufferSize)
+	}
+
+	if c.refresher != nil {
+		c.refreshers = workpool.New(c.refreshWorkers)
+		c.refreshing = map[K]struct{}{}
+	}
+
+	switch expirer := c.expirer.(type) {
+	case nil:
+		c.expirer = expire.AllKeys[K, V]{}
+		if indexer, ok := c.store.(expire.Indexer[K]); ok {
+			c.expirer = expire.Indexed[K, V]{Indexer: indexer, Clock: c.clock}
+		}
+	case *expire.RandomSample[K, V]:
+		expirer.Clock = c.clock
+	}
 
 	if c.activeExpirationInterval > 0 {
-		go c.runActiveExpirer(c.activeExpirationInterval)
+		// the ticker is created before returning so that advancing a fake
+		// clock straight after opening the cache ticks it.
+		go c.runActiveExpirer(c.clock.NewTicker(c.activeExpirationInterval))
+	}
+
+	if c.snapshotPath != "" {
+		c.snapshotting.Add(1)
+		go c.runPeriodicSnapshot(c.clock.NewTicker(c.snapshotInterval))
+	}
+
+	if c.journal != nil && c.journalFsync == FsyncEverySecond {
+		c.journalSyncing.Add(1)
+		go c.runJournalSync(c.clock.NewTicker(time.Second))
 	}
 
 	return c, nil
 }
 
+// OpenNoEvictionCache opens a new in-memory key-value cache.
+//
+// This policy will ignore any additional keys that would cause the cache to
+// breach its capacity.
+//
+// The capacity for this policy must be 0 (default) or set to a greater value
+// via [WithCapacity].
+func OpenNoEvictionCache[K comparable, V any](options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(NoEvictionPolicy[K, V](), options...)
+}
+
+// OpenAllKeysLRUCache opens a new in-memory key-value cache.
+//
+// This policy evicts the least recently used key when the cache would breach
+// its capacity.
+//
+// The capacity for this policy must be greater than 0.
+func OpenAllKeysLRUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(AllKeysLRUPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
+// OpenVolatileLRUCache opens a new in-memory key-value cache.
+//
+// This policy evicts the least recently used key with a ttl when the cache
+// would breach its capacity. If no keys have a ttl, then the least recently
+// used key is evicted.
+//
+// The capacity for this policy must be greater than 0.
+func OpenVolatileLRUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(VolatileLRUPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
 // OpenAllKeysLFUCache opens a new in-memory key-value cache.
 //
 // This policy evicts the least frequently used key when the cache would breach
@@ -212,50 +639,142 @@ func OpenVolatileLRUCache[K comparable, V any](capacity int, options ...Option[K
 //
 // The capacity for this policy must be greater than 0.
 func OpenAllKeysLFUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
-	c := &Cache[K, V]{
-		closer:   closeable.New(),
-		capacity: capacity,
-		expirer:  expire.AllKeys[K, V]{},
-	}
+	return Open(AllKeysLFUPolicy[K, V](), withCapacity(capacity, options)...)
+}
 
-	for _, option := range options {
-		if option == nil {
-			continue
-		}
-		if err := option(c); err != nil {
-			return nil, err
-		}
-	}
+// OpenVolatileLFUCache opens a new in-memory key-value cache.
+//
+// This policy evicts the least frequently used key with a ttl when the cache
+// would breach its capacity. If no keys have a ttl, then the least frequently
+// used key is evicted.
+//
+// The capacity for this policy must be greater than 0.
+func OpenVolatileLFUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(VolatileLFUPolicy[K, V](), withCapacity(capacity, options)...)
+}
 
-	if c.capacity < allkeyslfu.MinimumCapacity {
-		return nil, InvalidCapacityError{
-			Policy:   allkeyslfu.PolicyName,
-			Capacity: c.capacity,
-			Minimum:  allkeyslfu.MinimumCapacity,
-		}
-	}
+// OpenVolatileTTLCache opens a new in-memory key-value cache.
+//
+// This policy evicts the key with a ttl which is nearest to expiring when the
+// cache would breach its capacity. If no keys have a ttl, then a random key is
+// evicted. Because keys are indexed by expiry, [WithActiveExpiration] only
+// visits expired keys.
+//
+// The capacity for this policy must be greater than 0.
+func OpenVolatileTTLCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(VolatileTTLPolicy[K, V](), withCapacity(capacity, options)...)
+}
 
-	c.store = allkeyslfu.New[K, V](c.capacity)
+// OpenAllKeysRandomCache opens a new in-memory key-value cache.
+//
+// This policy evicts a random key when the cache would breach its capacity.
+// Because reads do not need to track recency or frequency, [Cache.Get] only
+// acquires a read lock.
+//
+// The capacity for this policy must be greater than 0.
+func OpenAllKeysRandomCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(AllKeysRandomPolicy[K, V](), withCapacity(capacity, options)...)
+}
 
-	if c.activeExpirationInterval > 0 {
-		go c.runActiveExpirer(c.activeExpirationInterval)
-	}
+// OpenVolatileRandomCache opens a new in-memory key-value cache.
+//
+// This policy evicts a random key with a ttl when the cache would breach its
+// capacity. If no keys have a ttl, then a random key is evicted. Because reads
+// do not need to track recency or frequency, [Cache.Get] only acquires a read
+// lock.
+//
+// The capacity for this policy must be greater than 0.
+func OpenVolatileRandomCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(VolatileRandomPolicy[K, V](), withCapacity(capacity, options)...)
+}
 
-	return c, nil
+// OpenTinyLFUCache opens a new in-memory key-value cache.
+//
+// This policy evicts keys using W-TinyLFU. New keys enter a small lru admission
+// window and are only admitted to the main segmented lru if they are estimated
+// to be accessed more frequently than the key they would replace. Access
+// frequencies are estimated using a count-min sketch which is periodically aged,
+// keeping hit ratios high under workloads with scans of keys accessed once.
+//
+// The capacity for this policy must be greater than 0.
+func OpenTinyLFUCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(TinyLFUPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
+// OpenARCCache opens a new in-memory key-value cache.
+//
+// This policy evicts keys using an Adaptive Replacement Cache. Keys seen once
+// and keys seen more than once are kept in separate lru lists, and recently
+// evicted keys from each are remembered so that the balance between the two
+// lists self-tunes to the workload rather than being chosen up front.
+//
+// The capacity for this policy must be greater than 0.
+func OpenARCCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(ARCPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
+// OpenSIEVECache opens a new in-memory key-value cache.
+//
+// This policy evicts keys using SIEVE when the cache would breach its
+// capacity. Gets only acquire a read lock on the underlying store, making it
+// well suited to read heavy workloads.
+//
+// The capacity for this policy must be greater than 0.
+func OpenSIEVECache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(SIEVEPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
+// OpenS3FIFOCache opens a new in-memory key-value cache.
+//
+// This policy evicts keys using S3-FIFO when the cache would breach its
+// capacity. Gets only acquire a read lock on the underlying store, making it
+// well suited to read heavy workloads.
+//
+// The capacity for this policy must be greater than 0.
+func OpenS3FIFOCache[K comparable, V any](capacity int, options ...Option[K, V]) (*Cache[K, V], error) {
+	return Open(S3FIFOPolicy[K, V](), withCapacity(capacity, options)...)
+}
+
+// withCapacity prepends a [WithCapacity] option to options so that capacities
+// passed positionally to open functions can still be overridden by options.
+func withCapacity[K comparable, V any](capacity int, options []Option[K, V]) []Option[K, V] {
+	return append([]Option[K, V]{WithCapacity[K, V](capacity)}, options...)
 }
 
 // Set non-expiring key to value in the cache.
+//
+// If value weighs more than the capacity of the cache it is not stored and any
+// existing value of key is deleted.
 func (c *Cache[K, V]) Set(key K, value V) {
-	c.store.Add(key, data.Item[K, V]{
+	c.add(key, data.Item[K, V]{
 		Value: value,
 	})
 }
 
 // SetEx key that will expire after ttl to value in the cache.
+//
+// If value weighs more than the capacity of the cache it is not stored and any
+// existing value of key is deleted.
 func (c *Cache[K, V]) SetEx(key K, value V, ttl time.Duration) {
-	expireAt := time.Now().Add(ttl)
-	c.store.Add(key, data.Item[K, V]{
+	expireAt := c.clock.Now().Add(ttl)
+	c.add(key, data.Item[K, V]{
+		Value:    value,
+		ExpireAt: &expireAt,
+	})
+}
+
+// SetExStale key to value in the cache, which becomes stale after softTTL and
+// expires after hardTTL.
+//
+// If the cache was opened with [WithRefresh], stale values continue to be
+// returned by [Cache.Get] while they are refreshed in the background.
+// Otherwise, stale values are treated the same as fresh ones until they expire.
+func (c *Cache[K, V]) SetExStale(key K, value V, softTTL, hardTTL time.Duration) {
+	now := c.clock.Now()
+	staleAt, expireAt := now.Add(softTTL), now.Add(hardTTL)
+	c.add(key, data.Item[K, V]{
 		Value:    value,
+		StaleAt:  &staleAt,
 		ExpireAt: &expireAt,
 	})
 }
@@ -265,32 +784,136 @@ func (c *Cache[K, V]) SetEx(key K, value V, ttl time.Duration) {
 //
 // If the cache was opened with [WithPassiveExpiration] and the requested key
 // is expired, it will be deleted from the cache and false will be returned.
+//
+// If the cache was opened with [WithRefresh] and the requested key is stale,
+// its stale value is returned and a refresh of it is scheduled.
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	item, ok := c.store.Get(key)
-	if !ok {
-		return *new(V), false
+	item, ok := c.get(key)
+	return item.Value, ok
+}
+
+// GetWithVersion returns the value associated with the provided key along with
+// its version, or false if it does not exist. It otherwise behaves the same as
+// [Cache.Get].
+//
+// Every value set in the cache is given a new version, so passing the version
+// to [Cache.CompareAndSwap] or [Cache.CompareAndDelete] only changes the value
+// if it has not been set since. Versions are not preserved by snapshots or
+// journals, restored values are given new versions.
+func (c *Cache[K, V]) GetWithVersion(key K) (V, uint64, bool) {
+	item, ok := c.get(key)
+	return item.Value, item.Version, ok
+}
+
+// GetOrLoad returns the value associated with the provided key if it exists,
+// otherwise it is loaded by calling loader and set in the cache to expire after
+// the returned ttl. A ttl of 0 or less sets the value without an expiry.
+//
+// Concurrent calls for a key which is being loaded wait for and share the
+// result of the same call to loader rather than calling it again, including
+// any error it returns. Errors are returned as is and are not cached. If loader
+// panics, every call waiting for it panics too.
+//
+// If ctx is done before loading is finished its error is returned. The ctx
+// passed to loader is only cancelled once the ctx of every call waiting for it
+// is done, so loading continues for as long as anyone is waiting.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(ctx context.Context, key K) (V, time.Duration, error)) (V, error) {
+	if loader == nil {
+		return *new(V), ErrInvalidLoader
 	}
 
-	if item.IsExpired() {
-		if c.passiveExpiration {
-			c.store.Remove(key)
-		}
-		return *new(V), false
+	if value, ok := c.Get(key); ok {
+		return value, nil
 	}
 
-	return item.Value, ok
+	if err := ctx.Err(); err != nil {
+		return *new(V), err
+	}
+
+	return c.loads.Do(ctx, key, func(ctx context.Context) (V, error) {
+		start := c.clock.Now()
+		value, ttl, err := loader(ctx, key)
+		if err != nil {
+			c.stats.loadFailures.Add(1)
+			return value, err
+		}
+		c.stats.loadSuccesses.Add(1)
+
+		now := c.clock.Now()
+		item := data.Item[K, V]{Value: value, LoadDuration: now.Sub(start)}
+		if ttl > 0 {
+			expireAt := now.Add(ttl)
+			item.ExpireAt = &expireAt
+		}
+		c.add(key, item)
+
+		return value, nil
+	})
+}
+
+// Contains reports whether key exists and has not expired. Unlike [Cache.Get]
+// it does not count as a hit or miss, nor as an access of key when choosing
+// which keys to evict, unless the cache was opened with a custom policy whose
+// store does not support it, see [Store].
+func (c *Cache[K, V]) Contains(key K) bool {
+	item, ok := c.peek(key)
+	return ok && !item.IsExpiredAt(c.clock.Now())
 }
 
 // TTL for the provided key if it exists, or false if it does not. If the key is
 // will not expire then (nil, true) will be returned.
 func (c *Cache[K, V]) TTL(key K) (*time.Duration, bool) {
 	item, ok := c.store.Get(key)
-	return item.TTL(), ok
+	return item.TTLAt(c.clock.Now()), ok
+}
+
+// CompareAndSwap sets non-expiring key to value only if key exists and still
+// has version, as returned by [Cache.GetWithVersion], reporting whether it did.
+//
+// The comparison and swap are atomic with respect to all other writes to the
+// cache, unless it was opened with a custom policy whose store does not
+// implement compare-and-swap, see [Store].
+//
+// If value weighs more than the capacity of the cache it is not stored, and key
+// is deleted if it still has version.
+func (c *Cache[K, V]) CompareAndSwap(key K, version uint64, value V) bool {
+	return c.swap(key, version, data.Item[K, V]{
+		Value: value,
+	})
+}
+
+// CompareAndSwapEx sets key that will expire after ttl to value only if key
+// exists and still has version, as returned by [Cache.GetWithVersion],
+// reporting whether it did. See [Cache.CompareAndSwap].
+func (c *Cache[K, V]) CompareAndSwapEx(key K, version uint64, value V, ttl time.Duration) bool {
+	expireAt := c.clock.Now().Add(ttl)
+	return c.swap(key, version, data.Item[K, V]{
+		Value:    value,
+		ExpireAt: &expireAt,
+	})
+}
+
+// CompareAndDelete deletes key only if it exists and still has version, as
+// returned by [Cache.GetWithVersion], reporting whether it did. See
+// [Cache.CompareAndSwap].
+func (c *Cache[K, V]) CompareAndDelete(key K, version uint64) bool {
+	if !c.compareAndRemove(key, version) {
+		return false
+	}
+
+	c.stats.deletes.Add(1)
+	c.journal.record(key)
+	return true
 }
 
 // Delete provided keys from the cache.
 func (c *Cache[K, V]) Delete(keys ...K) {
-	c.store.Remove(keys...)
+	for _, key := range keys {
+		if c.remove(key) {
+			c.stats.deletes.Add(1)
+		}
+	}
+	c.journal.record(keys...)
 }
 
 // Size returns the number of items currently in the cache.
@@ -298,6 +921,23 @@ func (c *Cache[K, V]) Size() int {
 	return c.store.Len()
 }
 
+// Weight returns the total weight of the items currently in the cache, which is
+// the number of items unless a weigher was provided via [WithWeigher].
+func (c *Cache[K, V]) Weight() int64 {
+	return c.store.Weight()
+}
+
+// Capacity returns the capacity of the cache, which is the maximum total weight
+// of the items it can hold. A capacity of 0 is unlimited.
+func (c *Cache[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// PolicyName returns the name of the policy the cache was opened with.
+func (c *Cache[K, V]) PolicyName() string {
+	return c.policyName
+}
+
 // RandomKey returns a random key from the cache, or false if the cache is
 // empty.
 func (c *Cache[K, V]) RandomKey() (K, bool) {
@@ -312,26 +952,334 @@ func (c *Cache[K, V]) Keys() []K {
 // Flush the cache, deleting all keys.
 func (c *Cache[K, V]) Flush() {
 	c.store.Flush()
+	c.journal.flush()
 }
 
 // Close the cache, stopping all running goroutines. Should be called when the
 // cache is no longer needed.
+//
+// If the cache was opened with [WithAsyncCallbacks], Close blocks until all
+// queued callbacks have returned. If it was opened with [WithPeriodicSnapshot],
+// Close blocks until a final snapshot has been written. If it was opened with
+// [WithJournal], Close blocks until the journal has been synced to disk. Both
+// happen after running refreshes and queued callbacks have returned, so that
+// any writes they make are persisted.
 func (c *Cache[K, V]) Close() {
 	c.closer.Close()
+	// stop writing to the cache in the background before persisting it for the
+	// last time, so that no writes are lost.
+	if c.refreshers != nil {
+		c.refreshers.Close()
+	}
+	if c.dispatcher != nil {
+		c.dispatcher.Close()
+	}
+	if c.snapshotPath != "" {
+		c.snapshotting.Wait()
+		_ = c.snapshotFile(c.snapshotPath)
+	}
+	if c.journal != nil {
+		c.journalSyncing.Wait()
+		c.journal.close()
+	}
+}
+
+// newStore creates the store of the cache using policy, split into shards if
+// there are more than one.
+func (c *Cache[K, V]) newStore(policy Policy[K, V]) Store[K, V] {
+	var onRemove data.RemoveFunc[K, V]
+	if c.hasCallbacks() {
+		onRemove = c.notify
+	}
+
+	if c.shards == 1 {
+		store := policy.NewStore(c.capacity, onRemove, c.clock)
+		c.swapper, _ = store.(Swapper[K, V])
+		return store
+	}
+
+	shards := make([]ports.Storer[K, V], c.shards)
+	for i := range shards {
+		// spread the remainder of the capacity over the first shards.
+		capacity := c.capacity / c.shards
+		if i < c.capacity%c.shards {
+			capacity++
+		}
+		shards[i] = policy.NewStore(capacity, onRemove, c.clock)
+	}
+
+	store := sharded.New(shards, c.hasher)
+	if _, ok := shards[0].(Swapper[K, V]); ok {
+		c.swapper, _ = store.(Swapper[K, V])
+	}
+
+	return store
+}
+
+// get returns the item of key if it exists and has not expired, counting the
+// lookup in the stats of the cache. Expired items are deleted if the cache was
+// opened with [WithPassiveExpiration], and stale items are refreshed if it was
+// opened with [WithRefresh].
+func (c *Cache[K, V]) get(key K) (data.Item[K, V], bool) {
+	item, ok := c.store.Get(key)
+	if !ok {
+		c.stats.misses.Add(1)
+		return data.Item[K, V]{}, false
+	}
+
+	now := c.clock.Now()
+	if item.IsExpiredAt(now) {
+		c.stats.misses.Add(1)
+		c.stats.expiredLookups.Add(1)
+		if c.passiveExpiration {
+			c.stats.passiveExpirations.Add(1)
+			c.store.Remove(key)
+		}
+		return data.Item[K, V]{}, false
+	}
+
+	if c.expiresEarly(item, now) {
+		c.stats.misses.Add(1)
+		return data.Item[K, V]{}, false
+	}
+
+	if item.IsStaleAt(now) {
+		c.refresh(key, item)
+	}
+
+	c.stats.hits.Add(1)
+	return item, true
+}
+
+// peek returns the item of key without counting it as an access of key, unless
+// the store does not support it.
+func (c *Cache[K, V]) peek(key K) (data.Item[K, V], bool) {
+	if peeker, ok := c.store.(Peeker[K, V]); ok {
+		return peeker.Peek(key)
+	}
+
+	return c.store.Get(key)
+}
+
+// add item to the store after weighing and versioning it, rejecting items which
+// can never fit in a shard.
+func (c *Cache[K, V]) add(key K, item data.Item[K, V]) {
+	if c.weigher != nil {
+		item.Weight = c.weigher(key, item.Value)
+	}
+	item.Version = c.versions.Add(1)
+
+	if c.capacity > 0 && item.Cost() > int64(c.capacity/c.shards) {
+		c.store.Remove(key)
+		c.journal.record(key)
+		return
+	}
+
+	c.store.Add(key, item)
+	c.stats.sets.Add(1)
+	c.journal.record(key)
+}
+
+// swap item into the store after weighing and versioning it, only if key still
+// has version. Items which can never fit in a shard are rejected, deleting key
+// if it still has version.
+func (c *Cache[K, V]) swap(key K, version uint64, item data.Item[K, V]) bool {
+	if c.weigher != nil {
+		item.Weight = c.weigher(key, item.Value)
+	}
+	item.Version = c.versions.Add(1)
+
+	if c.capacity > 0 && item.Cost() > int64(c.capacity/c.shards) {
+		if c.compareAndRemove(key, version) {
+			c.journal.record(key)
+		}
+		return false
+	}
+
+	if !c.compareAndSwap(key, version, item) {
+		return false
+	}
+	c.stats.sets.Add(1)
+	c.journal.record(key)
+
+	return true
+}
+
+// compareAndSwap adds item to the store only if key holds an unexpired item of
+// version. Stores which do not support compare-and-swap are compared & added to
+// while holding c.swapping, which only serializes them with other compare &
+// swaps.
+func (c *Cache[K, V]) compareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	if c.swapper != nil {
+		return c.swapper.CompareAndSwap(key, version, item)
+	}
+
+	c.swapping.Lock()
+	defer c.swapping.Unlock()
+
+	if existing, ok := c.store.Get(key); !ok || existing.Version != version || existing.IsExpiredAt(c.clock.Now()) {
+		return false
+	}
+	c.store.Add(key, item)
+
+	return true
+}
+
+// compareAndRemove removes key from the store only if it holds an unexpired
+// item of version, see [Cache.compareAndSwap].
+func (c *Cache[K, V]) compareAndRemove(key K, version uint64) bool {
+	if c.swapper != nil {
+		return c.swapper.CompareAndRemove(key, version)
+	}
+
+	c.swapping.Lock()
+	defer c.swapping.Unlock()
+
+	if existing, ok := c.store.Get(key); !ok || existing.Version != version || existing.IsExpiredAt(c.clock.Now()) {
+		return false
+	}
+	c.store.Remove(key)
+
+	return true
+}
+
+// remove key from the store, reporting whether it held an unexpired item. The
+// item is removed via compareAndRemove so that it is only reported as removed
+// if it was not changed or deleted concurrently.
+func (c *Cache[K, V]) remove(key K) bool {
+	for {
+		item, ok := c.peek(key)
+		if !ok {
+			return false
+		}
+
+		if item.IsExpiredAt(c.clock.Now()) {
+			c.store.Remove(key)
+			return false
+		}
+
+		if c.compareAndRemove(key, item.Version) {
+			return true
+		}
+	}
+}
+
+// notify calls the callback for reason with key & the value of item, on the
+// dispatcher if there is one.
+func (c *Cache[K, V]) notify(key K, item data.Item[K, V], reason Reason) {
+	var callback func(key K, value V, reason Reason)
+	switch reason {
+	case ReasonEvicted:
+		callback = c.onEvict
+	case ReasonExpired:
+		callback = c.onExpire
+	case ReasonDeleted:
+		callback = c.onDelete
+	}
+
+	if callback == nil {
+		return
+	}
+
+	if c.dispatcher == nil {
+		callback(key, item.Value, reason)
+		return
+	}
+
+	c.dispatcher.Dispatch(func() {
+		callback(key, item.Value, reason)
+	})
+}
+
+func (c *Cache[K, V]) hasCallbacks() bool {
+	return c.onEvict != nil || c.onExpire != nil || c.onDelete != nil
+}
+
+// expiresEarly reports whether item should be treated as expired ahead of its
+// expiry, which XFetch decides by checking whether now plus its load duration
+// scaled by beta and an exponentially distributed random factor is past it.
+func (c *Cache[K, V]) expiresEarly(item data.Item[K, V], now time.Time) bool {
+	if c.beta <= 0 || item.ExpireAt == nil || item.LoadDuration <= 0 {
+		return false
+	}
+
+	// 1-random is in (0, 1] so its log is never infinite.
+	gap := time.Duration(float64(item.LoadDuration) * c.beta * -math.Log(1-c.random()))
+
+	return !now.Add(gap).Before(*item.ExpireAt)
+}
+
+// refresh schedules a refresh of the stale item of key unless one is already
+// scheduled or the refresh workers are busy. The refreshed value is only set
+// if key still holds the stale item once it is refreshed, so that refreshes
+// never undo writes made in the meantime.
+func (c *Cache[K, V]) refresh(key K, stale data.Item[K, V]) {
+	if c.refresher == nil {
+		return
+	}
+
+	c.refreshingMu.Lock()
+	defer c.refreshingMu.Unlock()
+
+	if _, ok := c.refreshing[key]; ok {
+		return
+	}
+
+	scheduled := c.refreshers.TryGo(func(ctx context.Context) {
+		defer func() {
+			c.refreshingMu.Lock()
+			delete(c.refreshing, key)
+			c.refreshingMu.Unlock()
+		}()
+
+		value, softTTL, hardTTL, err := c.refresher(ctx, key, stale.Value)
+		if err != nil {
+			return
+		}
+
+		item := data.Item[K, V]{Value: value}
+		now := c.clock.Now()
+		if softTTL > 0 {
+			staleAt := now.Add(softTTL)
+			item.StaleAt = &staleAt
+		}
+		if hardTTL > 0 {
+			expireAt := now.Add(hardTTL)
+			item.ExpireAt = &expireAt
+		}
+		c.swap(key, stale.Version, item)
+	})
+	if scheduled {
+		c.refreshing[key] = struct{}{}
+	}
+}
+
+// refreshAhead schedules a refresh of every stale key which has not expired.
+func (c *Cache[K, V]) refreshAhead() {
+	if c.refresher == nil {
+		return
+	}
+
+	now := c.clock.Now()
+	for key, item := range c.store.Items() {
+		if item.IsStaleAt(now) && !item.IsExpiredAt(now) {
+			c.refresh(key, item)
+		}
+	}
 }
 
 func (c *Cache[K, V]) closed() bool {
 	return c.closer.Closed()
 }
 
-func (c *Cache[K, V]) runActiveExpirer(interval time.Duration) {
-	ticker := time.NewTicker(interval)
+func (c *Cache[K, V]) runActiveExpirer(ticker Ticker) {
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			c.expirer.Expire(c)
+		case <-ticker.C():
+			c.expirer.Expire(activeExpirer[K, V]{c})
+			c.refreshAhead()
 		case <-c.closer.Ch():
 			return
 		}
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"testing"
 	"time"
+
+	"github.com/wafer-bw/memcache"
 )
 
 var sizes = []int{100, 1000, 10000, 100000}
@@ -168,3 +170,32 @@ func BenchmarkCache_Keys(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCache_SetGetParallel(b *testing.B) {
+	for policy, newCache := range policies {
+		for _, shards := range []int{1, 16} {
+			size := 10000
+			cache, err := newCache(size, memcache.WithShards[int, int](shards))
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < size; i++ {
+				cache.Set(i, i)
+			}
+
+			b.Run(fmt.Sprintf("%d shards %s", shards, policy), func(b *testing.B) {
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Int()
+					for pb.Next() {
+						i++
+						if i%4 == 0 {
+							cache.Set(i%size, i)
+						} else {
+							_, _ = cache.Get(i % size)
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -1,12 +1,29 @@
 package memcache_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/memcachetest"
 )
 
+func ExampleOpen() {
+	capacity := 10
+	cache, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](),
+		memcache.WithCapacity[int, string](capacity),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
 func ExampleOpenNoEvictionCache() {
 	capacity := 10
 	interval := 1 * time.Second
@@ -60,6 +77,202 @@ func ExampleOpenAllKeysLFUCache() {
 	defer cache.Close()
 }
 
+func ExampleOpenVolatileLFUCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenVolatileLFUCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenAllKeysRandomCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenAllKeysRandomCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenVolatileRandomCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenVolatileRandomCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenVolatileTTLCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenVolatileTTLCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenTinyLFUCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenTinyLFUCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenARCCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenARCCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenSIEVECache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenSIEVECache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleOpenS3FIFOCache() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenS3FIFOCache(capacity,
+		memcache.WithActiveExpiration[int, string](interval),
+		memcache.WithPassiveExpiration[int, string](),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleWithSampledActiveExpiration() {
+	capacity := 10
+	interval := 1 * time.Second
+	cache, err := memcache.OpenAllKeysLRUCache(capacity,
+		memcache.WithSampledActiveExpiration[int, string](interval, 20, 0.25),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+}
+
+func ExampleWithWeigher() {
+	maxWeight := int64(1 << 20) // 1 MiB
+	cache, err := memcache.OpenAllKeysLRUCache(0,
+		memcache.WithWeigher(func(_ int, value []byte) int64 { return int64(len(value)) }),
+		memcache.WithMaxWeight[int, []byte](maxWeight),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.Set(1, make([]byte, 512<<10))
+	cache.Set(2, make([]byte, 768<<10)) // evicts 1 to remain within 1 MiB.
+	cache.Set(3, make([]byte, 2<<20))   // too heavy to ever be stored.
+
+	fmt.Println(cache.Keys())
+	fmt.Println(cache.Weight())
+	// Output:
+	// [2]
+	// 786432
+}
+
+func ExampleWithShards() {
+	capacity := 10_000
+	cache, err := memcache.OpenAllKeysLRUCache(capacity,
+		memcache.WithShards[string, int](16),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.Set("one", 1)
+
+	v, ok := cache.Get("one")
+	fmt.Println(v, ok)
+	// Output:
+	// 1 true
+}
+
+func ExampleWithOnEvict() {
+	onRemove := func(key int, value string, reason memcache.Reason) {
+		fmt.Println(reason, key, value)
+	}
+
+	cache, err := memcache.OpenAllKeysLRUCache(2,
+		memcache.WithOnEvict(onRemove),
+		memcache.WithOnDelete(onRemove),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.Set(1, "one")
+	cache.Set(2, "two")
+	cache.Set(3, "three") // evicts 1.
+	cache.Delete(2)
+	// Output:
+	// evicted 1 one
+	// deleted 2 two
+}
+
+func ExampleWithClock() {
+	clock := memcachetest.NewClock(time.Now())
+	cache, err := memcache.OpenAllKeysLRUCache(10, memcache.WithClock[int, string](clock))
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.SetEx(1, "one", time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	_, ok := cache.Get(1)
+	fmt.Println(ok)
+	// Output:
+	// false
+}
+
 func ExampleCache_Set() {
 	cache, err := memcache.OpenNoEvictionCache[int, string]()
 	if err != nil {
@@ -78,6 +291,55 @@ func ExampleCache_SetEx() {
 	cache.SetEx(1, "one", 1*time.Second)
 }
 
+func ExampleWithEarlyExpiration() {
+	cache, err := memcache.OpenAllKeysLRUCache(10, memcache.WithEarlyExpiration[int, string](1))
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	// values loaded via GetOrLoad record how long they took to load, which
+	// makes them increasingly likely to be reported missing, and so reloaded,
+	// by a single caller as their expiry approaches.
+	value, err := cache.GetOrLoad(context.Background(), 1, func(_ context.Context, key int) (string, time.Duration, error) {
+		return strconv.Itoa(key), time.Hour, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(value)
+	// Output:
+	// 1
+}
+
+func ExampleWithRefresh() {
+	refresher := func(_ context.Context, key int, stale string) (string, time.Duration, time.Duration, error) {
+		return stale + " (refreshed)", time.Minute, time.Hour, nil
+	}
+
+	cache, err := memcache.OpenAllKeysLRUCache(10, memcache.WithRefresh(refresher, 1))
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.SetExStale(1, "one", time.Millisecond, time.Hour)
+	time.Sleep(2 * time.Millisecond)
+
+	value, _ := cache.Get(1) // returns the stale value and schedules a refresh.
+	fmt.Println(value)
+
+	for value == "one" { // wait for the refresh to finish.
+		time.Sleep(time.Millisecond)
+		value, _ = cache.Get(1)
+	}
+	fmt.Println(value)
+	// Output:
+	// one
+	// one (refreshed)
+}
+
 func ExampleCache_Get() {
 	cache, err := memcache.OpenNoEvictionCache[int, string]()
 	if err != nil {
@@ -97,6 +359,55 @@ func ExampleCache_Get() {
 	// false
 }
 
+func ExampleCache_CompareAndSwap() {
+	cache, err := memcache.OpenNoEvictionCache[string, int]()
+	if err != nil {
+		panic(err)
+	}
+
+	cache.Set("visits", 1)
+
+	// increment visits, retrying whenever another writer set it first.
+	for {
+		visits, version, _ := cache.GetWithVersion("visits")
+		if cache.CompareAndSwap("visits", version, visits+1) {
+			break
+		}
+	}
+
+	_, version, _ := cache.GetWithVersion("visits")
+	cache.Set("visits", 10)
+	fmt.Println(cache.CompareAndSwap("visits", version, 3))
+
+	v, _ := cache.Get("visits")
+	fmt.Println(v)
+	// Output:
+	// false
+	// 10
+}
+
+func ExampleCache_GetOrLoad() {
+	cache, err := memcache.OpenAllKeysLRUCache[int, string](10)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	loader := func(_ context.Context, key int) (string, time.Duration, error) {
+		fmt.Println("loading", key)
+		return strconv.Itoa(key), time.Minute, nil
+	}
+
+	value, _ := cache.GetOrLoad(context.Background(), 1, loader)
+	fmt.Println(value)
+	value, _ = cache.GetOrLoad(context.Background(), 1, loader) // already loaded.
+	fmt.Println(value)
+	// Output:
+	// loading 1
+	// 1
+	// 1
+}
+
 func ExampleCache_TTL() {
 	cache, err := memcache.OpenNoEvictionCache[int, string]()
 	if err != nil {
@@ -172,6 +483,22 @@ func ExampleCache_Size() {
 	// 2
 }
 
+func ExampleCache_Weight() {
+	cache, err := memcache.OpenNoEvictionCache(
+		memcache.WithWeigher(func(_ int, value string) int64 { return int64(len(value)) }),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	cache.Set(1, "one")
+	cache.Set(2, "three")
+
+	fmt.Println(cache.Weight())
+	// Output:
+	// 8
+}
+
 func ExampleCache_RandomKey() {
 	cache, err := memcache.OpenNoEvictionCache[int, string]()
 	if err != nil {
@@ -204,3 +531,86 @@ func ExampleCache_Keys() {
 	// 1
 	// 2
 }
+
+func ExampleCache_Stats() {
+	cache, err := memcache.OpenAllKeysLRUCache[int, string](2)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.Set(1, "one")
+	cache.Set(2, "two")
+	cache.Set(3, "three") // evicts 1.
+	_, _ = cache.Get(1)
+	_, _ = cache.Get(3)
+
+	stats := cache.Stats()
+	fmt.Println(stats.Hits, stats.Misses, stats.Sets, stats.Evictions)
+	fmt.Println(stats.HitRatio())
+	// Output:
+	// 1 1 3 1
+	// 0.5
+}
+
+func ExampleCache_Snapshot() {
+	cache, err := memcache.OpenAllKeysLRUCache[int, string](10)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	cache.Set(1, "one")
+	cache.SetEx(2, "two", time.Hour)
+
+	var snapshot bytes.Buffer
+	if err := cache.Snapshot(&snapshot); err != nil {
+		panic(err)
+	}
+
+	// e.g. in a new process after a restart.
+	restored, err := memcache.OpenAllKeysLRUCache[int, string](10)
+	if err != nil {
+		panic(err)
+	}
+	defer restored.Close()
+
+	if err := restored.Restore(&snapshot); err != nil {
+		panic(err)
+	}
+
+	value, _ := restored.Get(2)
+	fmt.Println(value, restored.Size())
+	// Output:
+	// two 2
+}
+
+func ExampleWithJournal() {
+	dir, err := os.MkdirTemp("", "memcache")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.aof")
+
+	cache, err := memcache.OpenAllKeysLRUCache(10, memcache.WithJournal[int, string](path, memcache.FsyncEverySecond, 1<<20))
+	if err != nil {
+		panic(err)
+	}
+	cache.Set(1, "one")
+	cache.Set(2, "two")
+	cache.Delete(1)
+	cache.Close()
+
+	// e.g. in a new process after a restart.
+	replayed, err := memcache.OpenAllKeysLRUCache(10, memcache.WithJournal[int, string](path, memcache.FsyncEverySecond, 1<<20))
+	if err != nil {
+		panic(err)
+	}
+	defer replayed.Close()
+
+	value, _ := replayed.Get(2)
+	fmt.Println(value, replayed.Size())
+	// Output:
+	// two 1
+}
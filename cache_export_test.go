@@ -1,13 +1,14 @@
 package memcache
 
 import (
+	"os"
 	"time"
 
 	"github.com/wafer-bw/memcache/internal/ports"
 )
 
 // export for testing.
-func (c *Cache[K, V]) Store() ports.Storer[K, V] {
+func (c *Cache[K, V]) Store() Store[K, V] {
 	return c.store
 }
 
@@ -22,13 +23,28 @@ func (c *Cache[K, V]) ExpirationInterval() time.Duration {
 }
 
 // export for testing.
-//
-// TODO: remove.
-func (c *Cache[K, V]) Capacity() int {
-	return c.capacity
+func (c *Cache[K, V]) Expirer() ports.Expirer[K, V] {
+	return c.expirer
 }
 
 // export for testing.
 func (c *Cache[K, V]) Closed() bool {
 	return c.closed()
 }
+
+// export for testing.
+func (c *Cache[K, V]) WaitJournalRewrite() {
+	if c.journal != nil {
+		c.journal.rewrites.Wait()
+	}
+}
+
+// export for testing.
+func (c *Cache[K, V]) SwapJournalFile(f *os.File) *os.File {
+	c.journal.mu.Lock()
+	defer c.journal.mu.Unlock()
+
+	old := c.journal.file
+	c.journal.file = f
+	return old
+}
@@ -1,21 +1,41 @@
 package memcache_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/wafer-bw/memcache"
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
 	"github.com/wafer-bw/memcache/internal/eviction/allkeyslru"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeysrandom"
+	"github.com/wafer-bw/memcache/internal/eviction/arc"
 	"github.com/wafer-bw/memcache/internal/eviction/noevict"
+	"github.com/wafer-bw/memcache/internal/eviction/s3fifo"
+	"github.com/wafer-bw/memcache/internal/eviction/sieve"
+	"github.com/wafer-bw/memcache/internal/eviction/tinylfu"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilelfu"
 	"github.com/wafer-bw/memcache/internal/eviction/volatilelru"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilerandom"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilettl"
+	"github.com/wafer-bw/memcache/internal/expire"
 	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/sharded"
+	"github.com/wafer-bw/memcache/memcachetest"
 )
 
 var _ ports.Cacher[int, int] = (*memcache.Cache[int, int])(nil)
@@ -35,6 +55,30 @@ var policies = map[string]func(size int, options ...memcache.Option[int, int]) (
 	allkeyslfu.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
 		return memcache.OpenAllKeysLFUCache[int, int](size, options...)
 	},
+	volatilelfu.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenVolatileLFUCache[int, int](size, options...)
+	},
+	allkeysrandom.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenAllKeysRandomCache[int, int](size, options...)
+	},
+	volatilerandom.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenVolatileRandomCache[int, int](size, options...)
+	},
+	volatilettl.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenVolatileTTLCache[int, int](size, options...)
+	},
+	tinylfu.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenTinyLFUCache[int, int](size, options...)
+	},
+	arc.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenARCCache[int, int](size, options...)
+	},
+	sieve.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenSIEVECache[int, int](size, options...)
+	},
+	s3fifo.PolicyName: func(size int, options ...memcache.Option[int, int]) (*memcache.Cache[int, int], error) {
+		return memcache.OpenS3FIFOCache[int, int](size, options...)
+	},
 }
 
 func TestInvalidCapacityError_Error(t *testing.T) {
@@ -48,6 +92,25 @@ func TestInvalidCapacityError_Error(t *testing.T) {
 	})
 }
 
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("built-in stores implement swapper & peeker", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			for _, shards := range []int{1, 4} {
+				cache, err := newCache(cacheSize, memcache.WithShards[int, int](shards))
+				require.NoError(t, err)
+				defer cache.Close()
+
+				require.Implements(t, (*memcache.Swapper[int, int])(nil), cache.Store(), "%s with %d shards", policy, shards)
+				require.Implements(t, (*memcache.Peeker[int, int])(nil), cache.Store(), "%s with %d shards", policy, shards)
+			}
+		}
+	})
+}
+
 func TestCache_concurrentAccess(t *testing.T) {
 	// TODO: improve this test by making random actions against the cache
 	//       concurrently for a long time to look for deadlocks.
@@ -132,325 +195,354 @@ func TestCache_activeExpiration(t *testing.T) {
 				cache.SetEx(2, 2, ttl)
 				cache.SetEx(3, 3, ttl)
 
-				time.Sleep(5 * ttl)
-
-				items := store.Items()
-				require.Empty(t, items)
+				require.Eventually(t, func() bool {
+					return len(store.Items()) == 0
+				}, 1*time.Second, ttl)
 			})
 		}
 	})
 }
 
-func TestOpenNoEvictionCache(t *testing.T) {
+func TestCache_clock(t *testing.T) {
 	t.Parallel()
 
-	t.Run("returns a new no eviction cache", func(t *testing.T) {
-		t.Parallel()
-
-		c, err := memcache.OpenNoEvictionCache[int, string]()
-		require.NoError(t, err)
-		require.NotNil(t, c)
-		require.IsType(t, &noevict.Store[int, string]{}, c.Store())
-	})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	t.Run("does not panic when provided nil options", func(t *testing.T) {
+	t.Run("expires keys by the time of the clock", func(t *testing.T) {
 		t.Parallel()
 
-		require.NotPanics(t, func() {
-			c, err := memcache.OpenNoEvictionCache[int, string](nil, nil)
-			require.NoError(t, err)
-			require.NotNil(t, c)
-		})
-	})
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
 
-	t.Run("returns an error when an option returns an error", func(t *testing.T) {
-		t.Parallel()
+				clock := memcachetest.NewClock(now)
+				cache, err := newCache(cacheSize, memcache.WithClock[int, int](clock))
+				require.NoError(t, err)
+				defer cache.Close()
 
-		errDummy := errors.New("dummy")
+				cache.SetEx(1, 10, time.Minute)
+				clock.Advance(time.Minute)
+				value, ok := cache.Get(1)
+				require.True(t, ok)
+				require.Equal(t, 10, value)
+				ttl, _ := cache.TTL(1)
+				require.Equal(t, time.Duration(0), *ttl)
 
-		c, err := memcache.OpenNoEvictionCache[int, string](func(c *memcache.Cache[int, string]) error { return errDummy })
-		require.ErrorIs(t, err, errDummy)
-		require.Nil(t, c)
+				clock.Advance(time.Nanosecond)
+				_, ok = cache.Get(1)
+				require.False(t, ok)
+			})
+		}
 	})
 
-	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+	t.Run("ticks active expiration with the clock", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithCapacity[int, string](-1))
-		require.Error(t, err)
-		require.Nil(t, c)
-	})
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
 
-	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
-		t.Parallel()
+				clock := memcachetest.NewClock(now)
+				cache, err := newCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithActiveExpiration[int, int](time.Hour))
+				require.NoError(t, err)
+				defer cache.Close()
 
-		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithPassiveExpiration[int, string]())
-		require.NoError(t, err)
-		require.True(t, c.PassiveExpiration())
+				cache.SetEx(1, 10, time.Minute)
+				cache.SetEx(2, 20, 2*time.Hour)
+				clock.Advance(time.Hour)
+
+				require.Eventually(t, func() bool {
+					return cache.Size() == 1
+				}, time.Second, time.Millisecond)
+				_, ok := cache.Store().Get(2)
+				require.True(t, ok)
+			})
+		}
 	})
 
-	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+	t.Run("reports keys deleted after expiring by the time of the clock as expired", func(t *testing.T) {
 		t.Parallel()
 
-		interval := 25 * time.Millisecond
-
-		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithActiveExpiration[int, string](interval))
+		var reason memcache.Reason
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithOnExpire(func(_ int, _ int, r memcache.Reason) {
+			reason = r
+		}))
 		require.NoError(t, err)
-		defer c.Close()
-		require.Equal(t, interval, c.ExpirationInterval())
-	})
+		defer cache.Close()
 
-	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
-		t.Parallel()
-
-		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithActiveExpiration[int, int](0 * time.Second))
-		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+		cache.SetEx(1, 10, time.Minute)
+		clock.Advance(2 * time.Minute)
+		cache.Delete(1)
+		require.Equal(t, memcache.ReasonExpired, reason)
 	})
+}
 
-	t.Run("with capacity sets capacity", func(t *testing.T) {
+func TestCache_sampledActiveExpiration(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deletes expired keys", func(t *testing.T) {
 		t.Parallel()
 
-		capacity := 10
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
 
-		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithCapacity[int, string](capacity))
-		require.NoError(t, err)
-		require.Equal(t, capacity, c.Capacity())
-	})
+				ttl := 1 * time.Millisecond
 
-	t.Run("returns an error if capacity is less than 0", func(t *testing.T) {
-		t.Parallel()
+				cache, _ := newCache(cacheSize, memcache.WithSampledActiveExpiration[int, int](ttl, 20, 0.25))
+				defer cache.Close()
+				store := cache.Store()
 
-		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithCapacity[int, int](-1))
-		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+				cache.SetEx(1, 1, ttl)
+				cache.SetEx(2, 2, ttl)
+				cache.SetEx(3, 3, ttl)
+
+				require.Eventually(t, func() bool {
+					return len(store.Items()) == 0
+				}, 1*time.Second, ttl)
+			})
+		}
 	})
 }
 
-func TestOpenAllKeysLRUCache(t *testing.T) {
+func TestOpen(t *testing.T) {
 	t.Parallel()
 
-	t.Run("returns a new all keys lru cache", func(t *testing.T) {
+	t.Run("returns a new cache backed by the policy's store", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLRUCache[int, string](10)
+		c, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string]())
 		require.NoError(t, err)
 		require.NotNil(t, c)
 		require.IsType(t, &allkeyslru.Store[int, string]{}, c.Store())
 	})
 
-	t.Run("does not panic when provided nil options", func(t *testing.T) {
+	t.Run("uses the policy's default capacity", func(t *testing.T) {
 		t.Parallel()
 
-		require.NotPanics(t, func() {
-			c, err := memcache.OpenAllKeysLRUCache[int, string](10, nil, nil)
-			require.NoError(t, err)
-			require.NotNil(t, c)
-		})
+		c, err := memcache.Open(memcache.AllKeysLFUPolicy[int, string]())
+		require.NoError(t, err)
+		require.Equal(t, allkeyslfu.DefaultCapacity, c.Capacity())
 	})
 
-	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+	t.Run("passes capacity to the policy's store", func(t *testing.T) {
 		t.Parallel()
 
-		errDummy := errors.New("dummy")
+		var gotCapacity int
+		policy := memcache.AllKeysLRUPolicy[int, string]()
+		newStore := policy.NewStore
+		policy.NewStore = func(capacity int, onRemove data.RemoveFunc[int, string], clock data.Clock) memcache.Store[int, string] {
+			gotCapacity = capacity
+			return newStore(capacity, onRemove, clock)
+		}
 
-		c, err := memcache.OpenAllKeysLRUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
-		require.ErrorIs(t, err, errDummy)
-		require.Nil(t, c)
+		_, err := memcache.Open(policy, memcache.WithCapacity[int, string](5))
+		require.NoError(t, err)
+		require.Equal(t, 5, gotCapacity)
 	})
 
-	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+	t.Run("with max weight sets capacity", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLRUCache[int, string](0)
-		require.Error(t, err)
-		require.Nil(t, c)
+		c, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithMaxWeight[int, string](1<<20))
+		require.NoError(t, err)
+		require.Equal(t, 1<<20, c.Capacity())
 	})
 
-	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+	t.Run("returns an error if max weight is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLRUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
-		require.NoError(t, err)
-		require.True(t, c.PassiveExpiration())
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithMaxWeight[int, string](0))
+		require.ErrorIs(t, err, memcache.ErrInvalidMaxWeight)
 	})
 
-	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+	t.Run("returns an error if the weigher is nil", func(t *testing.T) {
 		t.Parallel()
 
-		interval := 25 * time.Millisecond
-
-		c, err := memcache.OpenAllKeysLRUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
-		require.NoError(t, err)
-		defer c.Close()
-		require.Equal(t, interval, c.ExpirationInterval())
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithWeigher[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidWeigher)
 	})
 
-	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+	t.Run("returns an error if a callback is nil", func(t *testing.T) {
 		t.Parallel()
 
-		_, err := memcache.OpenAllKeysLRUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
-		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+		policy := memcache.AllKeysLRUPolicy[int, string]()
+		_, err := memcache.Open(policy, memcache.WithOnEvict[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidCallback)
+		_, err = memcache.Open(policy, memcache.WithOnExpire[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidCallback)
+		_, err = memcache.Open(policy, memcache.WithOnDelete[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidCallback)
 	})
 
-	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+	t.Run("returns an error if the callback buffer size is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		_, err := memcache.OpenAllKeysLRUCache[int, int](allkeyslru.MinimumCapacity - 1)
-		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithAsyncCallbacks[int, string](0))
+		require.ErrorIs(t, err, memcache.ErrInvalidBufferSize)
 	})
-}
 
-func TestOpenAllKeysLFUCache(t *testing.T) {
-	t.Parallel()
-
-	t.Run("returns a new all keys lfu cache", func(t *testing.T) {
+	t.Run("returns an error if the refresher is nil", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLFUCache[int, string](10)
-		require.NoError(t, err)
-		require.NotNil(t, c)
-		require.IsType(t, &allkeyslfu.Store[int, string]{}, c.Store())
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithRefresh[int, string](nil, 1))
+		require.ErrorIs(t, err, memcache.ErrInvalidRefresher)
 	})
 
-	t.Run("does not panic when provided nil options", func(t *testing.T) {
+	t.Run("returns an error if refresh workers is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		require.NotPanics(t, func() {
-			c, err := memcache.OpenAllKeysLFUCache[int, string](10, nil, nil)
-			require.NoError(t, err)
-			require.NotNil(t, c)
-		})
+		refresher := func(context.Context, int, string) (string, time.Duration, time.Duration, error) {
+			return "", 0, 0, nil
+		}
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithRefresh(refresher, 0))
+		require.ErrorIs(t, err, memcache.ErrInvalidWorkers)
 	})
 
-	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+	t.Run("returns an error if beta is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		errDummy := errors.New("dummy")
-
-		c, err := memcache.OpenAllKeysLFUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
-		require.ErrorIs(t, err, errDummy)
-		require.Nil(t, c)
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithEarlyExpiration[int, string](0))
+		require.ErrorIs(t, err, memcache.ErrInvalidBeta)
 	})
 
-	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+	t.Run("returns an error if the clock is nil", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLFUCache[int, string](allkeyslfu.MinimumCapacity - 1)
-		require.Error(t, err)
-		require.Nil(t, c)
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithClock[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidClock)
 	})
 
-	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+	t.Run("returns an error if the random source is nil", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenAllKeysLFUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
-		require.NoError(t, err)
-		require.True(t, c.PassiveExpiration())
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithRandom[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidRandom)
 	})
 
-	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+	t.Run("returns an error if the snapshot path is empty", func(t *testing.T) {
 		t.Parallel()
 
-		interval := 25 * time.Millisecond
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithPeriodicSnapshot[int, string]("", time.Minute))
+		require.ErrorIs(t, err, memcache.ErrInvalidPath)
+	})
 
-		c, err := memcache.OpenAllKeysLFUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
-		require.NoError(t, err)
-		defer c.Close()
-		require.Equal(t, interval, c.ExpirationInterval())
+	t.Run("returns an error if the codec is nil", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithCodec[int, string](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidCodec)
 	})
 
-	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+	t.Run("returns an error if the snapshot interval is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		_, err := memcache.OpenAllKeysLFUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithPeriodicSnapshot[int, string](path, 0))
 		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
 	})
 
-	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+	t.Run("returns an error if the snapshot at path is invalid", func(t *testing.T) {
 		t.Parallel()
 
-		_, err := memcache.OpenAllKeysLFUCache[int, int](allkeyslru.MinimumCapacity - 1)
-		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+		require.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithPeriodicSnapshot[int, string](path, time.Minute))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
 	})
-}
 
-func TestOpenVolatileLRUCache(t *testing.T) {
-	t.Parallel()
-
-	t.Run("returns a new volatile lru cache", func(t *testing.T) {
+	t.Run("returns an error if the journal path is empty", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenVolatileLRUCache[int, string](10)
-		require.NoError(t, err)
-		require.NotNil(t, c)
-		require.IsType(t, &volatilelru.Store[int, string]{}, c.Store())
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithJournal[int, string]("", memcache.FsyncNever, 1024))
+		require.ErrorIs(t, err, memcache.ErrInvalidPath)
 	})
 
-	t.Run("does not panic when provided nil options", func(t *testing.T) {
+	t.Run("returns an error if the journal fsync policy is invalid", func(t *testing.T) {
 		t.Parallel()
 
-		require.NotPanics(t, func() {
-			c, err := memcache.OpenVolatileLRUCache[int, string](10, nil, nil)
-			require.NoError(t, err)
-			require.NotNil(t, c)
-		})
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithJournal[int, string](path, 0, 1024))
+		require.ErrorIs(t, err, memcache.ErrInvalidFsyncPolicy)
 	})
 
-	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+	t.Run("returns an error if the journal rewrite size is not greater than 0", func(t *testing.T) {
 		t.Parallel()
 
-		errDummy := errors.New("dummy")
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithJournal[int, string](path, memcache.FsyncNever, 0))
+		require.ErrorIs(t, err, memcache.ErrInvalidRewriteSize)
+	})
 
-		c, err := memcache.OpenVolatileLRUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
-		require.ErrorIs(t, err, errDummy)
-		require.Nil(t, c)
+	t.Run("returns an error if the journal at path is invalid", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		require.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+		_, err := memcache.Open(memcache.AllKeysLRUPolicy[int, string](), memcache.WithJournal[int, string](path, memcache.FsyncNever, 1024))
+		require.ErrorIs(t, err, memcache.ErrInvalidJournal)
 	})
 
-	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+	t.Run("returns an error if the policy has no store constructor", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenVolatileLRUCache[int, string](0)
-		require.Error(t, err)
+		c, err := memcache.Open(memcache.Policy[int, string]{Name: "custom"})
+		require.ErrorIs(t, err, memcache.ErrInvalidPolicy)
 		require.Nil(t, c)
 	})
 
-	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+	t.Run("returns an error naming the policy if the capacity is less than its minimum", func(t *testing.T) {
 		t.Parallel()
 
-		c, err := memcache.OpenVolatileLRUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
-		require.NoError(t, err)
-		require.True(t, c.PassiveExpiration())
+		policy := memcache.AllKeysLRUPolicy[int, string]()
+		policy.Name = "custom"
+
+		_, err := memcache.Open(policy, memcache.WithCapacity[int, string](policy.MinimumCapacity-1))
+		capacityErr := memcache.InvalidCapacityError{}
+		require.ErrorAs(t, err, &capacityErr)
+		require.Equal(t, "custom", capacityErr.Policy)
 	})
+}
 
-	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+func TestCache_sharded(t *testing.T) {
+	t.Parallel()
+
+	t.Run("splits the store and its capacity into shards", func(t *testing.T) {
 		t.Parallel()
 
-		interval := 25 * time.Millisecond
+		var capacities []int
+		policy := memcache.AllKeysLRUPolicy[int, int]()
+		newStore := policy.NewStore
+		policy.NewStore = func(capacity int, onRemove data.RemoveFunc[int, int], clock data.Clock) memcache.Store[int, int] {
+			capacities = append(capacities, capacity)
+			return newStore(capacity, onRemove, clock)
+		}
 
-		c, err := memcache.OpenVolatileLRUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		c, err := memcache.Open(policy, memcache.WithCapacity[int, int](10), memcache.WithShards[int, int](4))
 		require.NoError(t, err)
 		defer c.Close()
-		require.Equal(t, interval, c.ExpirationInterval())
-	})
-
-	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
-		t.Parallel()
 
-		_, err := memcache.OpenVolatileLRUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
-		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+		store, ok := c.Store().(*sharded.Store[int, int])
+		require.True(t, ok)
+		require.Equal(t, 4, store.Shards())
+		require.Equal(t, []int{3, 3, 2, 2}, capacities)
 	})
 
-	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+	t.Run("uses an indexed expirer when every shard is indexed", func(t *testing.T) {
 		t.Parallel()
 
-		_, err := memcache.OpenVolatileLRUCache[int, int](volatilelru.MinimumCapacity - 1)
-		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
-	})
-}
+		c, err := memcache.OpenVolatileTTLCache(100, memcache.WithShards[int, int](4))
+		require.NoError(t, err)
+		defer c.Close()
 
-func TestCache_Set(t *testing.T) {
-	t.Parallel()
+		require.IsType(t, expire.Indexed[int, int]{}, c.Expirer())
+	})
 
-	t.Run("successfully stores value in the cache at provided key", func(t *testing.T) {
+	t.Run("aggregates keys across shards", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
@@ -458,24 +550,2536 @@ func TestCache_Set(t *testing.T) {
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				cache, err := newCache(cacheSize, memcache.WithShards[int, int](8))
+				require.NoError(t, err)
 				defer cache.Close()
-				store := cache.Store()
 
-				cache.Set(1, 1)
+				for i := 0; i < 10; i++ {
+					cache.Set(i, i)
+				}
+				cache.Delete(0)
 
+				require.Equal(t, 9, cache.Size())
+				require.ElementsMatch(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, cache.Keys())
+				for i := 1; i < 10; i++ {
+					v, ok := cache.Get(i)
+					require.True(t, ok)
+					require.Equal(t, i, v)
+				}
+				key, ok := cache.RandomKey()
+				require.True(t, ok)
+				require.Contains(t, cache.Keys(), key)
+
+				cache.Flush()
+				require.Zero(t, cache.Size())
+				_, ok = cache.RandomKey()
+				require.False(t, ok)
+			})
+		}
+	})
+
+	t.Run("hashes keys with the provided hasher", func(t *testing.T) {
+		t.Parallel()
+
+		hashed := 0
+		c, err := memcache.OpenAllKeysLRUCache(100,
+			memcache.WithShards[int, int](2),
+			memcache.WithHasher[int, int](func(key int) uint64 {
+				hashed++
+				return uint64(key)
+			}),
+		)
+		require.NoError(t, err)
+		defer c.Close()
+
+		c.Set(1, 1)
+		require.Equal(t, 1, hashed)
+	})
+
+	t.Run("returns an error if shards is not greater than 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLRUCache(100, memcache.WithShards[int, int](0))
+		require.ErrorIs(t, err, memcache.ErrInvalidShards)
+	})
+
+	t.Run("returns an error if the hasher is nil", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLRUCache(100, memcache.WithHasher[int, int](nil))
+		require.ErrorIs(t, err, memcache.ErrInvalidHasher)
+	})
+
+	t.Run("returns an error if the capacity of a shard is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLRUCache(7, memcache.WithShards[int, int](4))
+		capacityErr := memcache.InvalidCapacityError{}
+		require.ErrorAs(t, err, &capacityErr)
+		require.Equal(t, allkeyslru.MinimumCapacity*4, capacityErr.Minimum)
+	})
+
+	t.Run("allows unbounded shards for policies which support them", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenNoEvictionCache(memcache.WithShards[int, int](4))
+		require.NoError(t, err)
+		defer c.Close()
+
+		for i := 0; i < 100; i++ {
+			c.Set(i, i)
+		}
+		require.Equal(t, 100, c.Size())
+	})
+}
+
+func TestOpenNoEvictionCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new no eviction cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenNoEvictionCache[int, string]()
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &noevict.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenNoEvictionCache[int, string](nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenNoEvictionCache[int, string](func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithCapacity[int, string](-1))
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+		require.IsType(t, expire.AllKeys[int, string]{}, c.Expirer())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithActiveExpiration[int, int](0 * time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("with sampled active expiration enables sampled active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithSampledActiveExpiration[int, string](interval, 10, 0.5))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+		require.Equal(t, &expire.RandomSample[int, string]{SampleSize: 10, ExpirePercent: 0.5, Clock: data.SystemClock{}}, c.Expirer())
+	})
+
+	t.Run("with sampled active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithSampledActiveExpiration[int, int](0*time.Second, 10, 0.5))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("with sampled active expiration returns an error if the sample size is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithSampledActiveExpiration[int, int](1*time.Second, 0, 0.5))
+		require.ErrorIs(t, err, memcache.ErrInvalidSampleSize)
+	})
+
+	t.Run("with sampled active expiration returns an error if the expire percent is out of range", func(t *testing.T) {
+		t.Parallel()
+
+		for _, percent := range []float32{0, -0.5, 1.5} {
+			_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithSampledActiveExpiration[int, int](1*time.Second, 10, percent))
+			require.ErrorIs(t, err, memcache.ErrInvalidExpirePercent)
+		}
+	})
+
+	t.Run("with capacity sets capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+
+		c, err := memcache.OpenNoEvictionCache[int, string](memcache.WithCapacity[int, string](capacity))
+		require.NoError(t, err)
+		require.Equal(t, capacity, c.Capacity())
+	})
+
+	t.Run("returns an error if capacity is less than 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenNoEvictionCache[int, int](memcache.WithCapacity[int, int](-1))
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenAllKeysLRUCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new all keys lru cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLRUCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &allkeyslru.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenAllKeysLRUCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenAllKeysLRUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLRUCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLRUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenAllKeysLRUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLRUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLRUCache[int, int](allkeyslru.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenAllKeysLFUCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new all keys lfu cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLFUCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &allkeyslfu.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenAllKeysLFUCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenAllKeysLFUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLFUCache[int, string](allkeyslfu.MinimumCapacity - 1)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysLFUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenAllKeysLFUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLFUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysLFUCache[int, int](allkeyslru.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenVolatileLRUCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new volatile lru cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLRUCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &volatilelru.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenVolatileLRUCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenVolatileLRUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLRUCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLRUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenVolatileLRUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileLRUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileLRUCache[int, int](volatilelru.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenVolatileLFUCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new volatile lfu cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLFUCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &volatilelfu.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenVolatileLFUCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenVolatileLFUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLFUCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileLFUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenVolatileLFUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileLFUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileLFUCache[int, int](volatilelfu.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenAllKeysRandomCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new all keys random cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysRandomCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &allkeysrandom.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenAllKeysRandomCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenAllKeysRandomCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysRandomCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenAllKeysRandomCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenAllKeysRandomCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysRandomCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenAllKeysRandomCache[int, int](allkeysrandom.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenVolatileRandomCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new volatile random cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileRandomCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &volatilerandom.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenVolatileRandomCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenVolatileRandomCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileRandomCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileRandomCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenVolatileRandomCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileRandomCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileRandomCache[int, int](volatilerandom.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenVolatileTTLCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new volatile ttl cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileTTLCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &volatilettl.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenVolatileTTLCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenVolatileTTLCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileTTLCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenVolatileTTLCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenVolatileTTLCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+		require.IsType(t, expire.Indexed[int, string]{}, c.Expirer())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileTTLCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenVolatileTTLCache[int, int](volatilettl.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenTinyLFUCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new tinylfu cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenTinyLFUCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &tinylfu.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenTinyLFUCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenTinyLFUCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenTinyLFUCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenTinyLFUCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenTinyLFUCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenTinyLFUCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenTinyLFUCache[int, int](tinylfu.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenARCCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new arc cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenARCCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &arc.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenARCCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenARCCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenARCCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenARCCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenARCCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenARCCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenARCCache[int, int](arc.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenSIEVECache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new SIEVE cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenSIEVECache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &sieve.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenSIEVECache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenSIEVECache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenSIEVECache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenSIEVECache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenSIEVECache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenSIEVECache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenSIEVECache[int, int](sieve.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestOpenS3FIFOCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new S3-FIFO cache", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenS3FIFOCache[int, string](10)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+		require.IsType(t, &s3fifo.Store[int, string]{}, c.Store())
+	})
+
+	t.Run("does not panic when provided nil options", func(t *testing.T) {
+		t.Parallel()
+
+		require.NotPanics(t, func() {
+			c, err := memcache.OpenS3FIFOCache[int, string](10, nil, nil)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+		})
+	})
+
+	t.Run("returns an error when an option returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		errDummy := errors.New("dummy")
+
+		c, err := memcache.OpenS3FIFOCache[int, string](10, func(c *memcache.Cache[int, string]) error { return errDummy })
+		require.ErrorIs(t, err, errDummy)
+		require.Nil(t, c)
+	})
+
+	t.Run("returns an error when opening the store returns an error", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenS3FIFOCache[int, string](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("with passive expiration enables passive expiration", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := memcache.OpenS3FIFOCache[int, string](10, memcache.WithPassiveExpiration[int, string]())
+		require.NoError(t, err)
+		require.True(t, c.PassiveExpiration())
+	})
+
+	t.Run("with active expiration enables active expiration", func(t *testing.T) {
+		t.Parallel()
+
+		interval := 25 * time.Millisecond
+
+		c, err := memcache.OpenS3FIFOCache[int, string](10, memcache.WithActiveExpiration[int, string](interval))
+		require.NoError(t, err)
+		defer c.Close()
+		require.Equal(t, interval, c.ExpirationInterval())
+	})
+
+	t.Run("with active expiration returns an error if the interval is less than or equal to 0", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenS3FIFOCache[int, int](10, memcache.WithActiveExpiration[int, int](0*time.Second))
+		require.ErrorIs(t, err, memcache.ErrInvalidInterval)
+	})
+
+	t.Run("returns an error if the capacity is less than the minimum", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := memcache.OpenS3FIFOCache[int, int](s3fifo.MinimumCapacity - 1)
+		require.ErrorAs(t, err, &memcache.InvalidCapacityError{})
+	})
+}
+
+func TestCache_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("successfully stores value in the cache at provided key", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				cache.Set(1, 1)
+
+				items := store.Items()
+				require.Contains(t, items, 1)
+				require.Equal(t, 1, items[1].Value)
+			})
+		}
+	})
+}
+
+func TestCache_Set_weighted(t *testing.T) {
+	t.Parallel()
+
+	weigher := func(_ int, value int) int64 { return int64(value) }
+
+	t.Run("keeps the weight of the cache within its max weight", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				maxWeight := int64(10)
+				cache, err := newCache(cacheSize, memcache.WithWeigher(weigher), memcache.WithMaxWeight[int, int](maxWeight))
+				require.NoError(t, err)
+				defer cache.Close()
+
+				for i := 0; i < cacheSize; i++ {
+					cache.Set(i, i%4+1)
+					require.LessOrEqual(t, cache.Weight(), maxWeight)
+				}
+				require.Positive(t, cache.Size())
+			})
+		}
+	})
+
+	t.Run("does not store values heavier than the max weight", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := newCache(cacheSize, memcache.WithWeigher(weigher), memcache.WithMaxWeight[int, int](10))
+				require.NoError(t, err)
+				defer cache.Close()
+
+				cache.Set(1, 2)
+				cache.Set(2, 3)
+				cache.SetEx(1, 11, time.Minute)
+
+				_, ok := cache.Get(1)
+				require.False(t, ok)
+				_, ok = cache.Get(2)
+				require.True(t, ok)
+				require.Equal(t, int64(3), cache.Weight())
+			})
+		}
+	})
+}
+
+func TestCache_SetEx(t *testing.T) {
+	t.Parallel()
+
+	t.Run("successfully stores value in the cache with a TTL", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				cache.SetEx(1, 1, 1*time.Minute)
+
+				items := store.Items()
+				require.Contains(t, items, 1)
+				require.Equal(t, 1, items[1].Value)
+				require.Greater(t, *items[1].ExpireAt, time.Now())
+			})
+		}
+	})
+}
+
+func TestCache_SetExStale(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns stale values until they expire without a refresher", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := newCache(cacheSize)
+				require.NoError(t, err)
+				defer cache.Close()
+
+				cache.SetExStale(1, 10, time.Millisecond, 50*time.Millisecond)
+				time.Sleep(2 * time.Millisecond)
+				value, ok := cache.Get(1)
+				require.True(t, ok)
+				require.Equal(t, 10, value)
+
+				require.Eventually(t, func() bool {
+					_, ok := cache.Get(1)
+					return !ok
+				}, time.Second, time.Millisecond)
+			})
+		}
+	})
+
+	t.Run("returns stale values while refreshing them once", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		refresher := func(_ context.Context, key int, stale int) (int, time.Duration, time.Duration, error) {
+			calls.Add(1)
+			<-release
+			return stale + 1, time.Minute, time.Hour, nil
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 4))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetExStale(1, 10, time.Millisecond, time.Hour)
+		time.Sleep(2 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			value, ok := cache.Get(1)
+			require.True(t, ok)
+			require.Equal(t, 10, value)
+		}
+		close(release)
+
+		require.Eventually(t, func() bool {
+			value, _ := cache.Get(1)
+			return value == 11
+		}, time.Second, time.Millisecond)
+		require.Equal(t, int32(1), calls.Load())
+
+		ttl, _ := cache.TTL(1)
+		require.Greater(t, *ttl, 59*time.Minute)
+	})
+
+	t.Run("keeps stale values and retries when refreshing them fails", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		refresher := func(context.Context, int, int) (int, time.Duration, time.Duration, error) {
+			if calls.Add(1) == 1 {
+				return 0, 0, 0, errors.New("refresh failed")
+			}
+			return 11, 0, 0, nil
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 1))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetExStale(1, 10, time.Millisecond, time.Hour)
+		time.Sleep(2 * time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			value, ok := cache.Get(1)
+			require.True(t, ok)
+			return value == 11
+		}, time.Second, time.Millisecond)
+		require.Equal(t, int32(2), calls.Load())
+
+		ttl, _ := cache.TTL(1)
+		require.Nil(t, ttl)
+	})
+
+	t.Run("does not restore keys deleted while they are refreshed", func(t *testing.T) {
+		t.Parallel()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		refresher := func(_ context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+			close(started)
+			<-release
+			return stale + 1, 0, 0, nil
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 1))
+		require.NoError(t, err)
+
+		cache.SetExStale(1, 10, time.Millisecond, time.Hour)
+		time.Sleep(2 * time.Millisecond)
+		_, _ = cache.Get(1)
+		<-started
+		cache.Delete(1)
+		close(release)
+		cache.Close() // waits for the refresh to finish.
+
+		require.NotContains(t, cache.Store().Items(), 1)
+	})
+
+	t.Run("does not overwrite values set while they are refreshed", func(t *testing.T) {
+		t.Parallel()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		refresher := func(_ context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+			close(started)
+			<-release
+			return stale + 1, 0, 0, nil
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 1))
+		require.NoError(t, err)
+
+		cache.SetExStale(1, 10, time.Millisecond, time.Hour)
+		time.Sleep(2 * time.Millisecond)
+		_, _ = cache.Get(1)
+		<-started
+		cache.Set(1, 20)
+		close(release)
+		cache.Close() // waits for the refresh to finish.
+
+		require.Equal(t, 20, cache.Store().Items()[1].Value)
+	})
+
+	t.Run("does not return values once they expire", func(t *testing.T) {
+		t.Parallel()
+
+		refresher := func(ctx context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+			<-ctx.Done()
+			return stale, 0, 0, ctx.Err()
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 1))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetExStale(1, 10, time.Millisecond, 5*time.Millisecond)
+		time.Sleep(6 * time.Millisecond)
+		_, ok := cache.Get(1)
+		require.False(t, ok)
+	})
+
+	t.Run("refreshes stale values ahead of them being read when actively expiring", func(t *testing.T) {
+		t.Parallel()
+
+		refresher := func(_ context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+			return stale + 1, time.Hour, 0, nil
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize,
+			memcache.WithRefresh(refresher, 1),
+			memcache.WithActiveExpiration[int, int](time.Millisecond),
+		)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetExStale(1, 10, time.Millisecond, time.Hour)
+		require.Eventually(t, func() bool {
+			item, _ := cache.Store().Get(1)
+			return item.Value == 11
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("close cancels and waits for running refreshes", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		var cancelled atomic.Bool
+		refresher := func(ctx context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+			close(started)
+			<-ctx.Done()
+			cancelled.Store(true)
+			return stale, 0, 0, ctx.Err()
+		}
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithRefresh(refresher, 1))
+		require.NoError(t, err)
+
+		cache.SetExStale(1, 10, 0, time.Hour)
+		_, _ = cache.Get(1)
+		<-started
+
+		cache.Close()
+		require.True(t, cancelled.Load())
+	})
+}
+
+func TestCache_Get(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns value of key & true when key exists in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+
+				got, ok := cache.Get(1)
+				require.True(t, ok)
+				require.Equal(t, 1, got)
+			})
+		}
+	})
+
+	t.Run("returns empty string & false when key does not exist in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				got, ok := cache.Get(1)
+				require.False(t, ok)
+				require.Equal(t, 0, got)
+			})
+		}
+	})
+
+	t.Run("returns empty string & false when expired key exists in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				expireAt := time.Now()
+				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+
+				got, ok := cache.Get(1)
+				require.False(t, ok)
+				require.Equal(t, 0, got)
+			})
+		}
+	})
+
+	t.Run("deletes expired keys when passive expiration is enabled", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize, memcache.WithPassiveExpiration[int, int]())
+				defer cache.Close()
+				store := cache.Store()
+
+				expireAt := time.Now()
+				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+
+				_, ok := cache.Get(1)
+				require.False(t, ok)
+
+				items := store.Items()
+				require.NotContains(t, items, 1)
+			})
+		}
+	})
+
+	t.Run("does not delete expired keys when passive expiration is disabled", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				expireAt := time.Now()
+				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+
+				_, ok := cache.Get(1)
+				require.False(t, ok)
+
+				items := store.Items()
+				require.Contains(t, items, 1)
+			})
+		}
+	})
+}
+
+func TestCache_GetWithVersion(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the value & version of key", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				value, version, ok := cache.GetWithVersion(1)
+				require.True(t, ok)
+				require.Equal(t, 10, value)
+				require.NotZero(t, version)
+
+				item, _ := cache.Store().Get(1)
+				require.Equal(t, item.Version, version)
+			})
+		}
+	})
+
+	t.Run("returns a greater version every time a key is set", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		_, first, _ := cache.GetWithVersion(1)
+		cache.Set(2, 20)
+		cache.SetEx(1, 10, time.Minute)
+		_, second, _ := cache.GetWithVersion(1)
+		_, other, _ := cache.GetWithVersion(2)
+
+		require.Greater(t, second, first)
+		require.NotEqual(t, other, first)
+		require.NotEqual(t, other, second)
+	})
+
+	t.Run("returns false when key does not exist or has expired", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(time.Now())
+		cache, _ := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Second)
+		clock.Advance(2 * time.Second)
+
+		for _, key := range []int{1, 2} {
+			value, version, ok := cache.GetWithVersion(key)
+			require.False(t, ok)
+			require.Zero(t, value)
+			require.Zero(t, version)
+		}
+	})
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets the value of key if it still has version", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.SetEx(1, 10, time.Minute)
+				_, version, _ := cache.GetWithVersion(1)
+
+				require.True(t, cache.CompareAndSwap(1, version, 11))
+				value, swapped, ok := cache.GetWithVersion(1)
+				require.True(t, ok)
+				require.Equal(t, 11, value)
+				require.Greater(t, swapped, version)
+
+				ttl, _ := cache.TTL(1)
+				require.Nil(t, ttl)
+			})
+		}
+	})
+
+	t.Run("does not set the value of key if it has been set since", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				_, version, _ := cache.GetWithVersion(1)
+				cache.Set(1, 12)
+
+				require.False(t, cache.CompareAndSwap(1, version, 11))
+				value, _ := cache.Get(1)
+				require.Equal(t, 12, value)
+			})
+		}
+	})
+
+	t.Run("does not set keys which do not exist, have been deleted or have expired", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(time.Now())
+		cache, _ := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		defer cache.Close()
+
+		require.False(t, cache.CompareAndSwap(1, 0, 10))
+
+		cache.Set(2, 20)
+		_, version, _ := cache.GetWithVersion(2)
+		cache.Delete(2)
+		require.False(t, cache.CompareAndSwap(2, version, 21))
+		cache.Set(2, 20)
+		require.False(t, cache.CompareAndSwap(2, version, 21))
+
+		cache.SetEx(3, 30, time.Second)
+		_, version, _ = cache.GetWithVersion(3)
+		clock.Advance(2 * time.Second)
+		require.False(t, cache.CompareAndSwap(3, version, 31))
+
+		require.Equal(t, uint64(3), cache.Stats().Sets, "failed swaps were counted as sets")
+	})
+
+	t.Run("deletes key if value can never fit in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithWeigher(func(_ int, value int) int64 {
+			return int64(value)
+		}))
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		_, version, _ := cache.GetWithVersion(1)
+		require.False(t, cache.CompareAndSwap(1, version+1, cacheSize+1))
+		require.Equal(t, 1, cache.Size())
+
+		require.False(t, cache.CompareAndSwap(1, version, cacheSize+1))
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("swaps atomically with concurrent writes", func(t *testing.T) {
+		t.Parallel()
+
+		newCaches := map[string]func() (*memcache.Cache[int, int], error){
+			"sharded": func() (*memcache.Cache[int, int], error) {
+				return memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithShards[int, int](4))
+			},
+		}
+		for policy, newCache := range policies {
+			newCache := newCache
+			newCaches[policy] = func() (*memcache.Cache[int, int], error) { return newCache(cacheSize) }
+		}
+
+		for name, newCache := range newCaches {
+			newCache := newCache
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := newCache()
+				require.NoError(t, err)
+				defer cache.Close()
+				cache.Set(0, 0)
+
+				const writers, increments = 8, 100
+				var wg sync.WaitGroup
+				for i := 1; i <= writers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						for n := 0; n < increments; {
+							value, version, _ := cache.GetWithVersion(0)
+							if cache.CompareAndSwap(0, version, value+1) {
+								n++
+							}
+							cache.Set(i, n)
+						}
+					}(i)
+				}
+				wg.Wait()
+
+				value, ok := cache.Get(0)
+				require.True(t, ok)
+				require.Equal(t, writers*increments, value)
+			})
+		}
+	})
+
+	t.Run("swaps values of stores which do not support compare-and-swap", func(t *testing.T) {
+		t.Parallel()
+
+		policy := memcache.AllKeysLRUPolicy[int, int]()
+		newStore := policy.NewStore
+		policy.NewStore = func(capacity int, onRemove data.RemoveFunc[int, int], clock data.Clock) memcache.Store[int, int] {
+			return struct{ memcache.Store[int, int] }{newStore(capacity, onRemove, clock)}
+		}
+		cache, err := memcache.Open(policy)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		_, version, _ := cache.GetWithVersion(1)
+		require.True(t, cache.CompareAndSwap(1, version, 11))
+		require.False(t, cache.CompareAndSwap(1, version, 12))
+		value, _ := cache.Get(1)
+		require.Equal(t, 11, value)
+	})
+
+	t.Run("journals swapped values", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncAlways, 1<<20))
+		require.NoError(t, err)
+		cache.Set(1, 10)
+		_, version, _ := cache.GetWithVersion(1)
+		require.True(t, cache.CompareAndSwap(1, version, 11))
+		cache.Close()
+
+		replayed, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncAlways, 1<<20))
+		require.NoError(t, err)
+		defer replayed.Close()
+		value, _ := replayed.Get(1)
+		require.Equal(t, 11, value)
+	})
+}
+
+func TestCache_CompareAndSwapEx(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets the value of key to expire after ttl if it still has version", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				clock := memcachetest.NewClock(time.Now())
+				cache, _ := newCache(cacheSize, memcache.WithClock[int, int](clock))
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				_, version, _ := cache.GetWithVersion(1)
+
+				require.True(t, cache.CompareAndSwapEx(1, version, 11, time.Minute))
+				require.False(t, cache.CompareAndSwapEx(1, version, 12, time.Minute))
+				value, _ := cache.Get(1)
+				require.Equal(t, 11, value)
+				ttl, _ := cache.TTL(1)
+				require.Equal(t, time.Minute, *ttl)
+			})
+		}
+	})
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns existing values without loading them", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		value, err := cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			t.Fatal("loader should not be called")
+			return 0, 0, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 10, value)
+	})
+
+	t.Run("loads and sets missing values", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := newCache(cacheSize)
+				require.NoError(t, err)
+				defer cache.Close()
+
+				value, err := cache.GetOrLoad(context.Background(), 1, func(_ context.Context, key int) (int, time.Duration, error) {
+					return key * 10, time.Minute, nil
+				})
+				require.NoError(t, err)
+				require.Equal(t, 10, value)
+
+				value, ok := cache.Get(1)
+				require.True(t, ok)
+				require.Equal(t, 10, value)
+				ttl, _ := cache.TTL(1)
+				require.NotNil(t, ttl)
+				require.Greater(t, *ttl, 59*time.Second)
+			})
+		}
+	})
+
+	t.Run("sets loaded values without an expiry when the ttl is not greater than 0", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		_, err = cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			return 10, 0, nil
+		})
+		require.NoError(t, err)
+
+		ttl, ok := cache.TTL(1)
+		require.True(t, ok)
+		require.Nil(t, ttl)
+	})
+
+	t.Run("reloads expired values", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+
+		value, err := cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			return 11, 0, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 11, value)
+	})
+
+	t.Run("panics if loader panics and loads again on the next call", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		require.Panics(t, func() {
+			_, _ = cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+				panic("loader panicked")
+			})
+		})
+		_, ok := cache.Get(1)
+		require.False(t, ok)
+
+		value, err := cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			return 10, 0, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 10, value)
+	})
+
+	t.Run("coalesces concurrent loads of the same key", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		var mu sync.Mutex
+		calls := 0
+		release := make(chan struct{})
+		loader := func(context.Context, int) (int, time.Duration, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			<-release
+			return 10, 0, nil
+		}
+
+		const callers = 10
+		var wg sync.WaitGroup
+		values := make([]int, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values[i], _ = cache.GetOrLoad(context.Background(), 1, loader)
+			}(i)
+		}
+		time.Sleep(10 * time.Millisecond) // let every caller join the load.
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, 1, calls)
+		for _, value := range values {
+			require.Equal(t, 10, value)
+		}
+	})
+
+	t.Run("returns errors to every caller without setting a value", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		errLoad := errors.New("load failed")
+		release := make(chan struct{})
+		loader := func(context.Context, int) (int, time.Duration, error) {
+			<-release
+			return 0, 0, errLoad
+		}
+
+		const callers = 3
+		errs := make(chan error, callers)
+		for i := 0; i < callers; i++ {
+			go func() {
+				_, err := cache.GetOrLoad(context.Background(), 1, loader)
+				errs <- err
+			}()
+		}
+		time.Sleep(10 * time.Millisecond) // let every caller join the load.
+		close(release)
+
+		for i := 0; i < callers; i++ {
+			require.ErrorIs(t, <-errs, errLoad)
+		}
+		_, ok := cache.Get(1)
+		require.False(t, ok)
+	})
+
+	t.Run("returns the error of a done context", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = cache.GetOrLoad(ctx, 1, func(ctx context.Context, _ int) (int, time.Duration, error) {
+			<-ctx.Done()
+			return 0, 0, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("returns an error if the loader is nil", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		_, err = cache.GetOrLoad(context.Background(), 1, nil)
+		require.ErrorIs(t, err, memcache.ErrInvalidLoader)
+	})
+}
+
+func TestCache_earlyExpiration(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expireAt := now.Add(10 * time.Second)
+	item := data.Item[int, int]{Value: 10, ExpireAt: &expireAt, LoadDuration: time.Second}
+
+	// e is the random value for which the exponential factor used by XFetch,
+	// -ln(1-random), is 1.
+	e := 1 - 1/math.E
+
+	t.Run("reports values missing once the scaled load duration reaches their expiry", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(expireAt.Add(-2*time.Second - time.Millisecond))
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithEarlyExpiration[int, int](2), memcache.WithClock[int, int](clock), memcache.WithRandom[int, int](func() float64 { return e }))
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Store().Add(1, item)
+
+		_, ok := cache.Get(1)
+		require.True(t, ok)
+
+		clock.Advance(time.Millisecond)
+		_, ok = cache.Get(1)
+		require.False(t, ok)
+
+		_, ok = cache.Store().Get(1)
+		require.True(t, ok, "early expiration should not delete the value")
+	})
+
+	t.Run("reports values missing earlier for larger random factors", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(expireAt.Add(-5 * time.Second))
+		random := 0.0
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithEarlyExpiration[int, int](1), memcache.WithClock[int, int](clock), memcache.WithRandom[int, int](func() float64 { return random }))
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Store().Add(1, item)
+
+		_, ok := cache.Get(1)
+		require.True(t, ok)
+
+		random = 1 - math.Exp(-6)
+		_, ok = cache.Get(1)
+		require.False(t, ok)
+	})
+
+	t.Run("does not report values missing early without the option", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(expireAt.Add(-time.Millisecond))
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithRandom[int, int](func() float64 { return e }))
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Store().Add(1, item)
+
+		_, ok := cache.Get(1)
+		require.True(t, ok)
+	})
+
+	t.Run("does not report values missing early without a load duration", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(expireAt.Add(-time.Millisecond))
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithEarlyExpiration[int, int](1), memcache.WithClock[int, int](clock), memcache.WithRandom[int, int](func() float64 { return e }))
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Store().Add(1, data.Item[int, int]{Value: 10, ExpireAt: &expireAt})
+
+		_, ok := cache.Get(1)
+		require.True(t, ok)
+	})
+
+	t.Run("records how long values loaded via GetOrLoad took to load", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithEarlyExpiration[int, int](1), memcache.WithClock[int, int](clock))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		_, err = cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			clock.Advance(3 * time.Second)
+			return 10, time.Minute, nil
+		})
+		require.NoError(t, err)
+
+		item, ok := cache.Store().Get(1)
+		require.True(t, ok)
+		require.Equal(t, 3*time.Second, item.LoadDuration)
+		require.Equal(t, now.Add(3*time.Second+time.Minute), *item.ExpireAt)
+	})
+}
+
+func TestCache_Contains(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports whether keys exist and have not expired without counting lookups", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				expireAt := time.Now().Add(-time.Minute)
+				cache.Store().Add(2, data.Item[int, int]{Value: 20, ExpireAt: &expireAt})
+
+				require.True(t, cache.Contains(1))
+				require.False(t, cache.Contains(2))
+				require.False(t, cache.Contains(3))
+				stats := cache.Stats()
+				require.Zero(t, stats.Hits)
+				require.Zero(t, stats.Misses)
+			})
+		}
+	})
+
+	t.Run("does not count as an access of the key when evicting", func(t *testing.T) {
+		t.Parallel()
+
+		for name, open := range map[string]func(int, ...memcache.Option[int, int]) (*memcache.Cache[int, int], error){
+			allkeyslru.PolicyName: memcache.OpenAllKeysLRUCache[int, int],
+			arc.PolicyName:        memcache.OpenARCCache[int, int],
+			sieve.PolicyName:      memcache.OpenSIEVECache[int, int],
+			s3fifo.PolicyName:     memcache.OpenS3FIFOCache[int, int],
+		} {
+			open := open
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := open(2)
+				require.NoError(t, err)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				cache.Set(2, 20)
+				require.True(t, cache.Contains(1))
+				cache.Set(3, 30)
+
+				require.ElementsMatch(t, []int{2, 3}, cache.Keys())
+			})
+		}
+	})
+
+	t.Run("looks up keys in stores which do not support peeking", func(t *testing.T) {
+		t.Parallel()
+
+		policy := memcache.AllKeysLRUPolicy[int, int]()
+		newStore := policy.NewStore
+		policy.NewStore = func(capacity int, onRemove data.RemoveFunc[int, int], clock data.Clock) memcache.Store[int, int] {
+			return struct{ memcache.Store[int, int] }{newStore(capacity, onRemove, clock)}
+		}
+		cache, err := memcache.Open(policy, memcache.WithShards[int, int](2))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		require.True(t, cache.Contains(1))
+		require.False(t, cache.Contains(2))
+	})
+}
+
+func TestCache_TTL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns remaining ttl of expiring item", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				setTTL := 1 * time.Minute
+				expireAt := time.Now().Add(setTTL)
+				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+
+				ttl, ok := cache.TTL(1)
+				require.True(t, ok)
+				require.Greater(t, *ttl, setTTL-1*time.Second)
+			})
+		}
+	})
+
+	t.Run("returns zero ttl for expired item", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				setTTL := -1 * time.Minute
+				expireAt := time.Now().Add(setTTL)
+				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+
+				ttl, ok := cache.TTL(1)
+				require.True(t, ok)
+				require.Equal(t, time.Duration(0), *ttl)
+			})
+		}
+	})
+
+	t.Run("returns nil ttl for non-expiring item", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+
+				ttl, ok := cache.TTL(1)
+				require.True(t, ok)
+				require.Nil(t, ttl)
+			})
+		}
+	})
+
+	t.Run("returns nil ttl and false if key does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				ttl, ok := cache.TTL(1)
+				require.False(t, ok)
+				require.Nil(t, ttl)
+			})
+		}
+	})
+}
+
+func TestCache_Delete(t *testing.T) {
+	t.Parallel()
+
+	t.Run("successfully deletes key from cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+
+				cache.Delete(1)
+				items := store.Items()
+				require.NotContains(t, items, 1)
+			})
+		}
+	})
+
+	t.Run("successfully deletes keys from cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+				store.Add(2, data.Item[int, int]{Value: 2})
+
+				cache.Delete(1, 2)
+				items := store.Items()
+				require.NotContains(t, items, 1)
+				require.NotContains(t, items, 2)
+			})
+		}
+	})
+
+	t.Run("does not panic when deleting keys that do not exist in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				c, _ := newCache(cacheSize)
+
+				require.NotPanics(t, func() {
+					c.Delete(1, 2)
+				})
+			})
+		}
+	})
+}
+
+func TestCache_CompareAndDelete(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deletes key only if it still has version", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				_, version, _ := cache.GetWithVersion(1)
+				cache.Set(1, 11)
+				require.False(t, cache.CompareAndDelete(1, version))
+				require.Equal(t, 1, cache.Size())
+
+				_, version, _ = cache.GetWithVersion(1)
+				require.True(t, cache.CompareAndDelete(1, version))
+				require.Zero(t, cache.Size())
+				require.False(t, cache.CompareAndDelete(1, version))
+			})
+		}
+	})
+
+	t.Run("does not delete expired keys", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(time.Now())
+		cache, _ := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Second)
+		_, version, _ := cache.GetWithVersion(1)
+		clock.Advance(2 * time.Second)
+
+		require.False(t, cache.CompareAndDelete(1, version))
+		require.Zero(t, cache.Stats().Deletes)
+	})
+
+	t.Run("calls the delete callback", func(t *testing.T) {
+		t.Parallel()
+
+		var deleted []int
+		cache, _ := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithOnDelete(func(key int, _ int, _ memcache.Reason) {
+			deleted = append(deleted, key)
+		}))
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		_, version, _ := cache.GetWithVersion(1)
+		require.True(t, cache.CompareAndDelete(1, version))
+		require.Equal(t, []int{1}, deleted)
+		require.Equal(t, uint64(1), cache.Stats().Deletes)
+	})
+}
+
+func TestCache_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("successfully flushes the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+				store.Add(2, data.Item[int, int]{Value: 2})
+				store.Add(3, data.Item[int, int]{Value: 3})
+
+				cache.Flush()
 				items := store.Items()
-				require.Contains(t, items, 1)
-				require.Equal(t, 1, items[1].Value)
+				require.Empty(t, items)
 			})
 		}
 	})
 }
 
-func TestCache_SetEx(t *testing.T) {
+func TestCache_Size(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the size of the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+				store.Add(2, data.Item[int, int]{Value: 2})
+				store.Add(3, data.Item[int, int]{Value: 3})
+
+				size := cache.Size()
+				require.Equal(t, 3, size)
+			})
+		}
+	})
+}
+
+func TestCache_Weight(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the number of items without a weigher", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				cache.Set(2, 20)
+
+				require.Equal(t, int64(2), cache.Weight())
+			})
+		}
+	})
+
+	t.Run("returns the total weight of items with a weigher", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize, memcache.WithWeigher(func(_ int, value int) int64 {
+					return int64(value)
+				}))
+				defer cache.Close()
+
+				cache.Set(1, 10)
+				cache.Set(2, 20)
+				cache.Set(3, 0)
+
+				require.Equal(t, int64(31), cache.Weight())
+			})
+		}
+	})
+}
+
+func TestCache_PolicyName(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the name of the policy the cache was opened with", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			policy, newCache := policy, newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, err := newCache(cacheSize)
+				require.NoError(t, err)
+				defer cache.Close()
+
+				require.Equal(t, policy, cache.PolicyName())
+			})
+		}
+	})
+}
+
+func TestCache_RandomKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a random key in the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+				store.Add(2, data.Item[int, int]{Value: 1})
+				store.Add(3, data.Item[int, int]{Value: 1})
+
+				keysFound := make(map[int]struct{}, 3)
+				for i := 0; i < 100; i++ {
+					key, ok := store.RandomKey()
+					require.True(t, ok)
+					keysFound[key] = struct{}{}
+				}
+				require.Len(t, keysFound, 3)
+				require.Equal(t, keysFound, map[int]struct{}{1: {}, 2: {}, 3: {}})
+			})
+		}
+	})
+}
+
+func TestCache_Keys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the keys of the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				defer cache.Close()
+				store := cache.Store()
+
+				store.Add(1, data.Item[int, int]{Value: 1})
+				store.Add(2, data.Item[int, int]{Value: 1})
+				store.Add(3, data.Item[int, int]{Value: 1})
+
+				keys := cache.Keys()
+				require.Contains(t, keys, 1)
+				require.Contains(t, keys, 2)
+				require.Contains(t, keys, 3)
+			})
+		}
+	})
+}
+
+func TestCache_Close(t *testing.T) {
 	t.Parallel()
 
-	t.Run("successfully stores value in the cache with a TTL", func(t *testing.T) {
+	t.Run("successfully closes the cache", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				cache.Close()
+				require.True(t, cache.Closed())
+			})
+		}
+	})
+
+	t.Run("subsequent calls to close do not panic", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := newCache(cacheSize)
+				cache.Close()
+				require.NotPanics(t, func() { cache.Close() })
+			})
+		}
+	})
+
+	t.Run("cache with no goroutines is garbage collected after releasing without closing", func(t *testing.T) {
+		t.Parallel()
+
+		for policy, newCache := range policies {
+			newCache := newCache
+			t.Run(policy, func(t *testing.T) {
+				t.Parallel()
+
+				ch := make(chan struct{})
+
+				cache := func() *memcache.Cache[int, int] {
+					c, _ := newCache(cacheSize)
+					runtime.SetFinalizer(c, func(_ *memcache.Cache[int, int]) {
+						close(ch)
+					})
+					return c
+				}()
+
+				cache.Flush() // use the cache once
+				cache = nil   // release the cache
+				runtime.GC()  // explicitly run garbage collection
+
+				select {
+				case <-time.After(500 * time.Millisecond):
+					t.Fatal("cache was not garbage collected")
+				case <-ch:
+					// cache was garbage collected
+				}
+			})
+		}
+	})
+
+	t.Run("cache with goroutines is garbage collected after releasing & closing", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
@@ -483,46 +3087,222 @@ func TestCache_SetEx(t *testing.T) {
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+				ch := make(chan struct{})
 
-				cache.SetEx(1, 1, 1*time.Minute)
+				cache := func() *memcache.Cache[int, int] {
+					interval := 50 * time.Millisecond
+					c, _ := newCache(cacheSize, memcache.WithActiveExpiration[int, int](interval))
+					runtime.SetFinalizer(c, func(_ *memcache.Cache[int, int]) {
+						close(ch)
+					})
+					return c
+				}()
 
-				items := store.Items()
-				require.Contains(t, items, 1)
-				require.Equal(t, 1, items[1].Value)
-				require.Greater(t, *items[1].ExpireAt, time.Now())
+				cache.Flush() // use the cache once
+				cache.Close() // close the cache
+				cache = nil   // release the cache
+				runtime.GC()  // explicitly run garbage collection
+
+				select {
+				case <-time.After(500 * time.Millisecond):
+					t.Fatal("cache was not garbage collected")
+				case <-ch:
+					// cache was garbage collected
+				}
+			})
+		}
+	})
+
+	t.Run("snapshots & journals refreshes which finish while closing", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		for name, persist := range map[string]memcache.Option[int, int]{
+			"snapshot": memcache.WithPeriodicSnapshot[int, int](filepath.Join(dir, "cache.snapshot"), time.Hour),
+			"journal":  memcache.WithJournal[int, int](filepath.Join(dir, "cache.aof"), memcache.FsyncNever, 1<<20),
+		} {
+			persist := persist
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				started := make(chan struct{})
+				refresher := func(ctx context.Context, _ int, stale int) (int, time.Duration, time.Duration, error) {
+					close(started)
+					<-ctx.Done()
+					return stale + 1, 0, 0, nil
+				}
+				cache, err := memcache.OpenAllKeysLRUCache(cacheSize, persist, memcache.WithRefresh(refresher, 1))
+				require.NoError(t, err)
+				cache.SetExStale(1, 10, time.Nanosecond, time.Hour)
+				time.Sleep(time.Millisecond)
+				_, _ = cache.Get(1)
+				<-started
+				cache.Close()
+
+				restored, err := memcache.OpenAllKeysLRUCache(cacheSize, persist)
+				require.NoError(t, err)
+				defer restored.Close()
+				value, ok := restored.Get(1)
+				require.True(t, ok)
+				require.Equal(t, 11, value)
 			})
 		}
 	})
 }
 
-func TestCache_Get(t *testing.T) {
+func TestCache_callbacks(t *testing.T) {
 	t.Parallel()
 
-	t.Run("returns value of key & true when key exists in the cache", func(t *testing.T) {
+	// removal records the arguments a callback was called with.
+	type removal struct {
+		key    int
+		value  int
+		reason memcache.Reason
+	}
+
+	// record returns a callback which appends to removals.
+	record := func(mu *sync.Mutex, removals *[]removal) func(key int, value int, reason memcache.Reason) {
+		return func(key int, value int, reason memcache.Reason) {
+			mu.Lock()
+			defer mu.Unlock()
+			*removals = append(*removals, removal{key: key, value: value, reason: reason})
+		}
+	}
+
+	t.Run("notifies of every evicted value", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
+			if policy == noevict.PolicyName {
+				continue
+			}
 			newCache := newCache
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				var mu sync.Mutex
+				var removals []removal
+				cache, err := newCache(10, memcache.WithOnEvict(record(&mu, &removals)))
+				require.NoError(t, err)
 				defer cache.Close()
-				store := cache.Store()
 
-				store.Add(1, data.Item[int, int]{Value: 1})
+				adds := 30
+				for i := 0; i < adds; i++ {
+					cache.Set(i, i*10)
+				}
 
-				got, ok := cache.Get(1)
-				require.True(t, ok)
-				require.Equal(t, 1, got)
+				mu.Lock()
+				defer mu.Unlock()
+				require.Equal(t, adds, cache.Size()+len(removals))
+				for _, r := range removals {
+					require.Equal(t, r.key*10, r.value)
+					require.Equal(t, memcache.ReasonEvicted, r.reason)
+				}
 			})
 		}
 	})
 
-	t.Run("returns empty string & false when key does not exist in the cache", func(t *testing.T) {
+	t.Run("notifies of evicted values from every shard", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var removals []removal
+		cache, err := memcache.OpenAllKeysLRUCache(8, memcache.WithShards[int, int](4), memcache.WithOnEvict(record(&mu, &removals)))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		for i := 0; i < 100; i++ {
+			cache.Set(i, i)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, removals, 100-cache.Size())
+	})
+
+	t.Run("notifies of values expired passively", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var removals []removal
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithPassiveExpiration[int, int](), memcache.WithOnExpire(record(&mu, &removals)))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		_, ok := cache.Get(1)
+		require.False(t, ok)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, []removal{{key: 1, value: 10, reason: memcache.ReasonExpired}}, removals)
+	})
+
+	t.Run("notifies of values expired actively", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var removals []removal
+		cache, err := memcache.OpenVolatileTTLCache(cacheSize, memcache.WithActiveExpiration[int, int](time.Millisecond), memcache.WithOnExpire(record(&mu, &removals)))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Millisecond)
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(removals) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, removal{key: 1, value: 10, reason: memcache.ReasonExpired}, removals[0])
+	})
+
+	t.Run("notifies of deleted and flushed values by whether they expired", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var removals []removal
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithOnDelete(record(&mu, &removals)), memcache.WithOnExpire(record(&mu, &removals)))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Millisecond)
+		cache.Set(3, 30)
+		time.Sleep(2 * time.Millisecond)
+
+		cache.Delete(1, 2, 4)
+		cache.Flush()
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, []removal{
+			{key: 1, value: 10, reason: memcache.ReasonDeleted},
+			{key: 2, value: 20, reason: memcache.ReasonExpired},
+			{key: 3, value: 30, reason: memcache.ReasonDeleted},
+		}, removals)
+	})
+
+	t.Run("notifies of values deleted by setting a value too heavy to store", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var removals []removal
+		cache, err := memcache.OpenAllKeysLRUCache(10, memcache.WithOnDelete(record(&mu, &removals)), memcache.WithWeigher(func(_ int, value int) int64 {
+			return int64(value)
+		}))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 5)
+		cache.Set(1, 11)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, []removal{{key: 1, value: 5, reason: memcache.ReasonDeleted}}, removals)
+	})
+
+	t.Run("callbacks may use the cache", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
@@ -530,17 +3310,144 @@ func TestCache_Get(t *testing.T) {
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				var cache *memcache.Cache[int, int]
+				callback := func(key int, _ int, _ memcache.Reason) {
+					_, _ = cache.Get(key)
+					cache.Set(-1, key)
+				}
+				cache, err := newCache(10, memcache.WithOnEvict(callback), memcache.WithOnDelete(callback))
+				require.NoError(t, err)
 				defer cache.Close()
 
-				got, ok := cache.Get(1)
-				require.False(t, ok)
-				require.Equal(t, 0, got)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for i := 0; i < 30; i++ {
+						cache.Set(i, i)
+					}
+					cache.Delete(29)
+					cache.Flush()
+				}()
+
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatal("cache deadlocked when its callback used the cache")
+				}
 			})
 		}
 	})
 
-	t.Run("returns empty string & false when expired key exists in the cache", func(t *testing.T) {
+	t.Run("runs callbacks asynchronously", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var removals []removal
+		onDelete := record(&mu, &removals)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithAsyncCallbacks[int, int](10), memcache.WithOnDelete(func(key int, value int, reason memcache.Reason) {
+			<-release
+			onDelete(key, value, reason)
+		}))
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			cache.Set(i, i*10)
+			cache.Delete(i) // would block if the callback ran synchronously.
+		}
+		close(release)
+		cache.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, removals, 5)
+		for i, r := range removals {
+			require.Equal(t, removal{key: i, value: i * 10, reason: memcache.ReasonDeleted}, r)
+		}
+	})
+}
+
+func TestCache_Stats(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("counts hits, misses and sets", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Minute)
+		_, _ = cache.Get(1)
+		_, _ = cache.Get(2)
+		_, _ = cache.Get(3)
+
+		stats := cache.Stats()
+		require.Equal(t, uint64(2), stats.Sets)
+		require.Equal(t, uint64(2), stats.Hits)
+		require.Equal(t, uint64(1), stats.Misses)
+		require.InDelta(t, 2.0/3.0, stats.HitRatio(), 0.0001)
+	})
+
+	t.Run("counts deletes of only the keys which existed", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Minute)
+		clock.Advance(2 * time.Minute)
+		cache.Delete(1, 2, 3)
+		cache.Delete(1)
+		require.Equal(t, uint64(1), cache.Stats().Deletes)
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("counts lookups of expired keys as misses", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Minute)
+		clock.Advance(2 * time.Minute)
+		_, _ = cache.Get(1)
+		_, _ = cache.Get(1)
+
+		stats := cache.Stats()
+		require.Equal(t, uint64(2), stats.Misses)
+		require.Equal(t, uint64(2), stats.ExpiredLookups)
+		require.Zero(t, stats.PassiveExpirations)
+	})
+
+	t.Run("counts passive expirations", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithPassiveExpiration[int, int]())
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.SetEx(1, 10, time.Minute)
+		clock.Advance(2 * time.Minute)
+		_, _ = cache.Get(1)
+		_, _ = cache.Get(1)
+
+		stats := cache.Stats()
+		require.Equal(t, uint64(1), stats.ExpiredLookups)
+		require.Equal(t, uint64(1), stats.PassiveExpirations)
+		require.Zero(t, stats.Deletes)
+	})
+
+	t.Run("counts active expirations", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
@@ -548,73 +3455,110 @@ func TestCache_Get(t *testing.T) {
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				clock := memcachetest.NewClock(now)
+				cache, err := newCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithActiveExpiration[int, int](time.Hour))
+				require.NoError(t, err)
 				defer cache.Close()
-				store := cache.Store()
 
-				expireAt := time.Now()
-				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+				cache.SetEx(1, 10, time.Minute)
+				cache.SetEx(2, 20, time.Minute)
+				cache.SetEx(3, 30, 2*time.Hour)
+				clock.Advance(time.Hour)
 
-				got, ok := cache.Get(1)
-				require.False(t, ok)
-				require.Equal(t, 0, got)
+				require.Eventually(t, func() bool {
+					return cache.Stats().ActiveExpirations == 2
+				}, time.Second, time.Millisecond)
+				require.Zero(t, cache.Stats().Deletes)
 			})
 		}
 	})
 
-	t.Run("deletes expired keys when passive expiration is enabled", func(t *testing.T) {
+	t.Run("counts evictions", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
+			if policy == noevict.PolicyName {
+				continue
+			}
 			newCache := newCache
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize, memcache.WithPassiveExpiration[int, int]())
-				defer cache.Close()
-				store := cache.Store()
-
-				expireAt := time.Now()
-				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+				for _, shards := range []int{1, 4} {
+					cache, err := newCache(cacheSize, memcache.WithShards[int, int](shards))
+					require.NoError(t, err)
+					defer cache.Close()
 
-				_, ok := cache.Get(1)
-				require.False(t, ok)
+					for i := 0; i < cacheSize*3; i++ {
+						cache.Set(i, i)
+					}
 
-				items := store.Items()
-				require.NotContains(t, items, 1)
+					stats := cache.Stats()
+					require.Positive(t, stats.Evictions)
+					require.Equal(t, uint64(cacheSize*3), stats.Evictions+uint64(cache.Size()))
+				}
 			})
 		}
 	})
 
-	t.Run("does not delete expired keys when passive expiration is disabled", func(t *testing.T) {
+	t.Run("counts load successes and failures", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		_, err = cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			return 10, 0, nil
+		})
+		require.NoError(t, err)
+		_, err = cache.GetOrLoad(context.Background(), 1, func(context.Context, int) (int, time.Duration, error) {
+			return 0, 0, errors.New("unreachable")
+		})
+		require.NoError(t, err)
+		_, err = cache.GetOrLoad(context.Background(), 2, func(context.Context, int) (int, time.Duration, error) {
+			return 0, 0, errors.New("failed")
+		})
+		require.Error(t, err)
 
-				expireAt := time.Now()
-				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		stats := cache.Stats()
+		require.Equal(t, uint64(1), stats.LoadSuccesses)
+		require.Equal(t, uint64(1), stats.LoadFailures)
+		require.Equal(t, uint64(1), stats.Sets)
+		require.Equal(t, uint64(1), stats.Hits)
+		require.Equal(t, uint64(2), stats.Misses)
+	})
 
-				_, ok := cache.Get(1)
-				require.False(t, ok)
+	t.Run("reset stats zeroes every counter", func(t *testing.T) {
+		t.Parallel()
 
-				items := store.Items()
-				require.Contains(t, items, 1)
-			})
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](2)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		for i := 0; i < 4; i++ {
+			cache.Set(i, i)
+			_, _ = cache.Get(i)
 		}
+		_, _ = cache.Get(0)
+		cache.Delete(3)
+		require.NotZero(t, cache.Stats())
+
+		cache.ResetStats()
+		require.Zero(t, cache.Stats())
+
+		cache.Set(4, 4)
+		cache.Set(5, 5)
+		require.Equal(t, memcache.Stats{Sets: 2, Evictions: 1}, cache.Stats())
 	})
 }
 
-func TestCache_TTL(t *testing.T) {
+func TestCache_Snapshot(t *testing.T) {
 	t.Parallel()
 
-	t.Run("returns remaining ttl of expiring item", func(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("restores the values and expiry of items", func(t *testing.T) {
 		t.Parallel()
 
 		for policy, newCache := range policies {
@@ -622,358 +3566,608 @@ func TestCache_TTL(t *testing.T) {
 			t.Run(policy, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				clock := memcachetest.NewClock(now)
+				cache, err := newCache(cacheSize, memcache.WithClock[int, int](clock))
+				require.NoError(t, err)
 				defer cache.Close()
-				store := cache.Store()
 
-				setTTL := 1 * time.Minute
-				expireAt := time.Now().Add(setTTL)
-				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+				cache.Set(1, 10)
+				cache.SetEx(2, 20, time.Minute)
+				cache.SetExStale(3, 30, time.Minute, time.Hour)
+				var buf bytes.Buffer
+				require.NoError(t, cache.Snapshot(&buf))
 
-				ttl, ok := cache.TTL(1)
-				require.True(t, ok)
-				require.Greater(t, *ttl, setTTL-1*time.Second)
+				restored, err := newCache(cacheSize, memcache.WithClock[int, int](clock))
+				require.NoError(t, err)
+				defer restored.Close()
+				require.NoError(t, restored.Restore(&buf))
+
+				requireItemsEqual(t, cache.Store().Items(), restored.Store().Items())
 			})
 		}
 	})
 
-	t.Run("returns zero ttl for expired item", func(t *testing.T) {
+	t.Run("skips items which have expired", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		require.NoError(t, err)
+		defer cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		cache.SetEx(1, 10, time.Minute)
+		cache.SetEx(2, 20, time.Hour)
+		cache.SetEx(3, 30, 2*time.Hour)
+		clock.Advance(2 * time.Minute)
+		var buf bytes.Buffer
+		require.NoError(t, cache.Snapshot(&buf))
 
-				setTTL := -1 * time.Minute
-				expireAt := time.Now().Add(setTTL)
-				store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		clock.Advance(time.Hour)
+		restored, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock))
+		require.NoError(t, err)
+		defer restored.Close()
+		require.NoError(t, restored.Restore(&buf))
 
-				ttl, ok := cache.TTL(1)
-				require.True(t, ok)
-				require.Equal(t, time.Duration(0), *ttl)
-			})
+		require.Equal(t, []int{3}, restored.Keys())
+	})
+
+	t.Run("keeps existing keys which are not restored", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		var buf bytes.Buffer
+		require.NoError(t, cache.Snapshot(&buf))
+
+		cache.Set(1, 11)
+		cache.Set(2, 20)
+		require.NoError(t, cache.Restore(&buf))
+
+		value, _ := cache.Get(1)
+		require.Equal(t, 10, value)
+		value, _ = cache.Get(2)
+		require.Equal(t, 20, value)
+	})
+
+	t.Run("preserves the order of lru policies", func(t *testing.T) {
+		t.Parallel()
+
+		for _, shards := range []int{1, 2} {
+			cache, err := memcache.OpenAllKeysLRUCache(4, memcache.WithShards[int, int](shards))
+			require.NoError(t, err)
+			defer cache.Close()
+
+			for i := 0; i < 4; i++ {
+				cache.Set(i, i)
+			}
+			_, _ = cache.Get(0)
+			_, _ = cache.Get(1) // least recently used is now 2, then 3.
+			var buf bytes.Buffer
+			require.NoError(t, cache.Snapshot(&buf))
+
+			restored, err := memcache.OpenAllKeysLRUCache(4, memcache.WithShards[int, int](shards))
+			require.NoError(t, err)
+			defer restored.Close()
+			require.NoError(t, restored.Restore(&buf))
+
+			want, _ := cache.Store().(memcache.Ranker[int])
+			got, _ := restored.Store().(memcache.Ranker[int])
+			require.Equal(t, want.Ranked(), got.Ranked())
 		}
 	})
 
-	t.Run("returns nil ttl for non-expiring item", func(t *testing.T) {
+	t.Run("preserves the frequencies of lfu policies", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		for _, open := range []func(int, ...memcache.Option[int, int]) (*memcache.Cache[int, int], error){
+			memcache.OpenAllKeysLFUCache[int, int],
+			memcache.OpenVolatileLFUCache[int, int],
+		} {
+			cache, err := open(3)
+			require.NoError(t, err)
+			defer cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+			for i := 0; i < 3; i++ {
+				cache.SetEx(i, i, time.Hour)
+				for j := 0; j < 3-i; j++ {
+					_, _ = cache.Get(i)
+				}
+			}
+			var buf bytes.Buffer
+			require.NoError(t, cache.Snapshot(&buf))
 
-				store.Add(1, data.Item[int, int]{Value: 1})
+			restored, err := open(3)
+			require.NoError(t, err)
+			defer restored.Close()
+			require.NoError(t, restored.Restore(&buf))
 
-				ttl, ok := cache.TTL(1)
-				require.True(t, ok)
-				require.Nil(t, ttl)
-			})
+			tracker, _ := restored.Store().(memcache.FrequencyTracker[int])
+			require.Equal(t, 4, tracker.Frequency(0))
+			require.Equal(t, 3, tracker.Frequency(1))
+			require.Equal(t, 2, tracker.Frequency(2))
 		}
 	})
 
-	t.Run("returns nil ttl and false if key does not exist", func(t *testing.T) {
+	t.Run("returns an error if values cannot be encoded", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, any](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, struct{ unregistered int }{})
+		require.Error(t, cache.Snapshot(io.Discard))
+	})
+}
+
+// requireItemsEqual requires items to be equal, comparing times by the instant
+// they represent rather than by their location or monotonic clock reading, and
+// ignoring versions which are not preserved by snapshots.
+func requireItemsEqual(t *testing.T, want, got map[int]data.Item[int, int]) {
+	t.Helper()
+
+	require.Len(t, got, len(want))
+	for key, item := range want {
+		other, ok := got[key]
+		require.True(t, ok, "missing key %d", key)
+		for _, times := range [][2]*time.Time{{item.ExpireAt, other.ExpireAt}, {item.StaleAt, other.StaleAt}} {
+			require.Equal(t, times[0] == nil, times[1] == nil, "key %d", key)
+			if times[0] != nil {
+				require.True(t, times[0].Equal(*times[1]), "key %d: %v != %v", key, times[0], times[1])
+			}
+		}
+		item.ExpireAt, item.StaleAt, other.ExpireAt, other.StaleAt = nil, nil, nil, nil
+		item.Version, other.Version = 0, 0
+		require.Equal(t, item, other)
+	}
+}
+
+func TestCache_Restore(t *testing.T) {
+	t.Parallel()
+
+	snapshot := func(t *testing.T, options ...memcache.Option[int, int]) []byte {
+		t.Helper()
+
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, options...)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Hour)
+		var buf bytes.Buffer
+		require.NoError(t, cache.Snapshot(&buf))
+
+		return buf.Bytes()
+	}
+
+	t.Run("restores snapshots written with every codec", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
+		for name, c := range map[string]codec.Codec[int, int]{
+			"gob":  codec.Gob[int, int](),
+			"json": codec.JSON[int, int](),
+		} {
+			c := c
+			t.Run(name, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
+				cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithCodec(c))
+				require.NoError(t, err)
 				defer cache.Close()
+				require.NoError(t, cache.Restore(bytes.NewReader(snapshot(t, memcache.WithCodec(c)))))
 
-				ttl, ok := cache.TTL(1)
-				require.False(t, ok)
-				require.Nil(t, ttl)
+				value, ok := cache.Get(2)
+				require.True(t, ok)
+				require.Equal(t, 20, value)
 			})
 		}
-	})
-}
 
-func TestCache_Delete(t *testing.T) {
-	t.Parallel()
+		t.Run("raw", func(t *testing.T) {
+			t.Parallel()
 
-	t.Run("successfully deletes key from cache", func(t *testing.T) {
-		t.Parallel()
+			cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithCodec(codec.Raw[string, []byte]()))
+			require.NoError(t, err)
+			defer cache.Close()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+			cache.Set("key", []byte("value"))
+			var buf bytes.Buffer
+			require.NoError(t, cache.Snapshot(&buf))
+			require.Contains(t, buf.String(), "value")
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+			cache.Flush()
+			require.NoError(t, cache.Restore(&buf))
+			value, _ := cache.Get("key")
+			require.Equal(t, []byte("value"), value)
+		})
+	})
 
-				store.Add(1, data.Item[int, int]{Value: 1})
+	t.Run("returns an error if the reader is not a snapshot", func(t *testing.T) {
+		t.Parallel()
 
-				cache.Delete(1)
-				items := store.Items()
-				require.NotContains(t, items, 1)
-			})
-		}
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		err = cache.Restore(strings.NewReader("invalid"))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
 	})
 
-	t.Run("successfully deletes keys from cache", func(t *testing.T) {
+	t.Run("returns an error and restores nothing if the snapshot is truncated", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		b := snapshot(t)
+		err = cache.Restore(bytes.NewReader(b[:len(b)-1]))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Zero(t, cache.Size())
+	})
 
-				store.Add(1, data.Item[int, int]{Value: 1})
-				store.Add(2, data.Item[int, int]{Value: 2})
+	t.Run("returns an error and restores nothing if the snapshot is corrupt", func(t *testing.T) {
+		t.Parallel()
 
-				cache.Delete(1, 2)
-				items := store.Items()
-				require.NotContains(t, items, 1)
-				require.NotContains(t, items, 2)
-			})
-		}
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		b := snapshot(t)
+		b[len(b)-5]++ // the number of entries.
+		err = cache.Restore(bytes.NewReader(b))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
+		var checksumErr memcache.SnapshotChecksumError
+		require.ErrorAs(t, err, &checksumErr)
+		require.NotEqual(t, checksumErr.Expected, checksumErr.Checksum)
+		require.Zero(t, cache.Size())
 	})
 
-	t.Run("does not panic when deleting keys that do not exist in the cache", func(t *testing.T) {
+	t.Run("returns an error if the snapshot is of an unsupported version", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		b := snapshot(t)
+		b[4], b[5] = 0, 99
+		err = cache.Restore(bytes.NewReader(b))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
+		require.ErrorAs(t, err, &memcache.SnapshotVersionError{})
+		require.Equal(t, memcache.SnapshotVersionError{Version: 99, Supported: 1}, err)
+	})
 
-				c, _ := newCache(cacheSize)
+	t.Run("returns an error if the snapshot was written with another codec", func(t *testing.T) {
+		t.Parallel()
 
-				require.NotPanics(t, func() {
-					c.Delete(1, 2)
-				})
-			})
-		}
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](cacheSize)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		err = cache.Restore(bytes.NewReader(snapshot(t, memcache.WithCodec(codec.JSON[int, int]()))))
+		require.ErrorIs(t, err, memcache.ErrInvalidSnapshot)
+		require.Equal(t, memcache.SnapshotCodecError{Codec: "json", Expected: "gob"}, err)
 	})
 }
 
-func TestCache_Flush(t *testing.T) {
+func TestCache_periodicSnapshot(t *testing.T) {
 	t.Parallel()
 
-	t.Run("successfully flushes the cache", func(t *testing.T) {
-		t.Parallel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+	t.Run("writes a snapshot every interval", func(t *testing.T) {
+		t.Parallel()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "cache.snapshot")
+		clock := memcachetest.NewClock(now)
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithClock[int, int](clock), memcache.WithPeriodicSnapshot[int, int](path, time.Minute))
+		require.NoError(t, err)
+		defer cache.Close()
 
-				store.Add(1, data.Item[int, int]{Value: 1})
-				store.Add(2, data.Item[int, int]{Value: 2})
-				store.Add(3, data.Item[int, int]{Value: 3})
+		cache.Set(1, 10)
+		clock.Advance(time.Minute)
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, time.Second, time.Millisecond)
 
-				cache.Flush()
-				items := store.Items()
-				require.Empty(t, items)
-			})
-		}
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "temporary files were left behind")
 	})
-}
 
-func TestCache_Size(t *testing.T) {
-	t.Parallel()
-
-	t.Run("returns the size of the cache", func(t *testing.T) {
+	t.Run("writes a snapshot on close which is restored on open", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithPeriodicSnapshot[int, int](path, time.Hour))
+		require.NoError(t, err)
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Hour)
+		cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		restored, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithPeriodicSnapshot[int, int](path, time.Hour))
+		require.NoError(t, err)
+		defer restored.Close()
+		items := restored.Store().Items()
+		require.Len(t, items, 2)
+		require.Equal(t, 10, items[1].Value)
+		require.Equal(t, 20, items[2].Value)
+		require.True(t, cache.Store().Items()[2].ExpireAt.Equal(*items[2].ExpireAt))
+	})
 
-				store.Add(1, data.Item[int, int]{Value: 1})
-				store.Add(2, data.Item[int, int]{Value: 2})
-				store.Add(3, data.Item[int, int]{Value: 3})
+	t.Run("opens an empty cache if there is no snapshot at path", func(t *testing.T) {
+		t.Parallel()
 
-				size := cache.Size()
-				require.Equal(t, 3, size)
-			})
-		}
+		path := filepath.Join(t.TempDir(), "cache.snapshot")
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithPeriodicSnapshot[int, int](path, time.Hour))
+		require.NoError(t, err)
+		defer cache.Close()
+		require.Zero(t, cache.Size())
 	})
 }
 
-func TestCache_RandomKey(t *testing.T) {
+func TestCache_journal(t *testing.T) {
 	t.Parallel()
 
-	t.Run("returns a random key in the cache", func(t *testing.T) {
+	open := func(t *testing.T, path string, fsync memcache.FsyncPolicy, options ...memcache.Option[int, int]) *memcache.Cache[int, int] {
+		t.Helper()
+
+		options = append(options, memcache.WithJournal[int, int](path, fsync, 1<<20))
+		cache, err := memcache.OpenAllKeysLRUCache(cacheSize, options...)
+		require.NoError(t, err)
+		return cache
+	}
+
+	for name, fsync := range map[string]memcache.FsyncPolicy{
+		"always":       memcache.FsyncAlways,
+		"every second": memcache.FsyncEverySecond,
+		"never":        memcache.FsyncNever,
+	} {
+		name, fsync := name, fsync
+		t.Run("replays sets & deletes on open when fsync is "+name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "cache.aof")
+			cache := open(t, path, fsync)
+			cache.Set(1, 10)
+			cache.SetEx(2, 20, time.Hour)
+			cache.Set(3, 30)
+			cache.Set(1, 11)
+			cache.Delete(3)
+			cache.Close()
+
+			replayed := open(t, path, fsync)
+			defer replayed.Close()
+			items := replayed.Store().Items()
+			require.Len(t, items, 2)
+			require.Equal(t, 11, items[1].Value)
+			require.Equal(t, 20, items[2].Value)
+			require.True(t, cache.Store().Items()[2].ExpireAt.Equal(*items[2].ExpireAt))
+		})
+	}
+
+	t.Run("replays flushes on open", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncNever)
+		cache.Set(1, 10)
+		cache.Flush()
+		cache.Set(2, 20)
+		cache.Close()
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		replayed := open(t, path, memcache.FsyncNever)
+		defer replayed.Close()
+		require.Equal(t, []int{2}, replayed.Keys())
+	})
 
-				store.Add(1, data.Item[int, int]{Value: 1})
-				store.Add(2, data.Item[int, int]{Value: 1})
-				store.Add(3, data.Item[int, int]{Value: 1})
+	t.Run("does not replay items which have since expired", func(t *testing.T) {
+		t.Parallel()
 
-				keysFound := make(map[int]struct{}, 3)
-				for i := 0; i < 100; i++ {
-					key, ok := store.RandomKey()
-					require.True(t, ok)
-					keysFound[key] = struct{}{}
-				}
-				require.Len(t, keysFound, 3)
-				require.Equal(t, keysFound, map[int]struct{}{1: {}, 2: {}, 3: {}})
-			})
-		}
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock := memcachetest.NewClock(now)
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncNever, memcache.WithClock[int, int](clock))
+		cache.Set(1, 10)
+		cache.SetEx(2, 20, time.Minute)
+		cache.Close()
+
+		clock.Advance(time.Hour)
+		replayed := open(t, path, memcache.FsyncNever, memcache.WithClock[int, int](clock))
+		defer replayed.Close()
+		require.Equal(t, []int{1}, replayed.Keys())
 	})
-}
 
-func TestCache_Keys(t *testing.T) {
-	t.Parallel()
+	t.Run("replays the journal after restoring the snapshot", func(t *testing.T) {
+		t.Parallel()
 
-	t.Run("returns the keys of the cache", func(t *testing.T) {
+		dir := t.TempDir()
+		options := []memcache.Option[int, int]{memcache.WithPeriodicSnapshot[int, int](filepath.Join(dir, "cache.snapshot"), time.Hour)}
+		cache := open(t, filepath.Join(dir, "cache.aof"), memcache.FsyncNever, options...)
+		cache.Set(1, 10)
+		cache.Close()
+
+		replayed := open(t, filepath.Join(dir, "cache.aof"), memcache.FsyncNever, options...)
+		defer replayed.Close()
+		value, ok := replayed.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 10, value)
+	})
+
+	t.Run("truncates a partially written record", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncAlways)
+		cache.Set(1, 10)
+		cache.Close()
+		complete, err := os.Stat(path)
+		require.NoError(t, err)
 
-				cache, _ := newCache(cacheSize)
-				defer cache.Close()
-				store := cache.Store()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.Write([]byte{20, 1, 2, 3})
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
 
-				store.Add(1, data.Item[int, int]{Value: 1})
-				store.Add(2, data.Item[int, int]{Value: 1})
-				store.Add(3, data.Item[int, int]{Value: 1})
+		replayed := open(t, path, memcache.FsyncAlways)
+		require.Equal(t, []int{1}, replayed.Keys())
+		truncated, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, complete.Size(), truncated.Size())
 
-				keys := cache.Keys()
-				require.Contains(t, keys, 1)
-				require.Contains(t, keys, 2)
-				require.Contains(t, keys, 3)
-			})
-		}
+		replayed.Set(2, 20)
+		replayed.Close()
+		reopened := open(t, path, memcache.FsyncAlways)
+		defer reopened.Close()
+		require.ElementsMatch(t, []int{1, 2}, reopened.Keys())
 	})
-}
-
-func TestCache_Close(t *testing.T) {
-	t.Parallel()
 
-	t.Run("successfully closes the cache", func(t *testing.T) {
+	t.Run("returns an error without truncating a corrupt record", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
+		for name, record := range map[string]int{"first": 0, "last": 1} {
+			record := record
+			t.Run(name, func(t *testing.T) {
 				t.Parallel()
 
-				cache, _ := newCache(cacheSize)
-				cache.Close()
-				require.True(t, cache.Closed())
+				// ends holds the offset each record ends at.
+				path := filepath.Join(t.TempDir(), "cache.aof")
+				var ends []int64
+				for i := 0; i < 2; i++ {
+					cache := open(t, path, memcache.FsyncAlways)
+					cache.Set(i, i*10)
+					cache.Close()
+					info, err := os.Stat(path)
+					require.NoError(t, err)
+					ends = append(ends, info.Size())
+				}
+
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				b[ends[record]-1] ^= 0xff // corrupt the checksum of the record.
+				require.NoError(t, os.WriteFile(path, b, 0o600))
+
+				_, err = memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncAlways, 1024))
+				require.ErrorIs(t, err, memcache.ErrInvalidJournal)
+				after, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.Equal(t, b, after)
 			})
 		}
 	})
 
-	t.Run("subsequent calls to close do not panic", func(t *testing.T) {
+	t.Run("stops recording writes once the journal cannot be written or truncated", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncAlways)
+		cache.Set(1, 10)
 
-				cache, _ := newCache(cacheSize)
-				cache.Close()
-				require.NotPanics(t, func() { cache.Close() })
-			})
-		}
+		readOnly, err := os.Open(path)
+		require.NoError(t, err)
+		defer readOnly.Close()
+		writable := cache.SwapJournalFile(readOnly)
+		cache.Set(2, 20)
+		cache.SwapJournalFile(writable)
+		cache.Set(3, 30)
+		cache.Close()
+
+		replayed := open(t, path, memcache.FsyncAlways)
+		defer replayed.Close()
+		require.Equal(t, []int{1}, replayed.Keys())
 	})
 
-	t.Run("cache with no goroutines is garbage collected after releasing without closing", func(t *testing.T) {
+	t.Run("returns an error if the journal was written using another codec", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncNever, memcache.WithCodec(codec.JSON[int, int]()))
+		cache.Set(1, 10)
+		cache.Close()
 
-				ch := make(chan struct{})
-
-				cache := func() *memcache.Cache[int, int] {
-					c, _ := newCache(cacheSize)
-					runtime.SetFinalizer(c, func(_ *memcache.Cache[int, int]) {
-						close(ch)
-					})
-					return c
-				}()
+		_, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncNever, 1024))
+		require.ErrorIs(t, err, memcache.ErrInvalidJournal)
+	})
 
-				cache.Flush() // use the cache once
-				cache = nil   // release the cache
-				runtime.GC()  // explicitly run garbage collection
+	t.Run("replays the last write to keys written concurrently", func(t *testing.T) {
+		t.Parallel()
 
-				select {
-				case <-time.After(500 * time.Millisecond):
-					t.Fatal("cache was not garbage collected")
-				case <-ch:
-					// cache was garbage collected
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		cache := open(t, path, memcache.FsyncNever)
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 1_000; i++ {
+					switch key := i % 4; {
+					case i%50 == 0:
+						cache.Flush()
+					case i%7 == w:
+						cache.Delete(key)
+					default:
+						cache.Set(key, w*1_000+i)
+					}
 				}
-			})
+			}(w)
+		}
+		wg.Wait()
+		want := cache.Store().Items()
+		cache.Close()
+
+		replayed := open(t, path, memcache.FsyncNever)
+		defer replayed.Close()
+		got := replayed.Store().Items()
+		require.Len(t, got, len(want))
+		for key, item := range want {
+			require.Contains(t, got, key)
+			require.Equal(t, item.Value, got[key].Value)
 		}
 	})
 
-	t.Run("cache with goroutines is garbage collected after releasing & closing", func(t *testing.T) {
+	t.Run("rewrites the journal once it grows beyond the rewrite size", func(t *testing.T) {
 		t.Parallel()
 
-		for policy, newCache := range policies {
-			newCache := newCache
-			t.Run(policy, func(t *testing.T) {
-				t.Parallel()
-
-				ch := make(chan struct{})
+		write := func(path string, rewriteSize int64) int64 {
+			cache, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncNever, rewriteSize))
+			require.NoError(t, err)
+			for i := 0; i < 10_000; i++ {
+				cache.Set(i%10, i)
+				// wait for each rewrite so that no records are written while
+				// it is in progress.
+				cache.WaitJournalRewrite()
+			}
+			cache.Close()
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			return info.Size()
+		}
 
-				cache := func() *memcache.Cache[int, int] {
-					interval := 50 * time.Millisecond
-					c, _ := newCache(cacheSize, memcache.WithActiveExpiration[int, int](interval))
-					runtime.SetFinalizer(c, func(_ *memcache.Cache[int, int]) {
-						close(ch)
-					})
-					return c
-				}()
+		path := filepath.Join(t.TempDir(), "cache.aof")
+		rewritten := write(path, 4096)
+		unrewritten := write(filepath.Join(t.TempDir(), "cache.aof"), 1<<30)
+		require.Less(t, rewritten, int64(4096))
+		require.Greater(t, unrewritten, int64(10_000*8))
 
-				cache.Flush() // use the cache once
-				cache.Close() // close the cache
-				cache = nil   // release the cache
-				runtime.GC()  // explicitly run garbage collection
+		dir, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		require.Len(t, dir, 1, "temporary files were left behind")
 
-				select {
-				case <-time.After(500 * time.Millisecond):
-					t.Fatal("cache was not garbage collected")
-				case <-ch:
-					// cache was garbage collected
-				}
-			})
+		replayed, err := memcache.OpenAllKeysLRUCache(cacheSize, memcache.WithJournal[int, int](path, memcache.FsyncNever, 4096))
+		require.NoError(t, err)
+		defer replayed.Close()
+		items := replayed.Store().Items()
+		require.Len(t, items, 10)
+		for i := 0; i < 10; i++ {
+			require.Equal(t, 9_990+i, items[i].Value)
 		}
 	})
 }
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/internal/glob"
+)
+
+// backend is a cache which commands are run against, either a snapshot opened
+// in memory or a running server.
+type backend interface {
+	// get returns the value of key, or false if it does not exist.
+	get(key string) ([]byte, bool, error)
+	// set key to value, expiring after ttl if it is greater than 0.
+	set(key string, value []byte, ttl time.Duration) error
+	// del deletes keys, returning how many existed.
+	del(keys ...string) (int, error)
+	// ttl returns the ttl of key, which is nil if it does not expire, or false
+	// if it does not exist.
+	ttl(key string) (*time.Duration, bool, error)
+	// keys returns the sorted keys matching the glob-style pattern.
+	keys(pattern string) ([]string, error)
+	// info returns lines of field:value describing the cache, in sections
+	// starting with a # line.
+	info() (string, error)
+	// stats returns lines of field:value holding the stats of the cache.
+	stats() (string, error)
+	close() error
+}
+
+// snapshotBackend runs commands against a snapshot restored into an unbounded
+// cache in memory. Writes only change the cache in memory, and are not saved
+// to the snapshot.
+type snapshotBackend[V ~[]byte] struct {
+	path  string
+	cache *memcache.Cache[string, V]
+}
+
+// openSnapshot restores the snapshot at path, which must have been written by
+// a cache of string keys using the codec named codecName: raw for []byte or
+// string values as written by memcache-server, or json for any values, which
+// are shown as JSON.
+func openSnapshot(path, codecName string) (backend, error) {
+	switch codecName {
+	case "raw":
+		return openSnapshotWith(path, codec.Raw[string, []byte]())
+	case "json":
+		return openSnapshotWith(path, codec.JSON[string, json.RawMessage]())
+	default:
+		return nil, fmt.Errorf("unknown codec %q, expected raw or json", codecName)
+	}
+}
+
+func openSnapshotWith[V ~[]byte](path string, c codec.Codec[string, V]) (backend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cache, err := memcache.OpenNoEvictionCache(
+		memcache.WithCodec(c),
+		memcache.WithPassiveExpiration[string, V](),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Restore(bufio.NewReader(f)); err != nil {
+		cache.Close()
+		return nil, err
+	}
+
+	return &snapshotBackend[V]{path: path, cache: cache}, nil
+}
+
+func (b *snapshotBackend[V]) get(key string) ([]byte, bool, error) {
+	value, ok := b.cache.Get(key)
+	return []byte(value), ok, nil
+}
+
+func (b *snapshotBackend[V]) set(key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		b.cache.SetEx(key, V(value), ttl)
+	} else {
+		b.cache.Set(key, V(value))
+	}
+	return nil
+}
+
+func (b *snapshotBackend[V]) del(keys ...string) (int, error) {
+	n := 0
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, ok, _ := b.ttl(key); ok {
+			n++
+		}
+	}
+	b.cache.Delete(keys...)
+
+	return n, nil
+}
+
+func (b *snapshotBackend[V]) ttl(key string) (*time.Duration, bool, error) {
+	ttl, ok := b.cache.TTL(key)
+	if !ok || (ttl != nil && *ttl <= 0) {
+		return nil, false, nil
+	}
+
+	return ttl, true, nil
+}
+
+func (b *snapshotBackend[V]) keys(pattern string) ([]string, error) {
+	var keys []string
+	for _, key := range b.cache.Keys() {
+		if _, ok, _ := b.ttl(key); ok && glob.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (b *snapshotBackend[V]) info() (string, error) {
+	stats, _ := b.stats()
+	return strings.Join([]string{
+		"# Snapshot",
+		"path:" + b.path,
+		"# Keyspace",
+		"keys:" + strconv.Itoa(b.cache.Size()),
+		"weight:" + strconv.FormatInt(b.cache.Weight(), 10),
+		"# Stats",
+		stats,
+	}, "\n"), nil
+}
+
+func (b *snapshotBackend[V]) stats() (string, error) {
+	stats := b.cache.Stats()
+	return strings.Join([]string{
+		"hits:" + strconv.FormatUint(stats.Hits, 10),
+		"misses:" + strconv.FormatUint(stats.Misses, 10),
+		"sets:" + strconv.FormatUint(stats.Sets, 10),
+		"deletes:" + strconv.FormatUint(stats.Deletes, 10),
+		"expired_lookups:" + strconv.FormatUint(stats.ExpiredLookups, 10),
+	}, "\n"), nil
+}
+
+func (b *snapshotBackend[V]) close() error {
+	b.cache.Close()
+	return nil
+}
+
+// serverBackend runs commands against a running server over RESP.
+type serverBackend struct {
+	client *respClient
+}
+
+func dialServer(addr string, timeout time.Duration) (backend, error) {
+	client, err := dialRESP(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.do("PING"); err != nil {
+		_ = client.close()
+		return nil, err
+	}
+
+	return &serverBackend{client: client}, nil
+}
+
+func (b *serverBackend) get(key string) ([]byte, bool, error) {
+	reply, err := b.client.do("GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, unexpectedReply(reply)
+	}
+	return value, true, nil
+}
+
+func (b *serverBackend) set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+
+	_, err := b.client.do(args...)
+	return err
+}
+
+func (b *serverBackend) del(keys ...string) (int, error) {
+	reply, err := b.client.do(append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, unexpectedReply(reply)
+	}
+	return int(n), nil
+}
+
+func (b *serverBackend) ttl(key string) (*time.Duration, bool, error) {
+	reply, err := b.client.do("PTTL", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	millis, ok := reply.(int64)
+	switch {
+	case !ok:
+		return nil, false, unexpectedReply(reply)
+	case millis == -2:
+		return nil, false, nil
+	case millis == -1:
+		return nil, true, nil
+	}
+	ttl := time.Duration(millis) * time.Millisecond
+	return &ttl, true, nil
+}
+
+func (b *serverBackend) keys(pattern string) ([]string, error) {
+	reply, err := b.client.do("KEYS", pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	array, ok := reply.([]any)
+	if !ok {
+		return nil, unexpectedReply(reply)
+	}
+	keys := make([]string, 0, len(array))
+	for _, key := range array {
+		key, ok := key.([]byte)
+		if !ok {
+			return nil, unexpectedReply(reply)
+		}
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (b *serverBackend) info() (string, error) {
+	return b.infoSection()
+}
+
+func (b *serverBackend) stats() (string, error) {
+	info, err := b.infoSection("stats")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(info, "# Stats\n"), nil
+}
+
+func (b *serverBackend) infoSection(sections ...string) (string, error) {
+	reply, err := b.client.do(append([]string{"INFO"}, sections...)...)
+	if err != nil {
+		return "", err
+	}
+
+	info, ok := reply.([]byte)
+	if !ok {
+		return "", unexpectedReply(reply)
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(info), "\r\n", "\n")), nil
+}
+
+func (b *serverBackend) close() error {
+	return b.client.close()
+}
+
+func unexpectedReply(reply any) error {
+	return fmt.Errorf("unexpected reply %v", reply)
+}
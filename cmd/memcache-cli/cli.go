@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errQuit is returned by the quit command to stop running commands.
+var errQuit = errors.New("quit")
+
+// maxHistory is the maximum number of commands kept in the history.
+const maxHistory = 1000
+
+type command struct {
+	usage       string
+	description string
+	// minArgs & maxArgs bound the number of arguments of the command, with a
+	// maxArgs of -1 being unbounded.
+	minArgs, maxArgs int
+	run              func(c *cli, args []string) error
+}
+
+// commands are looked up by their name, which is the first word of their
+// usage. They are initialized in init because help refers to them.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{}
+	for _, cmd := range []command{
+		{"get key", "print the value of key", 1, 1, (*cli).get},
+		{"set key value [ttl]", "set key to value, expiring after ttl seconds or a duration such as 1m30s", 2, 3, (*cli).set},
+		{"del key [key ...]", "delete keys, printing how many existed", 1, -1, (*cli).del},
+		{"ttl key", "print the ttl of key", 1, 1, (*cli).ttl},
+		{"keys [pattern]", "print the keys matching the glob-style pattern, * by default", 0, 1, (*cli).keys},
+		{"info", "print information about the cache", 0, 0, (*cli).info},
+		{"stats", "print the stats of the cache", 0, 0, (*cli).stats},
+		{"dump [pattern] [file]", "dump the keys matching pattern with their values & ttls as JSON to file or stdout", 0, 2, (*cli).dump},
+		{"history", "print the command history, whose commands can be rerun with !n, or !! for the last", 0, 0, (*cli).printHistory},
+		{"help", "print this help", 0, 0, (*cli).help},
+		{"quit", "quit, also exit", 0, 0, (*cli).quit},
+	} {
+		commands[strings.Fields(cmd.usage)[0]] = cmd
+	}
+	commands["exit"] = commands["quit"]
+}
+
+// cli runs commands against a backend.
+type cli struct {
+	backend backend
+	out     io.Writer
+	history []string
+	// historyFile, if not nil, has every command appended to it.
+	historyFile io.Writer
+}
+
+// run the command line, returning errQuit if it quits.
+func (c *cli) run(line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	cmd, ok := commands[strings.ToLower(args[0])]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see help", args[0])
+	}
+	args = args[1:]
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return fmt.Errorf("usage: %s", cmd.usage)
+	}
+
+	return cmd.run(c, args)
+}
+
+// repl runs every line of in as a command. Interactive sessions print a prompt
+// before reading each line, record lines in the history and carry on after
+// errors, which are written to errOut. Otherwise the first error stops the
+// session and is returned.
+func (c *cli) repl(in io.Reader, errOut io.Writer, interactive bool) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for {
+		if interactive {
+			fmt.Fprint(c.out, "memcache> ")
+		}
+		if !scanner.Scan() {
+			if interactive {
+				fmt.Fprintln(c.out)
+			}
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if interactive {
+			var err error
+			if line, err = c.expandHistory(line); err != nil {
+				fmt.Fprintln(errOut, "error:", err)
+				continue
+			}
+			c.record(line)
+		}
+
+		err := c.run(line)
+		switch {
+		case errors.Is(err, errQuit):
+			return nil
+		case err != nil && interactive:
+			fmt.Fprintln(errOut, "error:", err)
+		case err != nil:
+			return fmt.Errorf("%s: %w", line, err)
+		}
+	}
+}
+
+// expandHistory replaces a line of !! with the last command in the history and
+// a line of !n with the nth.
+func (c *cli) expandHistory(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+
+	n := len(c.history)
+	if line != "!!" {
+		var err error
+		if n, err = strconv.Atoi(line[1:]); err != nil {
+			return "", fmt.Errorf("invalid history reference %q", line)
+		}
+	}
+	if n < 1 || n > len(c.history) {
+		return "", fmt.Errorf("no command %s in history", line)
+	}
+
+	line = c.history[n-1]
+	fmt.Fprintln(c.out, line)
+	return line, nil
+}
+
+// record line in the history, and in the history file if there is one.
+func (c *cli) record(line string) {
+	c.history = append(c.history, line)
+	if len(c.history) > maxHistory {
+		c.history = c.history[len(c.history)-maxHistory:]
+	}
+
+	if c.historyFile != nil {
+		fmt.Fprintln(c.historyFile, line)
+	}
+}
+
+func (c *cli) get(args []string) error {
+	value, ok, err := c.backend.get(args[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintln(c.out, "(nil)")
+		return nil
+	}
+
+	fmt.Fprintln(c.out, printable(value))
+	return nil
+}
+
+func (c *cli) set(args []string) error {
+	var ttl time.Duration
+	if len(args) == 3 {
+		var err error
+		if ttl, err = parseTTL(args[2]); err != nil {
+			return err
+		}
+	}
+
+	if err := c.backend.set(args[0], []byte(args[1]), ttl); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, "OK")
+	return nil
+}
+
+func (c *cli) del(args []string) error {
+	n, err := c.backend.del(args...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, n)
+	return nil
+}
+
+func (c *cli) ttl(args []string) error {
+	ttl, ok, err := c.backend.ttl(args[0])
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		fmt.Fprintln(c.out, "(nil)")
+	case ttl == nil:
+		fmt.Fprintln(c.out, "no expiry")
+	default:
+		fmt.Fprintln(c.out, ttl.Round(time.Millisecond))
+	}
+
+	return nil
+}
+
+func (c *cli) keys(args []string) error {
+	pattern := "*"
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	keys, err := c.backend.keys(pattern)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		fmt.Fprintln(c.out, "(empty)")
+	}
+	for _, key := range keys {
+		fmt.Fprintln(c.out, printable([]byte(key)))
+	}
+
+	return nil
+}
+
+func (c *cli) info(_ []string) error {
+	info, err := c.backend.info()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, info)
+	return nil
+}
+
+func (c *cli) stats(_ []string) error {
+	stats, err := c.backend.stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, stats)
+	return nil
+}
+
+// dumpEntry is an entry of the JSON written by dump. Values which are valid
+// UTF-8 are written as is, and others base64 encoded.
+type dumpEntry struct {
+	Key         string   `json:"key"`
+	Value       *string  `json:"value,omitempty"`
+	ValueBase64 *string  `json:"value_base64,omitempty"`
+	TTLSeconds  *float64 `json:"ttl_seconds,omitempty"`
+}
+
+func (c *cli) dump(args []string) (err error) {
+	pattern := "*"
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+
+	keys, err := c.backend.keys(pattern)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]dumpEntry, 0, len(keys))
+	for _, key := range keys {
+		value, ok, err := c.backend.get(key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue // expired or deleted since listing keys.
+		}
+		ttl, _, err := c.backend.ttl(key)
+		if err != nil {
+			return err
+		}
+
+		entry := dumpEntry{Key: key}
+		if utf8.Valid(value) {
+			s := string(value)
+			entry.Value = &s
+		} else {
+			s := base64.StdEncoding.EncodeToString(value)
+			entry.ValueBase64 = &s
+		}
+		if ttl != nil {
+			seconds := ttl.Seconds()
+			entry.TTLSeconds = &seconds
+		}
+		entries = append(entries, entry)
+	}
+
+	out := c.out
+	if len(args) == 2 {
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return err
+	}
+	if out != c.out {
+		fmt.Fprintf(c.out, "dumped %d keys to %s\n", len(entries), args[1])
+	}
+
+	return nil
+}
+
+func (c *cli) printHistory(_ []string) error {
+	for i, line := range c.history {
+		fmt.Fprintf(c.out, "%4d  %s\n", i+1, line)
+	}
+
+	return nil
+}
+
+func (c *cli) help(_ []string) error {
+	for _, name := range []string{"get", "set", "del", "ttl", "keys", "info", "stats", "dump", "history", "help", "quit"} {
+		cmd := commands[name]
+		fmt.Fprintf(c.out, "%-22s %s\n", cmd.usage, cmd.description)
+	}
+	fmt.Fprintln(c.out, "\nArguments containing spaces can be quoted with \"\" or ''.")
+
+	return nil
+}
+
+func (c *cli) quit(_ []string) error {
+	return errQuit
+}
+
+// parseTTL parses a ttl given as either a number of seconds or a Go duration
+// such as 1m30s.
+func parseTTL(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil && seconds > 0 && seconds <= int64(time.Duration(1<<63-1)/time.Second) {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q, expected a positive number of seconds or duration", s)
+	}
+
+	return ttl, nil
+}
+
+// printable returns b as is if it is printable text, otherwise quoted with Go
+// escapes.
+func printable(b []byte) string {
+	s := string(b)
+	if !utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) && r != '\t' {
+			return strconv.Quote(s)
+		}
+	}
+
+	return s
+}
+
+// splitArgs splits line into arguments separated by whitespace. Arguments can
+// be quoted with double quotes, within which \ escapes the next character and
+// \n, \r & \t are interpreted, or with single quotes, within which nothing is
+// escaped.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case ch == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(line); i++ {
+				if line[i] == '"' {
+					closed = true
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					default:
+						arg.WriteByte(line[i])
+					}
+					continue
+				}
+				arg.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+		case ch == '\'':
+			inArg = true
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			arg.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+		default:
+			inArg = true
+			arg.WriteByte(ch)
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
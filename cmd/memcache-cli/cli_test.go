@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/server"
+)
+
+func TestSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		line string
+		args []string
+		err  string
+	}{
+		"splits on whitespace":             {line: " get \tkey  ", args: []string{"get", "key"}},
+		"returns nothing for a blank line": {line: "  ", args: nil},
+		"unquotes double quotes":           {line: `set "a key" "a \"value\"\n"`, args: []string{"set", "a key", "a \"value\"\n"}},
+		"does not escape in single quotes": {line: `set 'a key' 'a \n'`, args: []string{"set", "a key", `a \n`}},
+		"joins adjacent quoted parts":      {line: `get a"b c"'d'`, args: []string{"get", "ab cd"}},
+		"keeps empty quoted arguments":     {line: `set key ""`, args: []string{"set", "key", ""}},
+		"rejects unterminated double":      {line: `get "key`, err: "unterminated double quote"},
+		"rejects unterminated single":      {line: `get 'key`, err: "unterminated single quote"},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			args, err := splitArgs(tc.line)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	t.Parallel()
+
+	t.Run("quotes arguments so that they are split back out as is", func(t *testing.T) {
+		t.Parallel()
+
+		args := []string{"plain", "", "a b", `a"b`, "a'b", `a\nb`, "a\tb"}
+		line := make([]string, len(args))
+		for i, arg := range args {
+			line[i] = quote(arg)
+		}
+
+		split, err := splitArgs(strings.Join(line, " "))
+		require.NoError(t, err)
+		require.Equal(t, args, split)
+	})
+}
+
+func TestParseTTL(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]time.Duration{
+		"10":    10 * time.Second,
+		"1m30s": 90 * time.Second,
+		"250ms": 250 * time.Millisecond,
+	} {
+		ttl, err := parseTTL(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, ttl, s)
+	}
+
+	for _, s := range []string{"0", "-1", "0s", "-1s", "soon", ""} {
+		_, err := parseTTL(s)
+		require.Error(t, err, s)
+	}
+}
+
+// writeSnapshot writes a snapshot of a cache holding items, with greeting
+// expiring in an hour, and returns its path.
+func writeSnapshot(t *testing.T, items map[string]string) string {
+	t.Helper()
+
+	cache, err := memcache.OpenAllKeysLRUCache(100, memcache.WithCodec(codec.Raw[string, []byte]()))
+	require.NoError(t, err)
+	defer cache.Close()
+	for key, value := range items {
+		if key == "greeting" {
+			cache.SetEx(key, []byte(value), time.Hour)
+			continue
+		}
+		cache.Set(key, []byte(value))
+	}
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, cache.Snapshot(f))
+	require.NoError(t, f.Close())
+
+	return path
+}
+
+// serve a cache holding items, with greeting expiring in an hour, and return
+// a backend connected to it.
+func serve(t *testing.T, items map[string]string) backend {
+	t.Helper()
+
+	cache, err := memcache.OpenAllKeysLRUCache(100, memcache.WithPassiveExpiration[string, []byte]())
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+	for key, value := range items {
+		if key == "greeting" {
+			cache.SetEx(key, []byte(value), time.Hour)
+			continue
+		}
+		cache.Set(key, []byte(value))
+	}
+
+	s, err := server.NewRESP(cache)
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, s.Close())
+		require.ErrorIs(t, <-done, server.ErrServerClosed)
+	})
+
+	b, err := dialServer(l.Addr().String(), 5*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b.close() })
+
+	return b
+}
+
+// exec runs line, returning its output.
+func exec(t *testing.T, c *cli, line string) string {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	c.out = out
+	require.NoError(t, c.run(line))
+
+	return out.String()
+}
+
+func TestCLI(t *testing.T) {
+	t.Parallel()
+
+	items := map[string]string{
+		"greeting":  "hello",
+		"user:1":    "alice",
+		"user:2":    "bob",
+		"binary":    "\x00\xff",
+		"with tabs": "a\tb",
+	}
+
+	backends := map[string]func(t *testing.T) backend{
+		"snapshot": func(t *testing.T) backend {
+			b, err := openSnapshot(writeSnapshot(t, items), "raw")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = b.close() })
+			return b
+		},
+		"server": func(t *testing.T) backend {
+			return serve(t, items)
+		},
+	}
+
+	for name, newBackend := range backends {
+		newBackend := newBackend
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("get prints values, quoting unprintable ones", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				require.Equal(t, "hello\n", exec(t, c, "get greeting"))
+				require.Equal(t, "a\tb\n", exec(t, c, `get "with tabs"`))
+				require.Equal(t, `"\x00\xff"`+"\n", exec(t, c, "get binary"))
+				require.Equal(t, "(nil)\n", exec(t, c, "get missing"))
+			})
+
+			t.Run("set sets values with an optional ttl", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				require.Equal(t, "OK\n", exec(t, c, "set key 'a value'"))
+				require.Equal(t, "a value\n", exec(t, c, "get key"))
+				require.Equal(t, "no expiry\n", exec(t, c, "ttl key"))
+
+				require.Equal(t, "OK\n", exec(t, c, "set key value 1m"))
+				ttl, err := time.ParseDuration(strings.TrimSpace(exec(t, c, "ttl key")))
+				require.NoError(t, err)
+				require.InDelta(t, time.Minute, ttl, float64(5*time.Second))
+
+				require.ErrorContains(t, c.run("set key value never"), "invalid ttl")
+			})
+
+			t.Run("del deletes keys and prints how many existed", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				require.Equal(t, "2\n", exec(t, c, "del user:1 user:2 user:1 missing"))
+				require.Equal(t, "(nil)\n", exec(t, c, "get user:1"))
+			})
+
+			t.Run("ttl prints the ttl of keys", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				ttl, err := time.ParseDuration(strings.TrimSpace(exec(t, c, "ttl greeting")))
+				require.NoError(t, err)
+				require.InDelta(t, time.Hour, ttl, float64(time.Minute))
+				require.Equal(t, "no expiry\n", exec(t, c, "ttl user:1"))
+				require.Equal(t, "(nil)\n", exec(t, c, "ttl missing"))
+			})
+
+			t.Run("keys prints sorted keys matching a pattern", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				require.Equal(t, "user:1\nuser:2\n", exec(t, c, "keys user:*"))
+				require.Equal(t, "binary\ngreeting\nuser:1\nuser:2\nwith tabs\n", exec(t, c, "keys"))
+				require.Equal(t, "(empty)\n", exec(t, c, "keys nothing*"))
+			})
+
+			t.Run("info & stats print fields", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				exec(t, c, "get greeting")
+				require.Contains(t, exec(t, c, "info"), "keys:5\n")
+				require.Contains(t, exec(t, c, "stats"), "hits:1\n")
+			})
+
+			t.Run("dump writes entries as JSON", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				var entries []dumpEntry
+				require.NoError(t, json.Unmarshal([]byte(exec(t, c, "dump")), &entries))
+				require.Len(t, entries, 5)
+
+				require.Equal(t, "binary", entries[0].Key)
+				require.Nil(t, entries[0].Value)
+				require.Equal(t, "AP8=", *entries[0].ValueBase64)
+				require.Equal(t, "greeting", entries[1].Key)
+				require.Equal(t, "hello", *entries[1].Value)
+				require.InDelta(t, time.Hour.Seconds(), *entries[1].TTLSeconds, 60)
+				require.Equal(t, "user:1", entries[2].Key)
+				require.Nil(t, entries[2].TTLSeconds)
+			})
+
+			t.Run("dump writes entries matching a pattern to a file", func(t *testing.T) {
+				t.Parallel()
+
+				c := &cli{backend: newBackend(t)}
+				path := filepath.Join(t.TempDir(), "dump.json")
+				require.Equal(t, "dumped 2 keys to "+path+"\n", exec(t, c, "dump user:* "+quote(path)))
+
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				var entries []dumpEntry
+				require.NoError(t, json.Unmarshal(b, &entries))
+				require.Len(t, entries, 2)
+				require.Equal(t, "bob", *entries[1].Value)
+			})
+		})
+	}
+}
+
+func TestCLI_run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an error for unknown commands", func(t *testing.T) {
+		t.Parallel()
+
+		c := &cli{backend: serve(t, nil)}
+		require.EqualError(t, c.run("frobnicate"), `unknown command "frobnicate", see help`)
+	})
+
+	t.Run("returns the usage for the wrong number of arguments", func(t *testing.T) {
+		t.Parallel()
+
+		c := &cli{backend: serve(t, nil)}
+		require.EqualError(t, c.run("get"), "usage: get key")
+		require.EqualError(t, c.run("get a b"), "usage: get key")
+		require.EqualError(t, c.run("del"), "usage: del key [key ...]")
+	})
+
+	t.Run("matches commands case insensitively", func(t *testing.T) {
+		t.Parallel()
+
+		c := &cli{backend: serve(t, map[string]string{"key": "value"})}
+		require.Equal(t, "value\n", exec(t, c, "GET key"))
+	})
+
+	t.Run("returns errQuit for quit & exit", func(t *testing.T) {
+		t.Parallel()
+
+		c := &cli{backend: serve(t, nil)}
+		require.ErrorIs(t, c.run("quit"), errQuit)
+		require.ErrorIs(t, c.run("exit"), errQuit)
+	})
+}
+
+func TestCLI_repl(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs scripts, skipping blank lines & comments", func(t *testing.T) {
+		t.Parallel()
+
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		c := &cli{backend: serve(t, nil), out: out}
+		script := "# seed\nset a 1\n\nset b 2\nkeys\n"
+
+		require.NoError(t, c.repl(strings.NewReader(script), errOut, false))
+		require.Equal(t, "OK\nOK\na\nb\n", out.String())
+		require.Empty(t, errOut.String())
+		require.Empty(t, c.history)
+	})
+
+	t.Run("stops scripts at the first error", func(t *testing.T) {
+		t.Parallel()
+
+		out := &bytes.Buffer{}
+		c := &cli{backend: serve(t, nil), out: out}
+		script := "set a 1\nget\nset b 2\n"
+
+		err := c.repl(strings.NewReader(script), &bytes.Buffer{}, false)
+		require.EqualError(t, err, "get: usage: get key")
+		require.Equal(t, "OK\n", out.String())
+	})
+
+	t.Run("stops at quit", func(t *testing.T) {
+		t.Parallel()
+
+		out := &bytes.Buffer{}
+		c := &cli{backend: serve(t, nil), out: out}
+
+		require.NoError(t, c.repl(strings.NewReader("set a 1\nquit\nset b 2\n"), &bytes.Buffer{}, false))
+		require.Equal(t, "OK\n", out.String())
+	})
+
+	t.Run("prompts & carries on after errors when interactive", func(t *testing.T) {
+		t.Parallel()
+
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		c := &cli{backend: serve(t, nil), out: out}
+
+		require.NoError(t, c.repl(strings.NewReader("get\nset a 1\n"), errOut, true))
+		require.Equal(t, "memcache> memcache> OK\nmemcache> \n", out.String())
+		require.Equal(t, "error: usage: get key\n", errOut.String())
+	})
+
+	t.Run("records & reruns the history when interactive", func(t *testing.T) {
+		t.Parallel()
+
+		out, errOut, historyFile := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+		c := &cli{backend: serve(t, nil), out: out, historyFile: historyFile}
+		session := "set a 1\nget a\n!1\n!!\n!9\nhistory\n"
+
+		require.NoError(t, c.repl(strings.NewReader(session), errOut, true))
+		require.Equal(t, []string{"set a 1", "get a", "set a 1", "set a 1", "history"}, c.history)
+		require.Equal(t, "set a 1\nget a\nset a 1\nset a 1\nhistory\n", historyFile.String())
+		require.Contains(t, out.String(), "   2  get a\n")
+		require.Equal(t, "error: no command !9 in history\n", errOut.String())
+	})
+}
@@ -0,0 +1,173 @@
+// Command memcache-cli inspects caches, either by opening a snapshot file or
+// by connecting to a running memcache-server, and runs commands against them
+// interactively, from a script, or once from its arguments.
+//
+// Usage:
+//
+//	memcache-cli [flags] [command [args...]]
+//
+// Given a command, it is run and memcache-cli exits, with a non-zero status if
+// the command failed. Otherwise commands are read one per line from the script
+// given by -f, or from stdin, stopping at the first failing command unless
+// stdin is a terminal, in which case memcache-cli runs as a REPL with a
+// history. Run the help command to list commands.
+//
+// The flags are:
+//
+//	-addr address
+//		the TCP address of the server to connect to (default "localhost:6379")
+//	-snapshot path
+//		open the snapshot at path instead of connecting to a server, with
+//		writes only changing the snapshot in memory
+//	-codec name
+//		the codec the snapshot was written with: raw for memcache-server
+//		snapshots, or json (default "raw")
+//	-timeout duration
+//		the timeout of connecting to and each command sent to the server
+//		(default 5s)
+//	-f path
+//		run the commands in the script at path
+//	-history path
+//		save the history of the REPL to path, or nowhere if empty
+//		(default "~/.memcache_cli_history")
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("memcache-cli: ")
+
+	addr := flag.String("addr", "localhost:6379", "the TCP `address` of the server to connect to")
+	snapshot := flag.String("snapshot", "", "open the snapshot at `path` instead of connecting to a server")
+	codecName := flag.String("codec", "raw", "the codec the snapshot was written with, raw or json")
+	timeout := flag.Duration("timeout", 5*time.Second, "the timeout of connecting to and each command sent to the server")
+	script := flag.String("f", "", "run the commands in the script at `path`")
+	history := flag.String("history", defaultHistoryPath(), "save the history of the REPL to `path`, or nowhere if empty")
+	flag.Parse()
+
+	var b backend
+	var err error
+	if *snapshot != "" {
+		b, err = openSnapshot(*snapshot, *codecName)
+	} else {
+		b, err = dialServer(*addr, *timeout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = run(&cli{backend: b, out: os.Stdout}, flag.Args(), *script, *history)
+	if closeErr := b.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run the command given by args if any, otherwise the commands of script if
+// any, otherwise the commands read from stdin.
+func run(c *cli, args []string, script, historyPath string) error {
+	switch {
+	case len(args) > 0:
+		line := make([]string, len(args))
+		for i, arg := range args {
+			line[i] = quote(arg)
+		}
+		if err := c.run(strings.Join(line, " ")); err != nil && !errors.Is(err, errQuit) {
+			return err
+		}
+		return nil
+	case script != "":
+		f, err := os.Open(script)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return c.repl(f, os.Stderr, false)
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Mode()&os.ModeCharDevice == 0 {
+		return c.repl(os.Stdin, os.Stderr, false)
+	}
+
+	if historyPath != "" {
+		if err := loadHistory(c, historyPath); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(historyPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		c.historyFile = f
+	}
+	fmt.Fprintln(c.out, `Type "help" for help.`)
+
+	return c.repl(os.Stdin, os.Stderr, true)
+}
+
+// loadHistory reads the history saved at path into the history of c, if it
+// exists.
+func loadHistory(c *cli, path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			c.record(line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".memcache_cli_history")
+}
+
+// quote arg so that splitArgs splits it back out as is.
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		if arg[i] == '"' || arg[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(arg[i])
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
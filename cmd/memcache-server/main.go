@@ -0,0 +1,184 @@
+// Command memcache-server serves a cache of string keys and byte values over
+// TCP, speaking the Redis protocol and optionally the memcached text protocol,
+// so that it can be shared by processes written in any language or inspected
+// using redis-cli.
+//
+// Usage:
+//
+//	memcache-server [flags]
+//
+// The flags are:
+//
+//	-addr address
+//		the TCP address to listen on for Redis clients (default ":6379")
+//	-memcached-addr address
+//		the TCP address to listen on for memcached clients, if any
+//	-policy name
+//		the eviction policy of the cache (default "allkeyslru")
+//	-capacity n
+//		the capacity of the cache, or the default of the policy if 0
+//	-active-expiration interval
+//		how often expired keys are deleted, or never if 0 (default 1s)
+//	-snapshot path
+//		snapshot the cache to path periodically and restore it on start
+//	-snapshot-interval interval
+//		how often the cache is snapshotted (default 1m)
+//	-journal path
+//		journal writes to the cache to path and replay them on start
+//	-fsync policy
+//		how often the journal is synced: always, everysec or never
+//		(default "everysec")
+//	-journal-rewrite-size bytes
+//		rewrite the journal once it grows to this size (default 64MiB)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/server"
+)
+
+var policies = map[string]func() memcache.Policy[string, []byte]{
+	"noevict":        memcache.NoEvictionPolicy[string, []byte],
+	"allkeyslru":     memcache.AllKeysLRUPolicy[string, []byte],
+	"volatilelru":    memcache.VolatileLRUPolicy[string, []byte],
+	"allkeyslfu":     memcache.AllKeysLFUPolicy[string, []byte],
+	"volatilelfu":    memcache.VolatileLFUPolicy[string, []byte],
+	"allkeysrandom":  memcache.AllKeysRandomPolicy[string, []byte],
+	"volatilerandom": memcache.VolatileRandomPolicy[string, []byte],
+	"volatilettl":    memcache.VolatileTTLPolicy[string, []byte],
+	"tinylfu":        memcache.TinyLFUPolicy[string, []byte],
+	"arc":            memcache.ARCPolicy[string, []byte],
+	"sieve":          memcache.SIEVEPolicy[string, []byte],
+	"s3fifo":         memcache.S3FIFOPolicy[string, []byte],
+}
+
+var fsyncPolicies = map[string]memcache.FsyncPolicy{
+	"always":   memcache.FsyncAlways,
+	"everysec": memcache.FsyncEverySecond,
+	"never":    memcache.FsyncNever,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("memcache-server: ")
+
+	addr := flag.String("addr", ":6379", "the TCP `address` to listen on for Redis clients")
+	memcachedAddr := flag.String("memcached-addr", "", "the TCP `address` to listen on for memcached clients, if any")
+	policy := flag.String("policy", "allkeyslru", "the eviction policy of the cache, one of "+strings.Join(names(policies), ", "))
+	capacity := flag.Int("capacity", 0, "the capacity of the cache, or the default of the policy if 0")
+	activeExpiration := flag.Duration("active-expiration", time.Second, "how often expired keys are deleted, or never if 0")
+	snapshot := flag.String("snapshot", "", "snapshot the cache to `path` periodically and restore it on start")
+	snapshotInterval := flag.Duration("snapshot-interval", time.Minute, "how often the cache is snapshotted")
+	journal := flag.String("journal", "", "journal writes to the cache to `path` and replay them on start")
+	fsync := flag.String("fsync", "everysec", "how often the journal is synced, one of "+strings.Join(names(fsyncPolicies), ", "))
+	rewriteSize := flag.Int64("journal-rewrite-size", 64<<20, "rewrite the journal once it grows to this many `bytes`")
+	flag.Parse()
+
+	newPolicy, ok := policies[*policy]
+	if !ok {
+		log.Fatalf("unknown policy %q", *policy)
+	}
+
+	options := []memcache.Option[string, []byte]{
+		memcache.WithPassiveExpiration[string, []byte](),
+		memcache.WithCodec(codec.Raw[string, []byte]()),
+	}
+	if *capacity > 0 {
+		options = append(options, memcache.WithCapacity[string, []byte](*capacity))
+	}
+	if *activeExpiration > 0 {
+		options = append(options, memcache.WithActiveExpiration[string, []byte](*activeExpiration))
+	}
+	if *snapshot != "" {
+		options = append(options, memcache.WithPeriodicSnapshot[string, []byte](*snapshot, *snapshotInterval))
+	}
+	if *journal != "" {
+		fsyncPolicy, ok := fsyncPolicies[*fsync]
+		if !ok {
+			log.Fatalf("unknown fsync policy %q", *fsync)
+		}
+		options = append(options, memcache.WithJournal[string, []byte](*journal, fsyncPolicy, *rewriteSize))
+	}
+
+	cache, err := memcache.Open(newPolicy(), options...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := run(cache, *addr, *memcachedAddr); err != nil {
+		cache.Close()
+		log.Fatal(err)
+	}
+	cache.Close()
+}
+
+// run serves cache to Redis clients on addr, and to memcached clients on
+// memcachedAddr if it is not empty, until the process is interrupted or
+// terminated.
+func run(cache *memcache.Cache[string, []byte], addr, memcachedAddr string) error {
+	resp, err := server.NewRESP(cache)
+	if err != nil {
+		return err
+	}
+	servers := map[*server.Server]string{resp: addr}
+	if memcachedAddr != "" {
+		memcached, err := server.NewMemcached(cache)
+		if err != nil {
+			return err
+		}
+		servers[memcached] = memcachedAddr
+	}
+
+	done := make(chan error, len(servers))
+	for s, addr := range servers {
+		s, addr := s, addr
+		go func() { done <- s.ListenAndServe(addr) }()
+		fmt.Fprintf(os.Stderr, "memcache-server: serving %s cache on %s\n", cache.PolicyName(), addr)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	// the first server to stop serving, if any, stops the others.
+	var serveErr error
+	stopped := 0
+	select {
+	case serveErr = <-done:
+		stopped++
+	case <-signals:
+	}
+
+	for s := range servers {
+		_ = s.Close()
+	}
+	for ; stopped < len(servers); stopped++ {
+		if err := <-done; serveErr == nil && !errors.Is(err, server.ErrServerClosed) {
+			serveErr = err
+		}
+	}
+
+	return serveErr
+}
+
+func names[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
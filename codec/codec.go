@@ -0,0 +1,110 @@
+// Package codec provides encodings of the keys and values of caches, as used to
+// snapshot caches and to serve them over a network.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec encodes & decodes keys and values to & from bytes.
+//
+// Implementations must be safe for concurrent use and must not retain the
+// bytes passed to their decode methods.
+type Codec[K comparable, V any] interface {
+	// Name identifies the codec so that bytes encoded by one codec are not
+	// decoded by another.
+	Name() string
+	EncodeKey(key K) ([]byte, error)
+	DecodeKey(data []byte) (K, error)
+	EncodeValue(value V) ([]byte, error)
+	DecodeValue(data []byte) (V, error)
+}
+
+// Gob returns a codec encoding keys and values using [encoding/gob].
+//
+// Keys and values must be of types gob can encode. Values of interface types
+// must have their concrete types registered via [gob.Register].
+func Gob[K comparable, V any]() Codec[K, V] {
+	return funcs[K, V]{name: "gob", encode: gobEncode, decode: gobDecode}
+}
+
+// JSON returns a codec encoding keys and values using [encoding/json].
+func JSON[K comparable, V any]() Codec[K, V] {
+	return funcs[K, V]{name: "json", encode: json.Marshal, decode: json.Unmarshal}
+}
+
+// Raw returns a codec which uses the bytes of string keys and of string or
+// []byte values as is.
+func Raw[K ~string, V ~string | ~[]byte]() Codec[K, V] {
+	return raw[K, V]{}
+}
+
+// funcs is a codec encoding keys and values alike using encode & decode.
+type funcs[K comparable, V any] struct {
+	name   string
+	encode func(v any) ([]byte, error)
+	decode func(data []byte, v any) error
+}
+
+func (c funcs[K, V]) Name() string {
+	return c.name
+}
+
+func (c funcs[K, V]) EncodeKey(key K) ([]byte, error) {
+	return c.encode(&key)
+}
+
+func (c funcs[K, V]) DecodeKey(data []byte) (K, error) {
+	var key K
+	err := c.decode(data, &key)
+	return key, err
+}
+
+func (c funcs[K, V]) EncodeValue(value V) ([]byte, error) {
+	return c.encode(&value)
+}
+
+func (c funcs[K, V]) DecodeValue(data []byte) (V, error) {
+	var value V
+	err := c.decode(data, &value)
+	return value, err
+}
+
+// gobEncode encodes v, which is a pointer so that values of interface types are
+// encoded along with their concrete type.
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gobDecode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type raw[K ~string, V ~string | ~[]byte] struct{}
+
+func (raw[K, V]) Name() string {
+	return "raw"
+}
+
+func (raw[K, V]) EncodeKey(key K) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (raw[K, V]) DecodeKey(data []byte) (K, error) {
+	return K(data), nil
+}
+
+func (raw[K, V]) EncodeValue(value V) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (raw[K, V]) DecodeValue(data []byte) (V, error) {
+	return V(bytes.Clone(data)), nil
+}
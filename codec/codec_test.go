@@ -0,0 +1,109 @@
+package codec_test
+
+import (
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/codec"
+)
+
+type point struct {
+	X, Y int
+}
+
+func init() {
+	gob.Register(point{})
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	codecs := map[string]codec.Codec[string, point]{
+		"gob":  codec.Gob[string, point](),
+		"json": codec.JSON[string, point](),
+	}
+
+	for name, c := range codecs {
+		name, c := name, c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, name, c.Name())
+
+			data, err := c.EncodeKey("key")
+			require.NoError(t, err)
+			key, err := c.DecodeKey(data)
+			require.NoError(t, err)
+			require.Equal(t, "key", key)
+
+			data, err = c.EncodeValue(point{X: 1, Y: 2})
+			require.NoError(t, err)
+			value, err := c.DecodeValue(data)
+			require.NoError(t, err)
+			require.Equal(t, point{X: 1, Y: 2}, value)
+
+			_, err = c.DecodeValue([]byte("invalid"))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestGob(t *testing.T) {
+	t.Parallel()
+
+	t.Run("encodes values of interface types with their concrete type", func(t *testing.T) {
+		t.Parallel()
+
+		c := codec.Gob[int, any]()
+		data, err := c.EncodeValue(point{X: 1, Y: 2})
+		require.NoError(t, err)
+		value, err := c.DecodeValue(data)
+		require.NoError(t, err)
+		require.Equal(t, point{X: 1, Y: 2}, value)
+	})
+
+	t.Run("returns an error for types gob cannot encode", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := codec.Gob[int, any]().EncodeValue(struct{ unregistered int }{})
+		require.Error(t, err)
+	})
+}
+
+func TestRaw(t *testing.T) {
+	t.Parallel()
+
+	t.Run("uses the bytes of keys and values as is", func(t *testing.T) {
+		t.Parallel()
+
+		c := codec.Raw[string, string]()
+		require.Equal(t, "raw", c.Name())
+
+		data, err := c.EncodeKey("key")
+		require.NoError(t, err)
+		require.Equal(t, []byte("key"), data)
+		key, err := c.DecodeKey(data)
+		require.NoError(t, err)
+		require.Equal(t, "key", key)
+
+		data, err = c.EncodeValue("value")
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), data)
+		value, err := c.DecodeValue(data)
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+	})
+
+	t.Run("decodes byte slice values as copies", func(t *testing.T) {
+		t.Parallel()
+
+		type key string
+		c := codec.Raw[key, []byte]()
+		data := []byte("value")
+		value, err := c.DecodeValue(data)
+		require.NoError(t, err)
+		data[0] = 'V'
+		require.Equal(t, []byte("value"), value)
+	})
+}
@@ -0,0 +1,196 @@
+// Package data provides the types held by cache stores.
+//
+// It is only needed when implementing a custom store for a
+// [github.com/wafer-bw/memcache.Policy].
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// Item is a value held by a store along with its expiry metadata.
+type Item[K comparable, V any] struct {
+	Value    V
+	ExpireAt *time.Time
+	// StaleAt is when the item becomes stale and should be refreshed, while
+	// still being served until it expires at ExpireAt.
+	StaleAt *time.Time
+	// LoadDuration is how long Value took to load, used to expire the item
+	// early with a probability proportional to it.
+	LoadDuration time.Duration
+	// Weight of the item counted against the capacity of a store. Items
+	// weighing less than 1 are counted as weighing 1, see [Item.Cost].
+	Weight int64
+	// Version of the item, which a cache increments every time it sets a value
+	// so that no two items it sets share a version. It is compared by stores
+	// which support compare-and-swap to detect concurrent writes.
+	Version uint64
+}
+
+// Reason describes why an item was removed from a store.
+type Reason int
+
+const (
+	// Evicted items were removed by a store to remain within its capacity.
+	Evicted Reason = iota + 1
+	// Expired items had expired when they were removed.
+	Expired
+	// Deleted items were removed on request before they expired.
+	Deleted
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	case Deleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("Reason(%d)", int(r))
+	}
+}
+
+// RemoveFunc is called by a store with each key and item it removes along with
+// the reason they were removed. Stores must call it after releasing their
+// locks so that it may safely use the store.
+type RemoveFunc[K comparable, V any] func(key K, item Item[K, V], reason Reason)
+
+// NotifyEvicted calls f with each of entries which were evicted, doing nothing
+// if f is nil.
+func (f RemoveFunc[K, V]) NotifyEvicted(entries []Entry[K, V]) {
+	if f == nil {
+		return
+	}
+	for _, entry := range entries {
+		f(entry.Key, entry.Item, Evicted)
+	}
+}
+
+// NotifyRemoved calls f with each of entries which were removed on request,
+// doing nothing if f is nil. Items expired by now are reported as [Expired]
+// and all others as [Deleted].
+func (f RemoveFunc[K, V]) NotifyRemoved(entries []Entry[K, V], now time.Time) {
+	if f == nil {
+		return
+	}
+	for _, entry := range entries {
+		f(entry.Key, entry.Item, removedReason(entry.Item, now))
+	}
+}
+
+// NotifyFlushed calls f with each of items which were removed by flushing a
+// store, doing nothing if f is nil. Items expired by now are reported as
+// [Expired] and all others as [Deleted].
+func (f RemoveFunc[K, V]) NotifyFlushed(items map[K]Item[K, V], now time.Time) {
+	if f == nil {
+		return
+	}
+	for key, item := range items {
+		f(key, item, removedReason(item, now))
+	}
+}
+
+// Entry is a key along with its item.
+type Entry[K comparable, V any] struct {
+	Key  K
+	Item Item[K, V]
+}
+
+// Cost returns the weight of the item counted against the capacity of a store,
+// which is at least 1.
+func (i Item[K, V]) Cost() int64 {
+	return max(1, i.Weight)
+}
+
+// IsExpired reports whether the item has an expiry which has passed.
+func (i Item[K, V]) IsExpired() bool {
+	return i.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt reports whether the item has an expiry which has passed by now.
+func (i Item[K, V]) IsExpiredAt(now time.Time) bool {
+	if i.ExpireAt == nil {
+		return false
+	}
+	return now.After(*i.ExpireAt)
+}
+
+// IsStale reports whether the item has a stale time which has passed.
+func (i Item[K, V]) IsStale() bool {
+	return i.IsStaleAt(time.Now())
+}
+
+// IsStaleAt reports whether the item has a stale time which has passed by now.
+func (i Item[K, V]) IsStaleAt(now time.Time) bool {
+	if i.StaleAt == nil {
+		return false
+	}
+	return now.After(*i.StaleAt)
+}
+
+// TTL returns the time remaining until the item expires, zero if it has
+// already expired, or nil if it does not expire.
+func (i Item[K, V]) TTL() *time.Duration {
+	return i.TTLAt(time.Now())
+}
+
+// TTLAt returns the time remaining from now until the item expires, zero if it
+// has already expired by now, or nil if it does not expire.
+func (i Item[K, V]) TTLAt(now time.Time) *time.Duration {
+	if i.ExpireAt == nil {
+		return nil
+	}
+
+	ttl := i.ExpireAt.Sub(now)
+	if ttl < 0 {
+		return new(time.Duration)
+	}
+
+	return &ttl
+}
+
+// Clock tells the time to caches, their stores and their expirers, allowing
+// time to be controlled in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTicker returns a ticker which ticks every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a [Clock].
+type Ticker interface {
+	// C returns the channel ticks are delivered on.
+	C() <-chan time.Time
+	// Stop the ticker, after which no more ticks are delivered.
+	Stop()
+}
+
+// SystemClock is a [Clock] which tells the system time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{Ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+func removedReason[K comparable, V any](item Item[K, V], now time.Time) Reason {
+	if item.IsExpiredAt(now) {
+		return Expired
+	}
+	return Deleted
+}
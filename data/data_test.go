@@ -0,0 +1,264 @@
+package data_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+)
+
+func TestItem_IsExpired(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns true when ExpireAt is in the past", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now().Add(-1 * time.Minute)
+		i := data.Item[int, string]{ExpireAt: &now}
+		require.True(t, i.IsExpired())
+	})
+
+	t.Run("returns false when ExpireAt is nil", func(t *testing.T) {
+		t.Parallel()
+
+		var i data.Item[int, string]
+		require.False(t, i.IsExpired())
+	})
+
+	t.Run("returns false when ExpireAt is in the future", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now().Add(1 * time.Minute)
+		i := data.Item[int, string]{ExpireAt: &now}
+		require.False(t, i.IsExpired())
+	})
+}
+
+func TestItem_IsStale(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns true when StaleAt is in the past", func(t *testing.T) {
+		t.Parallel()
+
+		staleAt := time.Now().Add(-1 * time.Minute)
+		i := data.Item[int, string]{StaleAt: &staleAt}
+		require.True(t, i.IsStale())
+	})
+
+	t.Run("returns false when StaleAt is nil", func(t *testing.T) {
+		t.Parallel()
+
+		var i data.Item[int, string]
+		require.False(t, i.IsStale())
+	})
+
+	t.Run("returns false when StaleAt is in the future", func(t *testing.T) {
+		t.Parallel()
+
+		staleAt := time.Now().Add(1 * time.Minute)
+		i := data.Item[int, string]{StaleAt: &staleAt}
+		require.False(t, i.IsStale())
+	})
+}
+
+func TestItem_TTL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns time remaining when the item is not expired", func(t *testing.T) {
+		t.Parallel()
+
+		ttl := 1 * time.Minute
+		now := time.Now().Add(1 * time.Minute)
+		i := data.Item[int, string]{ExpireAt: &now}
+		require.Greater(t, *i.TTL(), ttl-1*time.Second)
+	})
+
+	t.Run("returns nil when the item has no expiry", func(t *testing.T) {
+		t.Parallel()
+
+		i := data.Item[int, string]{ExpireAt: nil}
+		require.Nil(t, i.TTL())
+	})
+
+	t.Run("returns 0 when the item is expired", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now().Add(-1 * time.Minute)
+		i := data.Item[int, string]{ExpireAt: &now}
+		require.Equal(t, time.Duration(0), *i.TTL())
+	})
+}
+
+func TestItem_IsExpiredAt(t *testing.T) {
+	t.Parallel()
+
+	expireAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := data.Item[int, string]{ExpireAt: &expireAt}
+	require.False(t, i.IsExpiredAt(expireAt))
+	require.True(t, i.IsExpiredAt(expireAt.Add(time.Nanosecond)))
+	require.False(t, data.Item[int, string]{}.IsExpiredAt(expireAt))
+}
+
+func TestItem_IsStaleAt(t *testing.T) {
+	t.Parallel()
+
+	staleAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := data.Item[int, string]{StaleAt: &staleAt}
+	require.False(t, i.IsStaleAt(staleAt))
+	require.True(t, i.IsStaleAt(staleAt.Add(time.Nanosecond)))
+	require.False(t, data.Item[int, string]{}.IsStaleAt(staleAt))
+}
+
+func TestItem_TTLAt(t *testing.T) {
+	t.Parallel()
+
+	expireAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := data.Item[int, string]{ExpireAt: &expireAt}
+	require.Equal(t, time.Minute, *i.TTLAt(expireAt.Add(-time.Minute)))
+	require.Equal(t, time.Duration(0), *i.TTLAt(expireAt.Add(time.Minute)))
+	require.Nil(t, data.Item[int, string]{}.TTLAt(expireAt))
+}
+
+func TestSystemClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the system time", func(t *testing.T) {
+		t.Parallel()
+
+		before := time.Now()
+		now := data.SystemClock{}.Now()
+		require.False(t, now.Before(before))
+		require.False(t, now.After(time.Now()))
+	})
+
+	t.Run("returns a ticker which ticks until stopped", func(t *testing.T) {
+		t.Parallel()
+
+		ticker := data.SystemClock{}.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		select {
+		case <-ticker.C():
+		case <-time.After(time.Second):
+			t.Fatal("ticker did not tick")
+		}
+	})
+}
+
+func TestItem_Cost(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the weight of the item", func(t *testing.T) {
+		t.Parallel()
+
+		i := data.Item[int, string]{Weight: 5}
+		require.Equal(t, int64(5), i.Cost())
+	})
+
+	t.Run("returns 1 when the item weighs less than 1", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, int64(1), data.Item[int, string]{}.Cost())
+		require.Equal(t, int64(1), data.Item[int, string]{Weight: -3}.Cost())
+	})
+}
+
+func TestReason_String(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "evicted", data.Evicted.String())
+	require.Equal(t, "expired", data.Expired.String())
+	require.Equal(t, "deleted", data.Deleted.String())
+	require.Equal(t, "Reason(0)", data.Reason(0).String())
+}
+
+func TestRemoveFunc_NotifyEvicted(t *testing.T) {
+	t.Parallel()
+
+	t.Run("calls the func with each entry in order", func(t *testing.T) {
+		t.Parallel()
+
+		var keys []int
+		f := data.RemoveFunc[int, string](func(key int, item data.Item[int, string], reason data.Reason) {
+			require.Equal(t, key, len(item.Value))
+			require.Equal(t, data.Evicted, reason)
+			keys = append(keys, key)
+		})
+		f.NotifyEvicted([]data.Entry[int, string]{
+			{Key: 1, Item: data.Item[int, string]{Value: "a"}},
+			{Key: 2, Item: data.Item[int, string]{Value: "ab"}},
+		})
+
+		require.Equal(t, []int{1, 2}, keys)
+	})
+
+	t.Run("does nothing when the func is nil", func(t *testing.T) {
+		t.Parallel()
+
+		var f data.RemoveFunc[int, string]
+		require.NotPanics(t, func() {
+			f.NotifyEvicted([]data.Entry[int, string]{{Key: 1}})
+		})
+	})
+}
+
+func TestRemoveFunc_NotifyRemoved(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports expired items as expired and others as deleted", func(t *testing.T) {
+		t.Parallel()
+
+		past := time.Now().Add(-1 * time.Minute)
+		future := time.Now().Add(1 * time.Minute)
+		reasons := map[int]data.Reason{}
+		f := data.RemoveFunc[int, string](func(key int, _ data.Item[int, string], reason data.Reason) {
+			reasons[key] = reason
+		})
+		f.NotifyRemoved([]data.Entry[int, string]{
+			{Key: 1, Item: data.Item[int, string]{ExpireAt: &past}},
+			{Key: 2, Item: data.Item[int, string]{ExpireAt: &future}},
+			{Key: 3, Item: data.Item[int, string]{}},
+		}, time.Now())
+
+		require.Equal(t, map[int]data.Reason{1: data.Expired, 2: data.Deleted, 3: data.Deleted}, reasons)
+	})
+
+	t.Run("does nothing when the func is nil", func(t *testing.T) {
+		t.Parallel()
+
+		var f data.RemoveFunc[int, string]
+		require.NotPanics(t, func() {
+			f.NotifyRemoved([]data.Entry[int, string]{{Key: 1}}, time.Now())
+		})
+	})
+}
+
+func TestRemoveFunc_NotifyFlushed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports expired items as expired and others as deleted", func(t *testing.T) {
+		t.Parallel()
+
+		past := time.Now().Add(-1 * time.Minute)
+		reasons := map[int]data.Reason{}
+		f := data.RemoveFunc[int, string](func(key int, _ data.Item[int, string], reason data.Reason) {
+			reasons[key] = reason
+		})
+		f.NotifyFlushed(map[int]data.Item[int, string]{
+			1: {ExpireAt: &past},
+			2: {},
+		}, time.Now())
+
+		require.Equal(t, map[int]data.Reason{1: data.Expired, 2: data.Deleted}, reasons)
+	})
+
+	t.Run("does nothing when the func is nil", func(t *testing.T) {
+		t.Parallel()
+
+		var f data.RemoveFunc[int, string]
+		require.NotPanics(t, func() {
+			f.NotifyFlushed(map[int]data.Item[int, string]{1: {}}, time.Now())
+		})
+	})
+}
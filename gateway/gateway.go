@@ -0,0 +1,411 @@
+// Package gateway exposes a cache over HTTP as a JSON REST API, for debugging
+// and for consumers not written in Go.
+//
+// A [Handler] serves the following routes:
+//
+//	GET    /keys/{key}      the value of key, encoded by the codec
+//	PUT    /keys/{key}      set key to the value in the body, decoded by the codec
+//	DELETE /keys/{key}      delete key
+//	GET    /keys?prefix=p   the keys starting with p, or every key
+//	GET    /ttl/{key}       the ttl of key
+//	POST   /flush           flush the cache
+//	GET    /info            the policy, size, capacity & stats of the cache
+//
+// Keys in paths are URL path unescaped, so keys containing a / must escape it
+// as %2F. Errors are served as a JSON object with an error field.
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/codec"
+)
+
+const (
+	// TTLHeader is the header of PUT requests which sets the ttl of their
+	// value, and of GET responses for values which expire, see [ParseTTL].
+	TTLHeader string = "X-Cache-TTL"
+
+	// TTLParam is the query parameter of PUT requests which sets the ttl of
+	// their value, taking precedence over [TTLHeader], see [ParseTTL].
+	TTLParam string = "ttl"
+
+	// DefaultMaxValueSize is the default maximum size of the body of PUT
+	// requests, see [WithMaxValueSize].
+	DefaultMaxValueSize int64 = 1 << 20
+)
+
+var (
+	ErrInvalidCache        = errors.New("provided cache must not be nil")
+	ErrInvalidCodec        = errors.New("provided codec must not be nil")
+	ErrInvalidMaxValueSize = errors.New("provided max value size must be greater than 0")
+)
+
+// Option configures a [Handler] when passed to [New].
+type Option[V any] func(*Handler[V]) error
+
+// WithCodec sets the codec used to encode values in responses and decode them
+// from requests. Defaults to [codec.JSON].
+//
+// Responses carry a Content-Type of application/json for codecs named json,
+// and application/octet-stream otherwise.
+func WithCodec[V any](c codec.Codec[string, V]) Option[V] {
+	return func(h *Handler[V]) error {
+		if c == nil {
+			return ErrInvalidCodec
+		}
+		h.codec = c
+		return nil
+	}
+}
+
+// WithReadOnly rejects requests which would write to the cache with 403
+// Forbidden.
+func WithReadOnly[V any]() Option[V] {
+	return func(h *Handler[V]) error {
+		h.readOnly = true
+		return nil
+	}
+}
+
+// WithMaxValueSize sets the maximum size of the body of PUT requests, larger
+// bodies being rejected with 413 Request Entity Too Large. Defaults to
+// [DefaultMaxValueSize].
+func WithMaxValueSize[V any](size int64) Option[V] {
+	return func(h *Handler[V]) error {
+		if size <= 0 {
+			return ErrInvalidMaxValueSize
+		}
+		h.maxValueSize = size
+		return nil
+	}
+}
+
+// Handler is an [http.Handler] exposing a cache as a JSON REST API.
+type Handler[V any] struct {
+	cache        *memcache.Cache[string, V]
+	codec        codec.Codec[string, V]
+	readOnly     bool
+	maxValueSize int64
+}
+
+// New returns a handler exposing cache.
+func New[V any](cache *memcache.Cache[string, V], options ...Option[V]) (*Handler[V], error) {
+	if cache == nil {
+		return nil, ErrInvalidCache
+	}
+
+	h := &Handler[V]{
+		cache:        cache,
+		codec:        codec.JSON[string, V](),
+		maxValueSize: DefaultMaxValueSize,
+	}
+
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		if err := option(h); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// ServeHTTP routes requests to the cache.
+func (h *Handler[V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	switch {
+	case path == "/keys":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.keys})
+	case strings.HasPrefix(path, "/keys/"):
+		h.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:    h.get,
+			http.MethodPut:    h.writing(h.put),
+			http.MethodDelete: h.writing(h.delete),
+		})
+	case strings.HasPrefix(path, "/ttl/"):
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.ttl})
+	case path == "/flush":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodPost: h.writing(h.flush)})
+	case path == "/info":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.info})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// route r to the handler for its method, or reply with 405 Method Not Allowed
+// if there is none.
+func (h *Handler[V]) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	if handler, ok := handlers[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// writing wraps handlers which write to the cache so that they are rejected in
+// read-only mode.
+func (h *Handler[V]) writing(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.readOnly {
+			writeError(w, http.StatusForbidden, "cache is read-only")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (h *Handler[V]) get(w http.ResponseWriter, r *http.Request) {
+	key, ok := pathKey(w, r, "/keys/")
+	if !ok {
+		return
+	}
+
+	value, ok := h.cache.Get(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	b, err := h.codec.EncodeValue(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "encoding value: "+err.Error())
+		return
+	}
+
+	if ttl, _ := h.cache.TTL(key); ttl != nil {
+		w.Header().Set(TTLHeader, ttl.String())
+	}
+	w.Header().Set("Content-Type", h.contentType())
+	_, _ = w.Write(b)
+}
+
+func (h *Handler[V]) put(w http.ResponseWriter, r *http.Request) {
+	key, ok := pathKey(w, r, "/keys/")
+	if !ok {
+		return
+	}
+
+	raw := r.URL.Query().Get(TTLParam)
+	if raw == "" {
+		raw = r.Header.Get(TTLHeader)
+	}
+	ttl, err := ParseTTL(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxValueSize))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, "value is too large")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, "reading value: "+err.Error())
+		return
+	}
+	value, err := h.codec.DecodeValue(b)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "decoding value: "+err.Error())
+		return
+	}
+
+	if ttl > 0 {
+		h.cache.SetEx(key, value, ttl)
+	} else {
+		h.cache.Set(key, value)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler[V]) delete(w http.ResponseWriter, r *http.Request) {
+	key, ok := pathKey(w, r, "/keys/")
+	if !ok {
+		return
+	}
+
+	if !h.cache.Contains(key) {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	h.cache.Delete(key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keysResponse is the response to GET /keys.
+type keysResponse struct {
+	Keys []string `json:"keys"`
+}
+
+func (h *Handler[V]) keys(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+
+	keys := []string{}
+	for _, key := range h.cache.Keys() {
+		if strings.HasPrefix(key, prefix) && h.cache.Contains(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	writeJSON(w, http.StatusOK, keysResponse{Keys: keys})
+}
+
+// ttlResponse is the response to GET /ttl/{key}. The ttl of keys which do not
+// expire is null.
+type ttlResponse struct {
+	Key        string   `json:"key"`
+	TTL        *string  `json:"ttl"`
+	TTLSeconds *float64 `json:"ttl_seconds"`
+}
+
+func (h *Handler[V]) ttl(w http.ResponseWriter, r *http.Request) {
+	key, ok := pathKey(w, r, "/ttl/")
+	if !ok {
+		return
+	}
+
+	ttl, ok := h.cache.TTL(key)
+	if !ok || (ttl != nil && *ttl <= 0) {
+		writeError(w, http.StatusNotFound, "key not found")
+		return
+	}
+
+	resp := ttlResponse{Key: key}
+	if ttl != nil {
+		s, seconds := ttl.String(), ttl.Seconds()
+		resp.TTL, resp.TTLSeconds = &s, &seconds
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler[V]) flush(w http.ResponseWriter, _ *http.Request) {
+	h.cache.Flush()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// infoResponse is the response to GET /info.
+type infoResponse struct {
+	Policy   string        `json:"policy"`
+	Codec    string        `json:"codec"`
+	ReadOnly bool          `json:"read_only"`
+	Size     int           `json:"size"`
+	Weight   int64         `json:"weight"`
+	Capacity int           `json:"capacity"`
+	Stats    statsResponse `json:"stats"`
+}
+
+type statsResponse struct {
+	Hits               uint64  `json:"hits"`
+	Misses             uint64  `json:"misses"`
+	HitRatio           float64 `json:"hit_ratio"`
+	Sets               uint64  `json:"sets"`
+	Deletes            uint64  `json:"deletes"`
+	Evictions          uint64  `json:"evictions"`
+	ActiveExpirations  uint64  `json:"active_expirations"`
+	PassiveExpirations uint64  `json:"passive_expirations"`
+	ExpiredLookups     uint64  `json:"expired_lookups"`
+	LoadSuccesses      uint64  `json:"load_successes"`
+	LoadFailures       uint64  `json:"load_failures"`
+}
+
+func (h *Handler[V]) info(w http.ResponseWriter, _ *http.Request) {
+	stats := h.cache.Stats()
+	writeJSON(w, http.StatusOK, infoResponse{
+		Policy:   h.cache.PolicyName(),
+		Codec:    h.codec.Name(),
+		ReadOnly: h.readOnly,
+		Size:     h.cache.Size(),
+		Weight:   h.cache.Weight(),
+		Capacity: h.cache.Capacity(),
+		Stats: statsResponse{
+			Hits:               stats.Hits,
+			Misses:             stats.Misses,
+			HitRatio:           stats.HitRatio(),
+			Sets:               stats.Sets,
+			Deletes:            stats.Deletes,
+			Evictions:          stats.Evictions,
+			ActiveExpirations:  stats.ActiveExpirations,
+			PassiveExpirations: stats.PassiveExpirations,
+			ExpiredLookups:     stats.ExpiredLookups,
+			LoadSuccesses:      stats.LoadSuccesses,
+			LoadFailures:       stats.LoadFailures,
+		},
+	})
+}
+
+func (h *Handler[V]) contentType() string {
+	if h.codec.Name() == "json" {
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// ParseTTL parses a ttl given as either a number of seconds or a Go duration
+// such as 1m30s. An empty ttl is 0, meaning the value does not expire.
+func ParseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if seconds < 0 || seconds > math.MaxInt64/int64(time.Second) {
+			return 0, errors.New("invalid ttl " + strconv.Quote(s))
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl < 0 {
+		return 0, errors.New("invalid ttl " + strconv.Quote(s))
+	}
+
+	return ttl, nil
+}
+
+// pathKey returns the unescaped key following prefix in the path of r, or
+// replies with 404 Not Found if it is empty or malformed.
+func pathKey(w http.ResponseWriter, r *http.Request, prefix string) (string, bool) {
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), prefix))
+	if err != nil || key == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return "", false
+	}
+
+	return key, true
+}
+
+// errorResponse is the response to failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,47 @@
+package gateway_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/gateway"
+)
+
+func ExampleNew() {
+	cache, err := memcache.OpenAllKeysLRUCache[string, string](10)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	handler, err := gateway.New(cache)
+	if err != nil {
+		panic(err)
+	}
+
+	// serve the handler, usually via http.Handle("/cache/", http.StripPrefix("/cache", handler)).
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/keys/greeting?ttl=1m", strings.NewReader(`"hello"`))
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		panic(err)
+	}
+	resp.Body.Close()
+
+	resp, err = server.Client().Get(server.URL + "/keys/greeting")
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, string(body))
+	// Output:
+	// 200 "hello"
+}
@@ -0,0 +1,430 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/gateway"
+	"github.com/wafer-bw/memcache/memcachetest"
+)
+
+var _ http.Handler = (*gateway.Handler[int])(nil)
+
+var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func openCache(t *testing.T) (*memcache.Cache[string, string], *memcachetest.Clock) {
+	t.Helper()
+
+	clock := memcachetest.NewClock(now)
+	cache, err := memcache.OpenAllKeysLRUCache(100, memcache.WithClock[string, string](clock))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	return cache, clock
+}
+
+func newHandler(t *testing.T, cache *memcache.Cache[string, string], options ...gateway.Option[string]) *gateway.Handler[string] {
+	t.Helper()
+
+	h, err := gateway.New(cache, options...)
+	require.NoError(t, err)
+	return h
+}
+
+func do(h http.Handler, method, target string, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+	return v
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an error if the cache is nil", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := gateway.New[string](nil)
+		require.ErrorIs(t, err, gateway.ErrInvalidCache)
+	})
+
+	t.Run("returns an error if the codec is nil", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		_, err := gateway.New(cache, gateway.WithCodec[string](nil))
+		require.ErrorIs(t, err, gateway.ErrInvalidCodec)
+	})
+
+	t.Run("returns an error if the max value size is not greater than 0", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		_, err := gateway.New(cache, gateway.WithMaxValueSize[string](0))
+		require.ErrorIs(t, err, gateway.ErrInvalidMaxValueSize)
+	})
+}
+
+func TestHandler_keys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("GET returns the encoded value of a key", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("a", "one")
+
+		w := do(h, http.MethodGet, "/keys/a", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.Equal(t, `"one"`, w.Body.String())
+		require.Empty(t, w.Header().Get(gateway.TTLHeader))
+	})
+
+	t.Run("GET returns the ttl of an expiring key in a header", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.SetEx("a", "one", time.Minute)
+
+		w := do(h, http.MethodGet, "/keys/a", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "1m0s", w.Header().Get(gateway.TTLHeader))
+	})
+
+	t.Run("GET returns 404 for missing & expired keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		h := newHandler(t, cache)
+		cache.SetEx("a", "one", time.Minute)
+		clock.Advance(time.Hour)
+
+		for _, target := range []string{"/keys/a", "/keys/b", "/keys/"} {
+			w := do(h, http.MethodGet, target, "")
+			require.Equal(t, http.StatusNotFound, w.Code, target)
+			require.Contains(t, decode(t, w), "error")
+		}
+	})
+
+	t.Run("GET unescapes keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("user/1 name", "one")
+
+		w := do(h, http.MethodGet, "/keys/user%2F1%20name", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"one"`, w.Body.String())
+	})
+
+	t.Run("PUT sets a key to the decoded body", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		w := do(h, http.MethodPut, "/keys/a", `"one"`)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		value, ok := cache.Get("a")
+		require.True(t, ok)
+		require.Equal(t, "one", value)
+		ttl, _ := cache.TTL("a")
+		require.Nil(t, ttl)
+	})
+
+	t.Run("PUT sets the ttl from the query or header", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPut, "/keys/a?ttl=30", `"one"`).Code)
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPut, "/keys/b", `"two"`, gateway.TTLHeader, "1m30s").Code)
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPut, "/keys/c?ttl=1h", `"three"`, gateway.TTLHeader, "1s").Code)
+
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, 30*time.Second, *ttl)
+		ttl, _ = cache.TTL("b")
+		require.Equal(t, 90*time.Second, *ttl)
+		ttl, _ = cache.TTL("c")
+		require.Equal(t, time.Hour, *ttl, "the query takes precedence over the header")
+	})
+
+	t.Run("PUT returns 400 for invalid ttls & values", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		require.Equal(t, http.StatusBadRequest, do(h, http.MethodPut, "/keys/a?ttl=soon", `"one"`).Code)
+		require.Equal(t, http.StatusBadRequest, do(h, http.MethodPut, "/keys/a?ttl=-1", `"one"`).Code)
+		require.Equal(t, http.StatusBadRequest, do(h, http.MethodPut, "/keys/a", `not json`).Code)
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("PUT returns 413 for values larger than the max value size", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache, gateway.WithMaxValueSize[string](4))
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, do(h, http.MethodPut, "/keys/a", `"long"`).Code)
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPut, "/keys/a", `"ok"`).Code)
+	})
+
+	t.Run("DELETE deletes a key", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("a", "one")
+
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodDelete, "/keys/a", "").Code)
+		require.Zero(t, cache.Size())
+		require.Equal(t, http.StatusNotFound, do(h, http.MethodDelete, "/keys/a", "").Code)
+	})
+
+	t.Run("GET lists the keys with a prefix", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("user:2", "two")
+		cache.Set("user:1", "one")
+		cache.SetEx("user:3", "three", time.Second)
+		cache.Set("order:1", "one")
+		clock.Advance(time.Minute)
+
+		w := do(h, http.MethodGet, "/keys?prefix=user:", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, map[string]any{"keys": []any{"user:1", "user:2"}}, decode(t, w))
+
+		w = do(h, http.MethodGet, "/keys", "")
+		require.Equal(t, map[string]any{"keys": []any{"order:1", "user:1", "user:2"}}, decode(t, w))
+
+		w = do(h, http.MethodGet, "/keys?prefix=missing", "")
+		require.Equal(t, map[string]any{"keys": []any{}}, decode(t, w))
+	})
+
+	t.Run("GET does not count as an access of the listed keys when evicting", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[string, string](2)
+		require.NoError(t, err)
+		defer cache.Close()
+		h := newHandler(t, cache)
+		cache.Set("a", "1")
+		cache.Set("b", "2")
+
+		w := do(h, http.MethodGet, "/keys?prefix=a", "")
+		require.Equal(t, map[string]any{"keys": []any{"a"}}, decode(t, w))
+		cache.Set("c", "3")
+
+		require.ElementsMatch(t, []string{"b", "c"}, cache.Keys())
+	})
+
+	t.Run("returns 405 for unsupported methods", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		w := do(h, http.MethodPost, "/keys/a", "")
+		require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		require.Equal(t, "DELETE, GET, PUT", w.Header().Get("Allow"))
+	})
+}
+
+func TestHandler_ttl(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the ttl of an expiring key", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.SetEx("a", "one", 90*time.Second)
+
+		w := do(h, http.MethodGet, "/ttl/a", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, map[string]any{"key": "a", "ttl": "1m30s", "ttl_seconds": 90.0}, decode(t, w))
+	})
+
+	t.Run("returns a null ttl for keys which do not expire", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("a", "one")
+
+		w := do(h, http.MethodGet, "/ttl/a", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, map[string]any{"key": "a", "ttl": nil, "ttl_seconds": nil}, decode(t, w))
+	})
+
+	t.Run("returns 404 for missing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		require.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/ttl/a", "").Code)
+	})
+}
+
+func TestHandler_flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("flushes the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("a", "one")
+
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPost, "/flush", "").Code)
+		require.Zero(t, cache.Size())
+		require.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodGet, "/flush", "").Code)
+	})
+}
+
+func TestHandler_info(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the policy, size, capacity & stats of the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+		cache.Set("a", "one")
+		cache.Get("a")
+		cache.Get("b")
+
+		info := decode(t, do(h, http.MethodGet, "/info", ""))
+		require.Equal(t, "allkeyslru", info["policy"])
+		require.Equal(t, "json", info["codec"])
+		require.Equal(t, false, info["read_only"])
+		require.Equal(t, 1.0, info["size"])
+		require.Equal(t, 100.0, info["capacity"])
+		stats := info["stats"].(map[string]any)
+		require.Equal(t, 1.0, stats["hits"])
+		require.Equal(t, 1.0, stats["misses"])
+		require.Equal(t, 0.5, stats["hit_ratio"])
+		require.Equal(t, 1.0, stats["sets"])
+	})
+}
+
+func TestHandler_readOnly(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rejects writes with 403", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache, gateway.WithReadOnly[string]())
+		cache.Set("a", "one")
+
+		require.Equal(t, http.StatusForbidden, do(h, http.MethodPut, "/keys/b", `"two"`).Code)
+		require.Equal(t, http.StatusForbidden, do(h, http.MethodDelete, "/keys/a", "").Code)
+		require.Equal(t, http.StatusForbidden, do(h, http.MethodPost, "/flush", "").Code)
+		require.Equal(t, []string{"a"}, cache.Keys())
+
+		require.Equal(t, http.StatusOK, do(h, http.MethodGet, "/keys/a", "").Code)
+		require.Equal(t, true, decode(t, do(h, http.MethodGet, "/info", ""))["read_only"])
+	})
+}
+
+func TestHandler_codec(t *testing.T) {
+	t.Parallel()
+
+	t.Run("encodes & decodes values using the codec", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[string, []byte](10)
+		require.NoError(t, err)
+		defer cache.Close()
+		h, err := gateway.New(cache, gateway.WithCodec(codec.Raw[string, []byte]()))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNoContent, do(h, http.MethodPut, "/keys/a", "raw bytes").Code)
+		value, _ := cache.Get("a")
+		require.Equal(t, []byte("raw bytes"), value)
+
+		w := do(h, http.MethodGet, "/keys/a", "")
+		require.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+		body, err := io.ReadAll(w.Body)
+		require.NoError(t, err)
+		require.Equal(t, "raw bytes", string(body))
+	})
+}
+
+func TestHandler_notFound(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns 404 for unknown routes", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		h := newHandler(t, cache)
+
+		require.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/nope", "").Code)
+		require.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/", "").Code)
+	})
+}
+
+func TestParseTTL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		"empty is 0":             {s: "", want: 0},
+		"integer is seconds":     {s: "90", want: 90 * time.Second},
+		"duration is parsed":     {s: "1m30s", want: 90 * time.Second},
+		"negative is invalid":    {s: "-1s", wantErr: true},
+		"negative int invalid":   {s: "-5", wantErr: true},
+		"garbage is invalid":     {s: "soon", wantErr: true},
+		"overflowing is invalid": {s: "99999999999999999", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := gateway.ParseTTL(tc.s)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package dispatch provides a bounded queue of callbacks run by a background
+// goroutine.
+package dispatch
+
+import "sync"
+
+// Dispatcher runs the callbacks it is given on a background goroutine in the
+// order they are dispatched.
+//
+// When its queue is full, or once it is closed, callbacks are instead run by
+// the goroutine dispatching them. This keeps memory bounded and means a
+// callback which dispatches more callbacks cannot deadlock the dispatcher.
+type Dispatcher struct {
+	mu     sync.RWMutex
+	closed bool
+	queue  chan func()
+	done   chan struct{}
+}
+
+// New returns a running dispatcher which can queue up to size callbacks.
+func New(size int) *Dispatcher {
+	d := &Dispatcher{
+		queue: make(chan func(), max(size, 0)),
+		done:  make(chan struct{}),
+	}
+	go d.run()
+
+	return d
+}
+
+// Dispatch queues fn to be run by the dispatcher, or runs it immediately if
+// the queue is full or the dispatcher is closed.
+func (d *Dispatcher) Dispatch(fn func()) {
+	d.mu.RLock()
+	queued := false
+	if !d.closed {
+		select {
+		case d.queue <- fn:
+			queued = true
+		default:
+		}
+	}
+	d.mu.RUnlock()
+
+	if !queued {
+		fn()
+	}
+}
+
+// Close stops the dispatcher after running all queued callbacks, blocking
+// until they have returned. Subsequent calls do nothing.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	<-d.done
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for fn := range d.queue {
+		fn()
+	}
+}
@@ -0,0 +1,129 @@
+package dispatch_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/dispatch"
+)
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs callbacks in order on another goroutine", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(10)
+		defer d.Close()
+
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var got []int
+		for i := 0; i < 3; i++ {
+			i := i
+			d.Dispatch(func() {
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, i)
+			})
+		}
+		close(release)
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(got) == 3
+		}, time.Second, time.Millisecond)
+		require.Equal(t, []int{0, 1, 2}, got)
+	})
+
+	t.Run("runs callbacks immediately when the queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(1)
+		defer d.Close()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		d.Dispatch(func() {
+			close(started)
+			<-release
+		})
+		<-started
+		d.Dispatch(func() {}) // fills the queue.
+
+		ran := false
+		d.Dispatch(func() { ran = true })
+		require.True(t, ran)
+		close(release)
+	})
+
+	t.Run("runs callbacks immediately once closed", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(10)
+		d.Close()
+
+		ran := false
+		d.Dispatch(func() { ran = true })
+		require.True(t, ran)
+	})
+
+	t.Run("callbacks may dispatch more callbacks", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(1)
+		defer d.Close()
+
+		done := make(chan struct{})
+		var dispatch func(n int)
+		dispatch = func(n int) {
+			if n == 0 {
+				close(done)
+				return
+			}
+			d.Dispatch(func() { dispatch(n - 1) })
+		}
+		dispatch(100)
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("dispatcher deadlocked")
+		}
+	})
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs queued callbacks before returning", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(10)
+		var mu sync.Mutex
+		count := 0
+		for i := 0; i < 10; i++ {
+			d.Dispatch(func() {
+				mu.Lock()
+				defer mu.Unlock()
+				count++
+			})
+		}
+		d.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, 10, count)
+	})
+
+	t.Run("subsequent calls do not panic", func(t *testing.T) {
+		t.Parallel()
+
+		d := dispatch.New(1)
+		d.Close()
+		d.Close()
+	})
+}
@@ -4,13 +4,19 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
 	"github.com/wafer-bw/memcache/internal/ports"
 )
 
 var _ ports.Storer[int, int] = (*allkeyslfu.Store[int, int])(nil)
 
+var _ ports.Swapper[int, int] = (*allkeyslfu.Store[int, int])(nil)
+
+var _ ports.Ranker[int] = (*allkeyslfu.Store[int, int])(nil)
+
+var _ ports.FrequencyTracker[int] = (*allkeyslfu.Store[int, int])(nil)
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 
@@ -18,14 +24,14 @@ func TestNew(t *testing.T) {
 		t.Parallel()
 
 		capacity := 10
-		store := allkeyslfu.New[int, int](capacity)
+		store := allkeyslfu.New[int, int](capacity, nil, nil)
 		require.Equal(t, capacity, store.Capacity())
 	})
 
 	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
 		t.Parallel()
 
-		store := allkeyslfu.New[int, int](allkeyslfu.MinimumCapacity - 1)
+		store := allkeyslfu.New[int, int](allkeyslfu.MinimumCapacity-1, nil, nil)
 		require.Equal(t, allkeyslfu.DefaultCapacity, store.Capacity())
 	})
 }
@@ -37,7 +43,7 @@ func TestStore_Set(t *testing.T) {
 		t.Parallel()
 
 		key, val := 1, 10
-		store := allkeyslfu.New[int, int](0)
+		store := allkeyslfu.New[int, int](0, nil, nil)
 		store.Add(key, data.Item[int, int]{Value: val})
 
 		items := store.Items()
@@ -48,7 +54,7 @@ func TestStore_Set(t *testing.T) {
 	t.Run("evicts least frequently used key when at capacity", func(t *testing.T) {
 		t.Parallel()
 
-		store := allkeyslfu.New[int, int](3)
+		store := allkeyslfu.New[int, int](3, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2})
 		store.Add(3, data.Item[int, int]{Value: 3})
@@ -66,7 +72,7 @@ func TestStore_Set(t *testing.T) {
 	t.Run("does not add more keys when at capacity", func(t *testing.T) {
 		t.Parallel()
 
-		store := allkeyslfu.New[int, int](2)
+		store := allkeyslfu.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 10})
 		store.Add(1, data.Item[int, int]{Value: 10})
 		store.Add(1, data.Item[int, int]{Value: 10})
@@ -76,6 +82,57 @@ func TestStore_Set(t *testing.T) {
 		items := store.Items()
 		require.Len(t, items, 2)
 	})
+	t.Run("calls onRemove with evicted key and item after eviction", func(t *testing.T) {
+		t.Parallel()
+
+		var evictedKey, evictedValue int
+		store := allkeyslfu.New[int, int](2, func(key int, item data.Item[int, int], _ data.Reason) {
+			evictedKey, evictedValue = key, item.Value
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		_, _ = store.Get(1)
+		store.Add(3, data.Item[int, int]{Value: 30})
+
+		require.Equal(t, 2, evictedKey)
+		require.Equal(t, 20, evictedValue)
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := allkeyslfu.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(1, data.Item[int, int]{Value: 11})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("evicts least frequently used keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := allkeyslfu.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3})
+		_, _ = store.Get(1)
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		_, _ = store.Get(2)
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 3})
+		store.Add(4, data.Item[int, int]{Value: 4, Weight: 1})
+		require.Empty(t, evicted)
+
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 7})
+		require.Len(t, evicted, 2)
+		require.Equal(t, 4, evicted[0])
+		require.Contains(t, store.Items(), 3)
+		require.Equal(t, int64(10), store.Weight())
+	})
 }
 
 func TestStore_Flush(t *testing.T) {
@@ -84,7 +141,7 @@ func TestStore_Flush(t *testing.T) {
 	t.Run("clears all keys and values", func(t *testing.T) {
 		t.Parallel()
 
-		store := allkeyslfu.New[int, int](2)
+		store := allkeyslfu.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2})
 		store.Flush()
@@ -93,3 +150,38 @@ func TestStore_Flush(t *testing.T) {
 		require.Empty(t, items)
 	})
 }
+
+func TestStore_Ranked(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns keys from least to most frequently used", func(t *testing.T) {
+		t.Parallel()
+
+		store := allkeyslfu.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		_, _ = store.Get(1)
+		_, _ = store.Get(1)
+		_, _ = store.Get(3)
+		require.Equal(t, []int{2, 3, 1}, store.Ranked())
+	})
+}
+
+func TestStore_SetFrequency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets the frequency of stored keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := allkeyslfu.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.SetFrequency(1, 5)
+		store.SetFrequency(3, 5)
+		require.Equal(t, 5, store.Frequency(1))
+		require.Equal(t, 1, store.Frequency(2))
+		require.Zero(t, store.Frequency(3))
+		require.Equal(t, []int{2, 1}, store.Ranked())
+	})
+}
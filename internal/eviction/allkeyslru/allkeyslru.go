@@ -3,8 +3,9 @@ package allkeyslru
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/ports"
 	"github.com/wafer-bw/memcache/internal/substore/randxs"
 )
@@ -16,8 +17,12 @@ const (
 )
 
 type Store[K comparable, V any] struct {
-	mu       sync.RWMutex
-	capacity int
+	mu        sync.RWMutex
+	capacity  int
+	onRemove  data.RemoveFunc[K, V]
+	clock     data.Clock
+	evictions atomic.Uint64
+	weight    int64
 
 	items        map[K]data.Item[K, V]   // primary storage of key-value pairs
 	randomAccess ports.RandomAccessor[K] // permits random key selection
@@ -25,32 +30,35 @@ type Store[K comparable, V any] struct {
 	list         *list.List              // component of the linked list
 }
 
-func New[K comparable, V any](capacity int) *Store[K, V] {
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
 	if capacity < MinimumCapacity {
 		capacity = DefaultCapacity
 	}
 
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	size := min(capacity, DefaultCapacity)
+
 	return &Store[K, V]{
 		capacity:     capacity,
-		items:        make(map[K]data.Item[K, V], capacity),
-		randomAccess: randxs.New[K](capacity),
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
 		list:         list.New(),
-		elements:     make(map[K]*list.Element, capacity),
+		elements:     make(map[K]*list.Element, size),
 	}
 }
 
 func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	evicted := s.add(key, item)
+	s.mu.Unlock()
 
-	s.randomAccess.Add(key)
-	s.items[key] = item
-	element := s.list.PushFront(key)
-	s.elements[key] = element
-
-	if len(s.items) > s.capacity {
-		s.evict()
-	}
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
 }
 
 func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
@@ -67,13 +75,63 @@ func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
 	return item, ok
 }
 
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
 func (s *Store[K, V]) Remove(keys ...K) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
+	var removed []data.Entry[K, V]
 	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
 		s.delete(key)
 	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version, reporting whether it did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+
+	return true
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
 }
 
 func (s *Store[K, V]) Len() int {
@@ -83,6 +141,33 @@ func (s *Store[K, V]) Len() int {
 	return len(s.items)
 }
 
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.weight
+}
+
+// Evictions returns the number of items the store has evicted to remain within
+// its capacity.
+func (s *Store[K, V]) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+// Ranked returns every key ordered from the least to the most recently used.
+func (s *Store[K, V]) Ranked() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, s.list.Len())
+	for element := s.list.Back(); element != nil; element = element.Prev() {
+		key, _ := element.Value.(K)
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
 func (s *Store[K, V]) RandomKey() (K, bool) {
 	return s.randomAccess.RandomKey()
 }
@@ -113,17 +198,47 @@ func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
 
 func (s *Store[K, V]) Flush() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	clear(s.items)
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.weight = 0
 	s.randomAccess.Clear()
 	s.list.Init()
 	clear(s.elements)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item, returning the entries evicted to make room for it. s.mu must
+// be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) []data.Entry[K, V] {
+	s.randomAccess.Add(key)
+	if existing, ok := s.items[key]; ok {
+		s.weight -= existing.Cost()
+	}
+	s.weight += item.Cost()
+	s.items[key] = item
+	if element, ok := s.elements[key]; ok {
+		s.list.MoveToFront(element)
+	} else {
+		s.elements[key] = s.list.PushFront(key)
+	}
+
+	var evicted []data.Entry[K, V]
+	for s.weight > int64(s.capacity) {
+		evicted = append(evicted, s.evict())
+	}
+
+	return evicted
 }
 
-func (s *Store[K, V]) evict() {
+func (s *Store[K, V]) evict() data.Entry[K, V] {
 	key, _ := s.list.Back().Value.(K)
+	item := s.items[key]
 	s.delete(key)
+
+	return data.Entry[K, V]{Key: key, Item: item}
 }
 
 func (s *Store[K, V]) delete(key K) {
@@ -132,6 +247,7 @@ func (s *Store[K, V]) delete(key K) {
 		return
 	}
 
+	s.weight -= s.items[key].Cost()
 	s.randomAccess.Remove(key)
 	delete(s.items, key)
 	s.list.Remove(element)
@@ -0,0 +1,117 @@
+package allkeysrandom_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeysrandom"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*allkeysrandom.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*allkeysrandom.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := allkeysrandom.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := allkeysrandom.New[int, int](allkeysrandom.MinimumCapacity-1, nil, nil)
+		require.Equal(t, allkeysrandom.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores key and value", func(t *testing.T) {
+		t.Parallel()
+
+		key, val := 1, 10
+		store := allkeysrandom.New[int, int](2, nil, nil)
+		store.Add(key, data.Item[int, int]{Value: val})
+
+		items := store.Items()
+		require.Len(t, items, 1)
+		require.Equal(t, val, items[key].Value)
+	})
+
+	t.Run("evicts a random existing key when at capacity", func(t *testing.T) {
+		t.Parallel()
+
+		evictedKeys := map[int]struct{}{}
+		for i := 0; i < 100; i++ {
+			var evictedKey int
+			store := allkeysrandom.New[int, int](2, func(key int, _ data.Item[int, int], _ data.Reason) {
+				evictedKey = key
+			}, nil)
+			store.Add(1, data.Item[int, int]{Value: 1})
+			store.Add(2, data.Item[int, int]{Value: 2})
+			store.Add(3, data.Item[int, int]{Value: 3})
+
+			items := store.Items()
+			require.Len(t, items, 2)
+			require.Contains(t, items, 3)
+			require.NotContains(t, items, evictedKey)
+			evictedKeys[evictedKey] = struct{}{}
+		}
+		require.Equal(t, map[int]struct{}{1: {}, 2: {}}, evictedKeys)
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := allkeysrandom.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("evicts random keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := allkeysrandom.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		for i := 1; i <= 5; i++ {
+			store.Add(i, data.Item[int, int]{Value: i, Weight: 2})
+		}
+		store.Add(6, data.Item[int, int]{Value: 6, Weight: 6})
+
+		require.Len(t, evicted, 3)
+		require.NotContains(t, evicted, 6)
+		require.Equal(t, int64(10), store.Weight())
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		store := allkeysrandom.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Flush()
+
+		items := store.Items()
+		require.Empty(t, items)
+	})
+}
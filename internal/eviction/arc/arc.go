@@ -0,0 +1,360 @@
+package arc
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/randxs"
+)
+
+const (
+	PolicyName      string = "arc"
+	DefaultCapacity int    = 10_000
+	MinimumCapacity int    = 2
+)
+
+type segment int
+
+const (
+	t1 segment = iota // resident keys seen once recently.
+	t2                // resident keys seen at least twice recently.
+	b1                // ghost keys recently evicted from t1.
+	b2                // ghost keys recently evicted from t2.
+)
+
+type entry[K comparable] struct {
+	key     K
+	cost    int64
+	segment segment
+}
+
+// Store is an Adaptive Replacement Cache store. It balances between recency
+// and frequency by tracking the keys it recently evicted from each (ghosts)
+// and growing the target size of whichever list those ghosts are found in.
+type Store[K comparable, V any] struct {
+	mu        sync.RWMutex
+	capacity  int
+	onRemove  data.RemoveFunc[K, V]
+	clock     data.Clock
+	evictions atomic.Uint64
+	p         int64 // target weight of t1, adapted on ghost hits.
+
+	items        map[K]data.Item[K, V]   // primary storage of key-value pairs
+	randomAccess ports.RandomAccessor[K] // permits random key selection
+	elements     map[K]*list.Element     // component of the segment lists, including ghosts
+	segments     [b2 + 1]*list.List      // t1, t2, b1 & b2 lru lists
+	weights      [b2 + 1]int64           // total cost of the keys in each list
+}
+
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
+	if capacity < MinimumCapacity {
+		capacity = DefaultCapacity
+	}
+
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	size := min(capacity, DefaultCapacity)
+
+	s := &Store[K, V]{
+		capacity:     capacity,
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
+		elements:     make(map[K]*list.Element, 2*size),
+	}
+	for i := range s.segments {
+		s.segments[i] = list.New()
+	}
+
+	return s
+}
+
+func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
+	s.mu.Lock()
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+}
+
+func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return item, ok
+	}
+
+	s.move(s.elements[key], t2)
+
+	return item, ok
+}
+
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *Store[K, V]) Remove(keys ...K) {
+	s.mu.Lock()
+
+	var removed []data.Entry[K, V]
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
+		s.delete(key)
+	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version, reporting whether it did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+
+	return true
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
+}
+
+func (s *Store[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.resident()
+}
+
+// Evictions returns the number of items the store has evicted to remain within
+// its capacity.
+func (s *Store[K, V]) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+func (s *Store[K, V]) RandomKey() (K, bool) {
+	return s.randomAccess.RandomKey()
+}
+
+func (s *Store[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make(map[K]data.Item[K, V], len(s.items))
+	for key, item := range s.items {
+		items[key] = item
+	}
+
+	return items
+}
+
+func (s *Store[K, V]) Flush() {
+	s.mu.Lock()
+
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.randomAccess.Clear()
+	clear(s.elements)
+	for _, l := range s.segments {
+		l.Init()
+	}
+	clear(s.weights[:])
+	s.p = 0
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item, returning the entries evicted to make room for it. s.mu must
+// be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) []data.Entry[K, V] {
+	cost, capacity := item.Cost(), int64(s.capacity)
+	var evicted []data.Entry[K, V]
+
+	if _, ok := s.items[key]; ok {
+		s.resize(s.elements[key], cost)
+		s.move(s.elements[key], t2)
+		for len(s.items) > 1 && s.resident() > capacity {
+			evicted = append(evicted, s.replace(false, s.elements[key]))
+		}
+	} else if element, ok := s.elements[key]; ok {
+		// ghost hit: the key was evicted too early so grow the target size of
+		// the list it was evicted from before making room for it.
+		e, _ := element.Value.(*entry[K])
+		b1Weight, b2Weight := s.weights[b1], s.weights[b2]
+		if e.segment == b1 {
+			s.p = min(capacity, s.p+max(b2Weight/b1Weight, 1)*e.cost)
+		} else {
+			s.p = max(0, s.p-max(b1Weight/b2Weight, 1)*e.cost)
+		}
+
+		for len(s.items) > 0 && s.resident()+cost > capacity {
+			evicted = append(evicted, s.replace(e.segment == b2, nil))
+		}
+		s.resize(element, cost)
+		s.move(element, t2)
+	} else {
+		for s.weights[t1]+s.weights[b1]+cost > capacity && s.segments[b1].Len() > 0 {
+			s.forget(s.segments[b1].Back())
+		}
+		for s.weights[t1]+s.weights[b1]+cost > capacity && s.segments[t1].Len() > 0 {
+			evicted = append(evicted, s.drop(s.segments[t1].Back()))
+		}
+		for s.total()+cost > 2*capacity && s.segments[b2].Len() > 0 {
+			s.forget(s.segments[b2].Back())
+		}
+		for len(s.items) > 0 && s.resident()+cost > capacity {
+			evicted = append(evicted, s.replace(false, nil))
+		}
+
+		s.elements[key] = s.segments[t1].PushFront(&entry[K]{key: key, cost: cost, segment: t1})
+		s.weights[t1] += cost
+	}
+
+	s.randomAccess.Add(key)
+	s.items[key] = item
+
+	return evicted
+}
+
+// replace evicts the lru key of t1 or t2 into its ghost list depending on
+// whether t1 is larger than its target size, never selecting skip.
+func (s *Store[K, V]) replace(inB2 bool, skip *list.Element) data.Entry[K, V] {
+	from, other := t2, t1
+	if s.segments[t1].Len() > 0 && (s.weights[t1] > s.p || (inB2 && s.weights[t1] == s.p) || s.segments[t2].Len() == 0) {
+		from, other = t1, t2
+	}
+
+	element := s.segments[from].Back()
+	if element == skip {
+		from, element = other, s.segments[other].Back()
+	}
+
+	if from == t1 {
+		return s.ghost(element, b1)
+	}
+	return s.ghost(element, b2)
+}
+
+// ghost evicts the resident key of element, remembering it in the provided
+// ghost list.
+func (s *Store[K, V]) ghost(element *list.Element, to segment) data.Entry[K, V] {
+	e, _ := element.Value.(*entry[K])
+	item := s.items[e.key]
+	delete(s.items, e.key)
+	s.randomAccess.Remove(e.key)
+	s.move(element, to)
+
+	return data.Entry[K, V]{Key: e.key, Item: item}
+}
+
+// drop evicts the resident key of element without remembering it.
+func (s *Store[K, V]) drop(element *list.Element) data.Entry[K, V] {
+	e, _ := element.Value.(*entry[K])
+	item := s.items[e.key]
+	s.delete(e.key)
+
+	return data.Entry[K, V]{Key: e.key, Item: item}
+}
+
+// forget removes a ghost key.
+func (s *Store[K, V]) forget(element *list.Element) {
+	e, _ := element.Value.(*entry[K])
+	s.segments[e.segment].Remove(element)
+	s.weights[e.segment] -= e.cost
+	delete(s.elements, e.key)
+}
+
+// move element to the front of the provided segment.
+func (s *Store[K, V]) move(element *list.Element, to segment) {
+	e, _ := element.Value.(*entry[K])
+	s.segments[e.segment].Remove(element)
+	s.weights[e.segment] -= e.cost
+	e.segment = to
+	s.elements[e.key] = s.segments[to].PushFront(e)
+	s.weights[to] += e.cost
+}
+
+// resize changes the cost of element within its list.
+func (s *Store[K, V]) resize(element *list.Element, cost int64) {
+	e, _ := element.Value.(*entry[K])
+	s.weights[e.segment] += cost - e.cost
+	e.cost = cost
+}
+
+// resident returns the total cost of resident keys.
+func (s *Store[K, V]) resident() int64 {
+	return s.weights[t1] + s.weights[t2]
+}
+
+// total returns the total cost of resident & ghost keys.
+func (s *Store[K, V]) total() int64 {
+	return s.resident() + s.weights[b1] + s.weights[b2]
+}
+
+func (s *Store[K, V]) delete(key K) {
+	if _, ok := s.items[key]; !ok {
+		return
+	}
+
+	s.forget(s.elements[key])
+	delete(s.items, key)
+	s.randomAccess.Remove(key)
+}
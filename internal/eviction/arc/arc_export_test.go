@@ -0,0 +1,22 @@
+package arc
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
+
+// export for testing.
+func (s *Store[K, V]) P() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int(s.p)
+}
+
+// export for testing.
+func (s *Store[K, V]) SegmentLens() (t1Len, t2Len, b1Len, b2Len int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.segments[t1].Len(), s.segments[t2].Len(), s.segments[b1].Len(), s.segments[b2].Len()
+}
@@ -0,0 +1,196 @@
+package arc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/arc"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*arc.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*arc.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := arc.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](arc.MinimumCapacity-1, nil, nil)
+		require.Equal(t, arc.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores new keys in the recency list", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+
+		require.Equal(t, 10, store.Items()[1].Value)
+		t1Len, t2Len, _, _ := store.SegmentLens()
+		require.Equal(t, 1, t1Len)
+		require.Zero(t, t2Len)
+	})
+
+	t.Run("moves accessed keys to the frequency list", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		_, _ = store.Get(1)
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		t1Len, t2Len, _, _ := store.SegmentLens()
+		require.Zero(t, t1Len)
+		require.Equal(t, 2, t2Len)
+		require.Equal(t, 21, store.Items()[2].Value)
+	})
+
+	t.Run("keeps frequently used keys when scanned by keys used once", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(1)
+		_, _ = store.Get(2)
+		for i := 100; i < 110; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		items := store.Items()
+		require.Len(t, items, 4)
+		require.Contains(t, items, 1)
+		require.Contains(t, items, 2)
+	})
+
+	t.Run("remembers evicted keys as ghosts and adapts on ghost hits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := arc.New[int, int](2, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(2)
+		store.Add(3, data.Item[int, int]{Value: 3})
+		require.Equal(t, []int{1}, evicted)
+
+		_, _, b1Len, _ := store.SegmentLens()
+		require.Equal(t, 1, b1Len)
+		require.Zero(t, store.P())
+
+		store.Add(1, data.Item[int, int]{Value: 1})
+		require.Equal(t, 1, store.P())
+		require.Contains(t, store.Items(), 1)
+		require.Len(t, store.Items(), 2)
+	})
+
+	t.Run("bounds the number of ghost keys", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 4
+		store := arc.New[int, int](capacity, nil, nil)
+		for i := 0; i < 100; i++ {
+			store.Add(i%13, data.Item[int, int]{Value: i})
+			if i%3 == 0 {
+				_, _ = store.Get(i % 7)
+			}
+
+			t1Len, t2Len, b1Len, b2Len := store.SegmentLens()
+			require.LessOrEqual(t, t1Len+t2Len, capacity)
+			require.LessOrEqual(t, t1Len+b1Len, capacity)
+			require.LessOrEqual(t, t1Len+t2Len+b1Len+b2Len, 2*capacity)
+		}
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := arc.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(1, data.Item[int, int]{Value: 11})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("evicts keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := arc.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 3})
+		store.Add(4, data.Item[int, int]{Value: 4, Weight: 4})
+		require.Equal(t, []int{1}, evicted)
+
+		_, _ = store.Get(2)
+		store.Add(5, data.Item[int, int]{Value: 5, Weight: 5})
+		require.Equal(t, []int{1, 3, 4}, evicted)
+		require.ElementsMatch(t, []int{2, 5}, store.Keys())
+		require.Equal(t, int64(8), store.Weight())
+		_, _, b1Len, _ := store.SegmentLens()
+		require.Equal(t, 1, b1Len)
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes key without remembering it as a ghost", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(2)
+		store.Remove(1, 2)
+
+		require.Empty(t, store.Items())
+		t1Len, t2Len, b1Len, b2Len := store.SegmentLens()
+		require.Zero(t, t1Len+t2Len+b1Len+b2Len)
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys, values and ghosts", func(t *testing.T) {
+		t.Parallel()
+
+		store := arc.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		t1Len, t2Len, b1Len, b2Len := store.SegmentLens()
+		require.Zero(t, t1Len+t2Len+b1Len+b2Len)
+		require.Zero(t, store.P())
+	})
+}
@@ -3,7 +3,7 @@ package noevict
 import (
 	"sync"
 
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/ports"
 	"github.com/wafer-bw/memcache/internal/substore/randxs"
 )
@@ -12,25 +12,40 @@ const (
 	PolicyName      string = "noevict"
 	DefaultCapacity int    = 0
 	MinimumCapacity int    = 0
+
+	// capacity is a total weight rather than a number of keys, so it is only
+	// used to size allocations up to this many keys.
+	maxPreallocation = 10_000
 )
 
 type Store[K comparable, V any] struct {
 	mu           sync.RWMutex
 	capacity     int
+	onRemove     data.RemoveFunc[K, V]
+	clock        data.Clock
+	weight       int64
 	items        map[K]data.Item[K, V]   // primary storage of key-value pairs
 	randomAccess ports.RandomAccessor[K] // permits random key selection
 }
 
-func New[K comparable, V any](capacity int) *Store[K, V] {
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
 	if capacity < 0 {
 		capacity = DefaultCapacity
 	}
 
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	size := min(capacity, maxPreallocation)
+
 	return &Store[K, V]{
 		mu:           sync.RWMutex{},
 		capacity:     capacity,
-		items:        make(map[K]data.Item[K, V], capacity),
-		randomAccess: randxs.New[K](capacity),
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
 	}
 }
 
@@ -38,12 +53,7 @@ func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.atCapacity() {
-		return
-	}
-
-	s.randomAccess.Add(key)
-	s.items[key] = item
+	s.add(key, item)
 }
 
 func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
@@ -54,13 +64,60 @@ func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
 	return item, ok
 }
 
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
 func (s *Store[K, V]) Remove(keys ...K) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
+	var removed []data.Entry[K, V]
 	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
 		s.delete(key)
 	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version and item fits within the capacity of the store, reporting whether it
+// did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		return false
+	}
+
+	return s.add(key, item)
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
 }
 
 func (s *Store[K, V]) Len() int {
@@ -70,6 +127,13 @@ func (s *Store[K, V]) Len() int {
 	return len(s.items)
 }
 
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.weight
+}
+
 func (s *Store[K, V]) RandomKey() (K, bool) {
 	return s.randomAccess.RandomKey()
 }
@@ -100,17 +164,55 @@ func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
 
 func (s *Store[K, V]) Flush() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	clear(s.items)
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.weight = 0
 	s.randomAccess.Clear()
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item if it fits within the capacity of the store, reporting
+// whether it did. s.mu must be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) bool {
+	if !s.fits(key, item) {
+		return false
+	}
+
+	if existing, ok := s.items[key]; ok {
+		s.weight -= existing.Cost()
+	}
+	s.randomAccess.Add(key)
+	s.weight += item.Cost()
+	s.items[key] = item
+
+	return true
 }
 
 func (s *Store[K, V]) delete(key K) {
+	item, ok := s.items[key]
+	if !ok {
+		return
+	}
+
+	s.weight -= item.Cost()
 	s.randomAccess.Remove(key)
 	delete(s.items, key)
 }
 
-func (s *Store[K, V]) atCapacity() bool {
-	return s.capacity > 0 && len(s.items) >= s.capacity
+// fits reports whether item can be stored as key without the store breaching
+// its capacity.
+func (s *Store[K, V]) fits(key K, item data.Item[K, V]) bool {
+	if s.capacity == 0 {
+		return true
+	}
+
+	weight := s.weight + item.Cost()
+	if existing, ok := s.items[key]; ok {
+		weight -= existing.Cost()
+	}
+
+	return weight <= int64(s.capacity)
 }
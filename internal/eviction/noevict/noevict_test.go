@@ -4,13 +4,15 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/eviction/noevict"
 	"github.com/wafer-bw/memcache/internal/ports"
 )
 
 var _ ports.Storer[int, int] = (*noevict.Store[int, int])(nil)
 
+var _ ports.Swapper[int, int] = (*noevict.Store[int, int])(nil)
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 
@@ -18,14 +20,14 @@ func TestNew(t *testing.T) {
 		t.Parallel()
 
 		capacity := 10
-		store := noevict.New[int, int](capacity)
+		store := noevict.New[int, int](capacity, nil, nil)
 		require.Equal(t, capacity, store.Capacity())
 	})
 
 	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
 		t.Parallel()
 
-		store := noevict.New[int, int](noevict.MinimumCapacity - 1)
+		store := noevict.New[int, int](noevict.MinimumCapacity-1, nil, nil)
 		require.Equal(t, noevict.DefaultCapacity, store.Capacity())
 	})
 }
@@ -37,7 +39,7 @@ func TestStore_Set(t *testing.T) {
 		t.Parallel()
 
 		key, val := 1, 10
-		store := noevict.New[int, int](0)
+		store := noevict.New[int, int](0, nil, nil)
 		store.Add(key, data.Item[int, int]{Value: val})
 
 		items := store.Items()
@@ -48,7 +50,7 @@ func TestStore_Set(t *testing.T) {
 	t.Run("does not add more keys when at capacity", func(t *testing.T) {
 		t.Parallel()
 
-		store := noevict.New[int, int](2)
+		store := noevict.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 10})
 		store.Add(1, data.Item[int, int]{Value: 10})
 		store.Add(1, data.Item[int, int]{Value: 10})
@@ -60,6 +62,33 @@ func TestStore_Set(t *testing.T) {
 		require.Contains(t, items, 1)
 		require.Contains(t, items, 2)
 	})
+	t.Run("updates existing keys when at capacity", func(t *testing.T) {
+		t.Parallel()
+
+		store := noevict.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(1, data.Item[int, int]{Value: 11})
+
+		items := store.Items()
+		require.Len(t, items, 2)
+		require.Equal(t, 11, items[1].Value)
+	})
+
+	t.Run("ignores items which would breach the capacity by weight", func(t *testing.T) {
+		t.Parallel()
+
+		store := noevict.New[int, int](10, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 6})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 5})
+		store.Add(1, data.Item[int, int]{Value: 10, Weight: 11})
+		require.Equal(t, 1, store.Items()[1].Value)
+		require.NotContains(t, store.Items(), 2)
+
+		store.Add(1, data.Item[int, int]{Value: 10, Weight: 9})
+		require.Equal(t, 10, store.Items()[1].Value)
+		require.Equal(t, int64(9), store.Weight())
+	})
 }
 
 func TestStore_Flush(t *testing.T) {
@@ -68,7 +97,7 @@ func TestStore_Flush(t *testing.T) {
 	t.Run("clears all keys and values", func(t *testing.T) {
 		t.Parallel()
 
-		store := noevict.New[int, int](2)
+		store := noevict.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2})
 		store.Flush()
@@ -0,0 +1,382 @@
+package s3fifo
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/randxs"
+)
+
+const (
+	PolicyName      string = "s3fifo"
+	DefaultCapacity int    = 10_000
+	MinimumCapacity int    = 2
+
+	smallPercent     = 10 // percentage of capacity used by the small queue.
+	maxFrequency     = 3  // access frequency of keys saturates at this value.
+	promoteFrequency = 0  // keys accessed more than this while small are promoted to main.
+)
+
+type queue int
+
+const (
+	small queue = iota
+	main
+	ghost
+)
+
+type entry[K comparable] struct {
+	key   K
+	cost  int64
+	queue queue
+	freq  atomic.Int32
+}
+
+// Store is an S3-FIFO store. New keys enter a small fifo queue and are only
+// promoted to the main fifo queue if they are accessed again before reaching
+// its end, quickly removing keys which are only accessed once. Recently
+// evicted small keys are remembered in a ghost queue and go straight to main
+// if added again.
+//
+// Because a hit only increments the frequency of a key atomically,
+// [Store.Get] only acquires a read lock.
+type Store[K comparable, V any] struct {
+	mu        sync.RWMutex
+	capacity  int64
+	smallCap  int64
+	ghostCap  int64
+	onRemove  data.RemoveFunc[K, V]
+	clock     data.Clock
+	evictions atomic.Uint64
+
+	items        map[K]data.Item[K, V]   // primary storage of key-value pairs
+	randomAccess ports.RandomAccessor[K] // permits random key selection
+	elements     map[K]*list.Element     // component of the queues, including ghosts
+	queues       [ghost + 1]*list.List   // small, main & ghost fifo queues
+	weights      [ghost + 1]int64        // total cost of the keys in each queue
+}
+
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
+	if capacity < MinimumCapacity {
+		capacity = DefaultCapacity
+	}
+
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	smallCap := max(1, int64(capacity)*smallPercent/100)
+	size := min(capacity, DefaultCapacity)
+
+	s := &Store[K, V]{
+		capacity:     int64(capacity),
+		smallCap:     smallCap,
+		ghostCap:     int64(capacity) - smallCap,
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
+		elements:     make(map[K]*list.Element, 2*size),
+	}
+	for i := range s.queues {
+		s.queues[i] = list.New()
+	}
+
+	return s
+}
+
+func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
+	s.mu.Lock()
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+}
+
+func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return item, ok
+	}
+
+	s.hit(s.elements[key])
+
+	return item, ok
+}
+
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *Store[K, V]) Remove(keys ...K) {
+	s.mu.Lock()
+
+	var removed []data.Entry[K, V]
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
+		s.delete(key)
+	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version, reporting whether it did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+
+	return true
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
+}
+
+func (s *Store[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.weight()
+}
+
+// Evictions returns the number of items the store has evicted to remain within
+// its capacity.
+func (s *Store[K, V]) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+func (s *Store[K, V]) RandomKey() (K, bool) {
+	return s.randomAccess.RandomKey()
+}
+
+func (s *Store[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make(map[K]data.Item[K, V], len(s.items))
+	for key, item := range s.items {
+		items[key] = item
+	}
+
+	return items
+}
+
+func (s *Store[K, V]) Flush() {
+	s.mu.Lock()
+
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.randomAccess.Clear()
+	clear(s.elements)
+	for _, q := range s.queues {
+		q.Init()
+	}
+	clear(s.weights[:])
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item, returning the entries evicted to make room for it. s.mu must
+// be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) []data.Entry[K, V] {
+	others, incoming := len(s.items), item.Cost()
+	element := s.elements[key]
+	_, resident := s.items[key]
+	if resident {
+		s.hit(element)
+		s.resize(element, incoming)
+		others, incoming = others-1, 0
+	}
+
+	// evict before adding so that the added key cannot be selected.
+	var evicted []data.Entry[K, V]
+	for ; others > 0 && s.weight()+incoming > s.capacity; others-- {
+		entry, ok := s.evict(key)
+		if !ok {
+			break
+		}
+		evicted = append(evicted, entry)
+	}
+
+	if !resident {
+		if ghosted, ok := s.elements[key]; ok {
+			s.resize(ghosted, item.Cost())
+			s.move(ghosted, main)
+		} else {
+			s.elements[key] = s.queues[small].PushFront(&entry[K]{key: key, cost: item.Cost(), queue: small})
+			s.weights[small] += item.Cost()
+		}
+		s.randomAccess.Add(key)
+	}
+	s.items[key] = item
+
+	return evicted
+}
+
+// hit atomically increments the frequency of element up to its maximum.
+func (s *Store[K, V]) hit(element *list.Element) {
+	e, _ := element.Value.(*entry[K])
+	for {
+		freq := e.freq.Load()
+		if freq >= maxFrequency || e.freq.CompareAndSwap(freq, freq+1) {
+			return
+		}
+	}
+}
+
+// evict a key from the small or main queue, never selecting skip, or report
+// false if skip is the only key left. Keys are compared rather than elements
+// because moving a key between queues replaces its element.
+func (s *Store[K, V]) evict(skip K) (data.Entry[K, V], bool) {
+	for {
+		smallLen, mainLen := s.queues[small].Len(), s.queues[main].Len()
+		onlySkip := false
+		if mainLen == 1 {
+			e, _ := s.queues[main].Back().Value.(*entry[K])
+			onlySkip = e.key == skip
+		}
+		if smallLen == 0 && (mainLen == 0 || onlySkip) {
+			return data.Entry[K, V]{}, false
+		}
+
+		// evict from small regardless of its weight if main holds nothing else.
+		if smallLen > 0 && (s.weights[small] >= s.smallCap || mainLen == 0 || onlySkip) {
+			element := s.queues[small].Back()
+			e, _ := element.Value.(*entry[K])
+			if e.key == skip || e.freq.Load() > promoteFrequency {
+				e.freq.Store(0)
+				s.move(element, main)
+				continue
+			}
+
+			item := s.items[e.key]
+			s.delete(e.key)
+			s.remember(e.key, e.cost)
+			return data.Entry[K, V]{Key: e.key, Item: item}, true
+		}
+
+		element := s.queues[main].Back()
+		e, _ := element.Value.(*entry[K])
+		if e.key == skip {
+			s.queues[main].MoveToFront(element)
+			continue
+		}
+		if freq := e.freq.Load(); freq > 0 {
+			e.freq.Store(freq - 1)
+			s.queues[main].MoveToFront(element)
+			continue
+		}
+
+		item := s.items[e.key]
+		s.delete(e.key)
+		return data.Entry[K, V]{Key: e.key, Item: item}, true
+	}
+}
+
+// remember key in the ghost queue, forgetting the oldest ghosts if it is full.
+func (s *Store[K, V]) remember(key K, cost int64) {
+	for s.queues[ghost].Len() > 0 && s.weights[ghost]+cost > s.ghostCap {
+		oldest := s.queues[ghost].Back()
+		e, _ := oldest.Value.(*entry[K])
+		s.queues[ghost].Remove(oldest)
+		s.weights[ghost] -= e.cost
+		delete(s.elements, e.key)
+	}
+
+	s.elements[key] = s.queues[ghost].PushFront(&entry[K]{key: key, cost: cost, queue: ghost})
+	s.weights[ghost] += cost
+}
+
+// move element to the front of the provided queue.
+func (s *Store[K, V]) move(element *list.Element, to queue) {
+	e, _ := element.Value.(*entry[K])
+	s.queues[e.queue].Remove(element)
+	s.weights[e.queue] -= e.cost
+	e.queue = to
+	s.elements[e.key] = s.queues[to].PushFront(e)
+	s.weights[to] += e.cost
+}
+
+// resize changes the cost of element within its queue.
+func (s *Store[K, V]) resize(element *list.Element, cost int64) {
+	e, _ := element.Value.(*entry[K])
+	s.weights[e.queue] += cost - e.cost
+	e.cost = cost
+}
+
+// weight returns the total cost of resident keys.
+func (s *Store[K, V]) weight() int64 {
+	return s.weights[small] + s.weights[main]
+}
+
+func (s *Store[K, V]) delete(key K) {
+	if _, ok := s.items[key]; !ok {
+		return
+	}
+
+	element := s.elements[key]
+	e, _ := element.Value.(*entry[K])
+	s.queues[e.queue].Remove(element)
+	s.weights[e.queue] -= e.cost
+	delete(s.elements, key)
+	delete(s.items, key)
+	s.randomAccess.Remove(key)
+}
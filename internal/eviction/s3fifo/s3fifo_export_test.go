@@ -0,0 +1,23 @@
+package s3fifo
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return int(s.capacity)
+}
+
+// export for testing.
+func (s *Store[K, V]) QueueLens() (smallLen, mainLen, ghostLen int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queues[small].Len(), s.queues[main].Len(), s.queues[ghost].Len()
+}
+
+// export for testing.
+func (s *Store[K, V]) Frequency(key K) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, _ := s.elements[key].Value.(*entry[K])
+	return int(e.freq.Load())
+}
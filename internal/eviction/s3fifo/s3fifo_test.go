@@ -0,0 +1,297 @@
+package s3fifo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/s3fifo"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*s3fifo.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*s3fifo.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := s3fifo.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](s3fifo.MinimumCapacity-1, nil, nil)
+		require.Equal(t, s3fifo.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores new keys in the small queue", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](10, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+
+		require.Equal(t, 10, store.Items()[1].Value)
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Equal(t, 1, smallLen)
+		require.Zero(t, mainLen+ghostLen)
+	})
+
+	t.Run("evicts keys which were not accessed from the small queue into the ghost queue", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](10, func(key int, item data.Item[int, int], _ data.Reason) {
+			require.Equal(t, key*10, item.Value)
+			evicted = append(evicted, key)
+		}, nil)
+		for i := 0; i <= 10; i++ {
+			store.Add(i, data.Item[int, int]{Value: i * 10})
+		}
+
+		require.Equal(t, []int{0}, evicted)
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Equal(t, 10, smallLen)
+		require.Zero(t, mainLen)
+		require.Equal(t, 1, ghostLen)
+	})
+
+	t.Run("promotes accessed keys from the small queue to the main queue", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		for i := 0; i < 10; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+		_, _ = store.Get(0)
+		store.Add(10, data.Item[int, int]{Value: 10})
+
+		require.Equal(t, []int{1}, evicted)
+		require.Contains(t, store.Items(), 0)
+		require.Zero(t, store.Frequency(0))
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Equal(t, 9, smallLen)
+		require.Equal(t, 1, mainLen)
+		require.Equal(t, 1, ghostLen)
+	})
+
+	t.Run("adds ghost keys straight to the main queue", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](10, nil, nil)
+		for i := 0; i <= 10; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+		store.Add(0, data.Item[int, int]{Value: 0})
+
+		require.Contains(t, store.Items(), 0)
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Equal(t, 9, smallLen)
+		require.Equal(t, 1, mainLen)
+		require.Equal(t, 1, ghostLen)
+	})
+
+	t.Run("reinserts accessed keys in the main queue instead of evicting them", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](2, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		_, _ = store.Get(1)
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3}) // promotes 1 to main & evicts 2.
+		_, _ = store.Get(1)
+		store.Add(4, data.Item[int, int]{Value: 4}) // evicts 3.
+		store.Add(5, data.Item[int, int]{Value: 5}) // evicts 4.
+
+		require.Equal(t, []int{2, 3, 4}, evicted)
+		require.ElementsMatch(t, []int{1, 5}, store.Keys())
+	})
+
+	t.Run("caps the access frequency of keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		for i := 0; i < 10; i++ {
+			_, _ = store.Get(1)
+		}
+
+		require.Equal(t, 3, store.Frequency(1))
+	})
+
+	t.Run("bounds the number of ghost keys", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := s3fifo.New[int, int](capacity, nil, nil)
+		for i := 0; i < 100; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+			if i%3 == 0 {
+				_, _ = store.Get(i)
+			}
+
+			smallLen, mainLen, ghostLen := store.QueueLens()
+			require.LessOrEqual(t, smallLen+mainLen, capacity)
+			require.LessOrEqual(t, ghostLen, capacity)
+		}
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := s3fifo.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(1, data.Item[int, int]{Value: 11})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+		require.Equal(t, 11, store.Items()[1].Value)
+	})
+
+	t.Run("evicts keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 4})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 4})
+		_, _ = store.Get(1)
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 4})
+		require.Equal(t, []int{2}, evicted)
+
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 4})
+		require.Equal(t, []int{2, 3}, evicted)
+		require.ElementsMatch(t, []int{1, 2}, store.Keys())
+		require.Equal(t, int64(8), store.Weight())
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Zero(t, smallLen)
+		require.Equal(t, 2, mainLen)
+		require.Equal(t, 1, ghostLen)
+	})
+
+	t.Run("never evicts the key being replaced with a heavier item", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		store.Add(1, data.Item[int, int]{Value: 10, Weight: 9})
+
+		require.Equal(t, []int{2}, evicted)
+		require.Equal(t, 10, store.Items()[1].Value)
+		require.Equal(t, int64(9), store.Weight())
+	})
+
+	t.Run("never evicts a small key being replaced with a heavier item after moving it to main", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 5})
+		_, _ = store.Get(1)
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 5})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 1}) // promotes 1 to main & evicts 2.
+		_, _ = store.Get(1)
+		store.Add(3, data.Item[int, int]{Value: 30, Weight: 6}) // moves 3 to main & evicts 1.
+
+		require.Equal(t, []int{2, 1}, evicted)
+		require.Equal(t, 1, store.Len())
+		require.Equal(t, int64(6), store.Weight())
+		item, ok := store.Get(3)
+		require.True(t, ok)
+		require.Equal(t, 30, item.Value)
+	})
+
+	t.Run("evicts small keys regardless of their weight when main only holds the key being replaced", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := s3fifo.New[int, int](100, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 50})
+		_, _ = store.Get(1)
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 50})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 1}) // promotes 1 to main & evicts 2.
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			store.Add(1, data.Item[int, int]{Value: 10, Weight: 100})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("replacing the only key in main with a heavier item did not return")
+		}
+
+		require.Equal(t, []int{2, 3}, evicted)
+		require.Equal(t, 1, store.Len())
+		require.Equal(t, int64(100), store.Weight())
+		item, ok := store.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 10, item.Value)
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes key without remembering it as a ghost", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Remove(1, 2, 3)
+
+		require.Empty(t, store.Items())
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Zero(t, smallLen+mainLen+ghostLen)
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys, values and ghosts", func(t *testing.T) {
+		t.Parallel()
+
+		store := s3fifo.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		smallLen, mainLen, ghostLen := store.QueueLens()
+		require.Zero(t, smallLen+mainLen+ghostLen)
+	})
+}
@@ -0,0 +1,287 @@
+package sieve
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/randxs"
+)
+
+const (
+	PolicyName      string = "sieve"
+	DefaultCapacity int    = 10_000
+	MinimumCapacity int    = 2
+)
+
+type entry[K comparable] struct {
+	key     K
+	visited atomic.Bool
+}
+
+// Store is a SIEVE store. Keys are kept in insertion order and a hand sweeps
+// from oldest to newest, sparing keys visited since it last passed them.
+//
+// Because a hit only sets the visited flag of a key atomically, [Store.Get]
+// only acquires a read lock.
+type Store[K comparable, V any] struct {
+	mu        sync.RWMutex
+	capacity  int
+	onRemove  data.RemoveFunc[K, V]
+	clock     data.Clock
+	evictions atomic.Uint64
+	weight    int64
+
+	items        map[K]data.Item[K, V]   // primary storage of key-value pairs
+	randomAccess ports.RandomAccessor[K] // permits random key selection
+	elements     map[K]*list.Element     // component of the fifo queue
+	queue        *list.List              // keys ordered from newest (front) to oldest (back)
+	hand         *list.Element           // next eviction candidate, nil to start at the back
+}
+
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
+	if capacity < MinimumCapacity {
+		capacity = DefaultCapacity
+	}
+
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	size := min(capacity, DefaultCapacity)
+
+	return &Store[K, V]{
+		capacity:     capacity,
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
+		elements:     make(map[K]*list.Element, size),
+		queue:        list.New(),
+	}
+}
+
+func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
+	s.mu.Lock()
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+}
+
+func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return item, ok
+	}
+
+	e, _ := s.elements[key].Value.(*entry[K])
+	e.visited.Store(true)
+
+	return item, ok
+}
+
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *Store[K, V]) Remove(keys ...K) {
+	s.mu.Lock()
+
+	var removed []data.Entry[K, V]
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
+		s.delete(key)
+	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version, reporting whether it did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+
+	return true
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
+}
+
+func (s *Store[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.weight
+}
+
+// Evictions returns the number of items the store has evicted to remain within
+// its capacity.
+func (s *Store[K, V]) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+func (s *Store[K, V]) RandomKey() (K, bool) {
+	return s.randomAccess.RandomKey()
+}
+
+func (s *Store[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make(map[K]data.Item[K, V], len(s.items))
+	for key, item := range s.items {
+		items[key] = item
+	}
+
+	return items
+}
+
+func (s *Store[K, V]) Flush() {
+	s.mu.Lock()
+
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.weight = 0
+	s.randomAccess.Clear()
+	clear(s.elements)
+	s.queue.Init()
+	s.hand = nil
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item, returning the entries evicted to make room for it. s.mu must
+// be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) []data.Entry[K, V] {
+	others := len(s.items)
+	element, exists := s.elements[key]
+	if exists {
+		e, _ := element.Value.(*entry[K])
+		e.visited.Store(true)
+		s.weight -= s.items[key].Cost()
+		others--
+	}
+
+	// evict before adding so that the added key cannot be selected.
+	var evicted []data.Entry[K, V]
+	for ; others > 0 && s.weight+item.Cost() > int64(s.capacity); others-- {
+		evicted = append(evicted, s.evict(element))
+	}
+
+	if !exists {
+		s.randomAccess.Add(key)
+		s.elements[key] = s.queue.PushFront(&entry[K]{key: key})
+	}
+	s.weight += item.Cost()
+	s.items[key] = item
+
+	return evicted
+}
+
+// evict the first key the hand finds which was not visited since it last
+// passed, never selecting skip.
+func (s *Store[K, V]) evict(skip *list.Element) data.Entry[K, V] {
+	cursor := s.hand
+	if cursor == nil {
+		cursor = s.queue.Back()
+	}
+
+	for {
+		e, _ := cursor.Value.(*entry[K])
+		if cursor != skip {
+			if !e.visited.Load() {
+				break
+			}
+			e.visited.Store(false)
+		}
+
+		cursor = cursor.Prev()
+		if cursor == nil {
+			cursor = s.queue.Back()
+		}
+	}
+
+	// deleting the key under the hand moves the hand to the next key.
+	s.hand = cursor
+	e, _ := cursor.Value.(*entry[K])
+	item := s.items[e.key]
+	s.delete(e.key)
+
+	return data.Entry[K, V]{Key: e.key, Item: item}
+}
+
+func (s *Store[K, V]) delete(key K) {
+	element, ok := s.elements[key]
+	if !ok {
+		return
+	}
+
+	s.weight -= s.items[key].Cost()
+	if s.hand == element {
+		s.hand = element.Prev()
+	}
+
+	s.randomAccess.Remove(key)
+	delete(s.items, key)
+	s.queue.Remove(element)
+	delete(s.elements, key)
+}
@@ -0,0 +1,23 @@
+package sieve
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
+
+// export for testing.
+func (s *Store[K, V]) Visited(key K) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, _ := s.elements[key].Value.(*entry[K])
+	return e.visited.Load()
+}
+
+// export for testing.
+func (s *Store[K, V]) QueueLen() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.queue.Len()
+}
@@ -0,0 +1,200 @@
+package sieve_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/sieve"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*sieve.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*sieve.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := sieve.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := sieve.New[int, int](sieve.MinimumCapacity-1, nil, nil)
+		require.Equal(t, sieve.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("evicts the oldest key when no keys were visited", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](3, func(key int, item data.Item[int, int], _ data.Reason) {
+			require.Equal(t, key*10, item.Value)
+			evicted = append(evicted, key)
+		}, nil)
+		for i := 1; i <= 4; i++ {
+			store.Add(i, data.Item[int, int]{Value: i * 10})
+		}
+
+		require.Equal(t, []int{1}, evicted)
+		require.Len(t, store.Items(), 3)
+		require.NotContains(t, store.Items(), 1)
+	})
+
+	t.Run("spares visited keys and clears their visited bit", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](3, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(3, data.Item[int, int]{Value: 30})
+		_, _ = store.Get(1)
+		require.True(t, store.Visited(1))
+
+		store.Add(4, data.Item[int, int]{Value: 40})
+		require.Equal(t, []int{2}, evicted)
+		require.Contains(t, store.Items(), 1)
+		require.False(t, store.Visited(1))
+	})
+
+	t.Run("evicts keys in a single pass when all keys were visited", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](3, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		for i := 1; i <= 3; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+			_, _ = store.Get(i)
+		}
+
+		store.Add(4, data.Item[int, int]{Value: 4})
+		require.Equal(t, []int{1}, evicted)
+		require.Len(t, store.Items(), 3)
+	})
+
+	t.Run("re-adding an existing key marks it visited without evicting", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := sieve.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(1, data.Item[int, int]{Value: 11})
+
+		require.Zero(t, evictions)
+		require.True(t, store.Visited(1))
+		require.Equal(t, 11, store.Items()[1].Value)
+	})
+
+	t.Run("evicts unvisited keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 3})
+		_, _ = store.Get(1)
+		store.Add(4, data.Item[int, int]{Value: 4, Weight: 4})
+		require.Equal(t, []int{2}, evicted)
+
+		store.Add(5, data.Item[int, int]{Value: 5, Weight: 7})
+		require.Equal(t, []int{2, 3, 4}, evicted)
+		require.ElementsMatch(t, []int{1, 5}, store.Keys())
+		require.Equal(t, int64(10), store.Weight())
+	})
+
+	t.Run("never evicts the key being replaced with a heavier item", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		store.Add(1, data.Item[int, int]{Value: 10, Weight: 9})
+
+		require.Equal(t, []int{2}, evicted)
+		require.Equal(t, 10, store.Items()[1].Value)
+		require.Equal(t, int64(9), store.Weight())
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes keys from the queue", func(t *testing.T) {
+		t.Parallel()
+
+		store := sieve.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Remove(1, 2, 3)
+
+		require.Empty(t, store.Items())
+		require.Zero(t, store.QueueLen())
+	})
+
+	t.Run("moves the hand when removing the key it points to", func(t *testing.T) {
+		t.Parallel()
+
+		var evicted []int
+		store := sieve.New[int, int](3, func(key int, _ data.Item[int, int], reason data.Reason) {
+			if reason == data.Evicted {
+				evicted = append(evicted, key)
+			}
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		_, _ = store.Get(1)
+		store.Add(4, data.Item[int, int]{Value: 4}) // evicts 2 leaving the hand on 3.
+		store.Remove(3)
+
+		store.Add(5, data.Item[int, int]{Value: 5})
+		store.Add(6, data.Item[int, int]{Value: 6})
+		require.Equal(t, []int{2, 4}, evicted)
+		require.ElementsMatch(t, []int{1, 5, 6}, store.Keys())
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		store := sieve.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		require.Zero(t, store.QueueLen())
+
+		store.Add(4, data.Item[int, int]{Value: 4})
+		store.Add(5, data.Item[int, int]{Value: 5})
+		store.Add(6, data.Item[int, int]{Value: 6})
+		require.ElementsMatch(t, []int{5, 6}, store.Keys())
+	})
+}
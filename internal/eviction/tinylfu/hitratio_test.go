@@ -0,0 +1,101 @@
+package tinylfu_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslru"
+	"github.com/wafer-bw/memcache/internal/eviction/tinylfu"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+func hitRatio(store ports.Storer[int, int], trace []int) float64 {
+	hits := 0
+	for _, key := range trace {
+		if _, ok := store.Get(key); ok {
+			hits++
+			continue
+		}
+		store.Add(key, data.Item[int, int]{Value: key})
+	}
+
+	return float64(hits) / float64(len(trace))
+}
+
+func zipfTrace(r *rand.Rand, n int, keys uint64) []int {
+	zipf := rand.NewZipf(r, 1.01, 1, keys-1)
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(zipf.Uint64())
+	}
+
+	return trace
+}
+
+// scanTrace interleaves a zipf distributed workload with long scans of keys
+// which are only ever accessed once.
+func scanTrace(r *rand.Rand, n int, keys uint64, scanLen int) []int {
+	zipf := rand.NewZipf(r, 1.01, 1, keys-1)
+	trace := make([]int, 0, n)
+	next := int(keys)
+	for len(trace) < n {
+		for i := 0; i < scanLen && len(trace) < n; i++ {
+			trace = append(trace, int(zipf.Uint64()))
+		}
+		for i := 0; i < scanLen && len(trace) < n; i++ {
+			trace = append(trace, next)
+			next++
+		}
+	}
+
+	return trace
+}
+
+// shiftTrace is a zipf distributed workload whose popular keys change every
+// phase, which favors policies that adapt to recent popularity.
+func shiftTrace(r *rand.Rand, n int, keys uint64, phases int) []int {
+	zipf := rand.NewZipf(r, 1.01, 1, keys-1)
+	trace := make([]int, n)
+	phaseLen := n / phases
+	for i := range trace {
+		offset := (i / phaseLen) * int(keys)
+		trace[i] = offset + int(zipf.Uint64())
+	}
+
+	return trace
+}
+
+func TestStore_hitRatio(t *testing.T) {
+	t.Parallel()
+
+	capacity := 1000
+	tests := map[string]struct {
+		trace []int
+		// lfuMargin is how far below allkeyslfu the tinylfu hit ratio may fall.
+		// allkeyslfu never ages its exact counts which is optimal for a static
+		// distribution but not for one whose popular keys change.
+		lfuMargin float64
+	}{
+		"zipf":  {trace: zipfTrace(rand.New(rand.NewSource(1)), 200_000, 100_000), lfuMargin: 0.01},
+		"scan":  {trace: scanTrace(rand.New(rand.NewSource(1)), 200_000, 100_000, 2*capacity), lfuMargin: 0.01},
+		"shift": {trace: shiftTrace(rand.New(rand.NewSource(1)), 200_000, 100_000, 4), lfuMargin: -0.1},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tiny := hitRatio(tinylfu.New[int, int](capacity, nil, nil), tt.trace)
+			lru := hitRatio(allkeyslru.New[int, int](capacity, nil, nil), tt.trace)
+			lfu := hitRatio(allkeyslfu.New[int, int](capacity, nil, nil), tt.trace)
+			t.Logf("tinylfu=%.4f allkeyslru=%.4f allkeyslfu=%.4f", tiny, lru, lfu)
+
+			require.Greater(t, tiny, lru, "tinylfu should outperform allkeyslru")
+			require.GreaterOrEqual(t, tiny, lfu-tt.lfuMargin, "tinylfu should keep up with allkeyslfu")
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package tinylfu
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/hashing"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/cmsketch"
+	"github.com/wafer-bw/memcache/internal/substore/randxs"
+)
+
+const (
+	PolicyName      string = "tinylfu"
+	DefaultCapacity int    = 10_000
+	MinimumCapacity int    = 2
+
+	windowPercent    = 1  // percentage of capacity used by the admission window.
+	protectedPercent = 80 // percentage of the main segment used by protected keys.
+)
+
+type segment int
+
+const (
+	window segment = iota
+	probation
+	protected
+)
+
+type entry[K comparable] struct {
+	key     K
+	cost    int64
+	segment segment
+}
+
+// Store is a W-TinyLFU store. New keys enter a small LRU admission window and,
+// once pushed out of it, must be estimated to be accessed more frequently than
+// the eviction victim of the main segmented LRU in order to be admitted.
+type Store[K comparable, V any] struct {
+	mu           sync.RWMutex
+	capacity     int
+	windowCap    int64
+	protectedCap int64
+	onRemove     data.RemoveFunc[K, V]
+	clock        data.Clock
+	evictions    atomic.Uint64
+
+	items        map[K]data.Item[K, V]     // primary storage of key-value pairs
+	randomAccess ports.RandomAccessor[K]   // permits random key selection
+	sketch       ports.FrequencySketch[K]  // estimates access frequency of keys
+	elements     map[K]*list.Element       // component of the segment lists
+	segments     [protected + 1]*list.List // window, probation & protected lru lists
+	weights      [protected + 1]int64      // total cost of the keys in each segment
+}
+
+func New[K comparable, V any](capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) *Store[K, V] {
+	if capacity < MinimumCapacity {
+		capacity = DefaultCapacity
+	}
+
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	windowCap := max(1, int64(capacity)*windowPercent/100)
+	mainCap := int64(capacity) - windowCap
+	size := min(capacity, DefaultCapacity)
+
+	s := &Store[K, V]{
+		capacity:     capacity,
+		windowCap:    windowCap,
+		protectedCap: mainCap * protectedPercent / 100,
+		onRemove:     onRemove,
+		clock:        clock,
+		items:        make(map[K]data.Item[K, V], size),
+		randomAccess: randxs.New[K](size),
+		sketch:       cmsketch.New[K](size, hashing.Default[K]()),
+		elements:     make(map[K]*list.Element, size),
+	}
+	for i := range s.segments {
+		s.segments[i] = list.New()
+	}
+
+	return s
+}
+
+func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
+	s.mu.Lock()
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+}
+
+func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sketch.Inc(key)
+
+	item, ok := s.items[key]
+	if !ok {
+		return item, ok
+	}
+
+	s.touch(s.elements[key])
+
+	return item, ok
+}
+
+// Peek returns the item of key without counting it as an access of key.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *Store[K, V]) Remove(keys ...K) {
+	s.mu.Lock()
+
+	var removed []data.Entry[K, V]
+	for _, key := range keys {
+		if item, ok := s.items[key]; ok {
+			removed = append(removed, data.Entry[K, V]{Key: key, Item: item})
+		}
+		s.delete(key)
+	}
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved(removed, s.clock.Now())
+}
+
+// CompareAndSwap adds key & item only if key holds an unexpired item of
+// version, reporting whether it did.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	s.mu.Lock()
+	if existing, ok := s.items[key]; !ok || existing.Version != version || existing.IsExpiredAt(s.clock.Now()) {
+		s.mu.Unlock()
+		return false
+	}
+	evicted := s.add(key, item)
+	s.mu.Unlock()
+
+	s.evictions.Add(uint64(len(evicted)))
+	s.onRemove.NotifyEvicted(evicted)
+
+	return true
+}
+
+// CompareAndRemove removes key only if it holds an unexpired item of version,
+// reporting whether it did.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	s.mu.Lock()
+	now := s.clock.Now()
+	item, ok := s.items[key]
+	if !ok || item.Version != version || item.IsExpiredAt(now) {
+		s.mu.Unlock()
+		return false
+	}
+	s.delete(key)
+	s.mu.Unlock()
+
+	s.onRemove.NotifyRemoved([]data.Entry[K, V]{{Key: key, Item: item}}, now)
+
+	return true
+}
+
+func (s *Store[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+func (s *Store[K, V]) Weight() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.weight()
+}
+
+// Evictions returns the number of items the store has evicted to remain within
+// its capacity.
+func (s *Store[K, V]) Evictions() uint64 {
+	return s.evictions.Load()
+}
+
+func (s *Store[K, V]) RandomKey() (K, bool) {
+	return s.randomAccess.RandomKey()
+}
+
+func (s *Store[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make(map[K]data.Item[K, V], len(s.items))
+	for key, item := range s.items {
+		items[key] = item
+	}
+
+	return items
+}
+
+func (s *Store[K, V]) Flush() {
+	s.mu.Lock()
+
+	flushed := s.items
+	s.items = make(map[K]data.Item[K, V], len(flushed))
+	s.randomAccess.Clear()
+	s.sketch.Clear()
+	clear(s.elements)
+	for _, l := range s.segments {
+		l.Init()
+	}
+	clear(s.weights[:])
+	s.mu.Unlock()
+
+	s.onRemove.NotifyFlushed(flushed, s.clock.Now())
+}
+
+// add key & item, returning the entries evicted to make room for it. s.mu must
+// be held.
+func (s *Store[K, V]) add(key K, item data.Item[K, V]) []data.Entry[K, V] {
+	s.sketch.Inc(key)
+	if element, ok := s.elements[key]; ok {
+		s.resize(element, item.Cost())
+		s.touch(element)
+	} else {
+		s.randomAccess.Add(key)
+		s.elements[key] = s.segments[window].PushFront(&entry[K]{key: key, cost: item.Cost(), segment: window})
+		s.weights[window] += item.Cost()
+	}
+	s.items[key] = item
+	s.sketch.Grow(len(s.items))
+
+	var evicted []data.Entry[K, V]
+
+	// keys pushed out of the full window become candidates for admission to
+	// the main segment.
+	for s.weights[window] > s.windowCap {
+		candidate := s.move(s.segments[window].Back(), probation)
+		c, _ := candidate.Value.(*entry[K])
+		for admitting := true; admitting && s.weight() > int64(s.capacity); {
+			evictee := s.evict(candidate)
+			admitting = evictee.Key != c.key
+			evicted = append(evicted, evictee)
+		}
+	}
+
+	for s.weight() > int64(s.capacity) {
+		evicted = append(evicted, s.evict(nil))
+	}
+
+	return evicted
+}
+
+// touch records a hit of element, promoting probation keys to protected.
+func (s *Store[K, V]) touch(element *list.Element) {
+	e, _ := element.Value.(*entry[K])
+	switch e.segment {
+	case window, protected:
+		s.segments[e.segment].MoveToFront(element)
+	case probation:
+		s.move(element, protected)
+		for s.weights[protected] > s.protectedCap {
+			s.move(s.segments[protected].Back(), probation)
+		}
+	}
+}
+
+// move element to the front of the provided segment, returning its new element.
+func (s *Store[K, V]) move(element *list.Element, to segment) *list.Element {
+	e, _ := element.Value.(*entry[K])
+	s.segments[e.segment].Remove(element)
+	s.weights[e.segment] -= e.cost
+	e.segment = to
+	s.elements[e.key] = s.segments[to].PushFront(e)
+	s.weights[to] += e.cost
+
+	return s.elements[e.key]
+}
+
+// resize changes the cost of element within its segment.
+func (s *Store[K, V]) resize(element *list.Element, cost int64) {
+	e, _ := element.Value.(*entry[K])
+	s.weights[e.segment] += cost - e.cost
+	e.cost = cost
+}
+
+// weight returns the total cost of all keys.
+func (s *Store[K, V]) weight() int64 {
+	return s.weights[window] + s.weights[probation] + s.weights[protected]
+}
+
+// evict either the admission candidate or the main segment's victim, whichever
+// is estimated to be accessed less frequently. Without a candidate the victim
+// is always evicted.
+func (s *Store[K, V]) evict(candidate *list.Element) data.Entry[K, V] {
+	victim := s.segments[probation].Back()
+	if victim == nil || victim == candidate {
+		victim = s.segments[protected].Back()
+	}
+	if victim == nil && candidate == nil {
+		victim = s.segments[window].Back()
+	}
+
+	var key K
+	if victim != nil {
+		v, _ := victim.Value.(*entry[K])
+		key = v.key
+	}
+	if candidate != nil {
+		c, _ := candidate.Value.(*entry[K])
+		if victim == nil || s.sketch.Estimate(c.key) <= s.sketch.Estimate(key) {
+			key = c.key
+		}
+	}
+
+	item := s.items[key]
+	s.delete(key)
+
+	return data.Entry[K, V]{Key: key, Item: item}
+}
+
+func (s *Store[K, V]) delete(key K) {
+	element, ok := s.elements[key]
+	if !ok {
+		return
+	}
+
+	e, _ := element.Value.(*entry[K])
+	s.segments[e.segment].Remove(element)
+	s.weights[e.segment] -= e.cost
+	delete(s.elements, key)
+	delete(s.items, key)
+	s.randomAccess.Remove(key)
+}
@@ -0,0 +1,14 @@
+package tinylfu
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
+
+// export for testing.
+func (s *Store[K, V]) SegmentLens() (windowLen, probationLen, protectedLen int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.segments[window].Len(), s.segments[probation].Len(), s.segments[protected].Len()
+}
@@ -0,0 +1,190 @@
+package tinylfu_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/tinylfu"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*tinylfu.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*tinylfu.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := tinylfu.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](tinylfu.MinimumCapacity-1, nil, nil)
+		require.Equal(t, tinylfu.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores key and value in the admission window", func(t *testing.T) {
+		t.Parallel()
+
+		key, val := 1, 10
+		store := tinylfu.New[int, int](100, nil, nil)
+		store.Add(key, data.Item[int, int]{Value: val})
+
+		items := store.Items()
+		require.Len(t, items, 1)
+		require.Equal(t, val, items[key].Value)
+
+		windowLen, probationLen, protectedLen := store.SegmentLens()
+		require.Equal(t, 1, windowLen)
+		require.Zero(t, probationLen)
+		require.Zero(t, protectedLen)
+	})
+
+	t.Run("moves keys pushed out of the window to probation", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](100, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+
+		windowLen, probationLen, _ := store.SegmentLens()
+		require.Equal(t, 1, windowLen)
+		require.Equal(t, 1, probationLen)
+	})
+
+	t.Run("promotes probation keys to protected when accessed", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](100, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(1)
+
+		_, probationLen, protectedLen := store.SegmentLens()
+		require.Zero(t, probationLen)
+		require.Equal(t, 1, protectedLen)
+	})
+
+	t.Run("rejects new keys accessed less frequently than the victim", func(t *testing.T) {
+		t.Parallel()
+
+		var evictedKey int
+		store := tinylfu.New[int, int](2, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evictedKey = key
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		for i := 0; i < 5; i++ {
+			_, _ = store.Get(1)
+		}
+		store.Add(3, data.Item[int, int]{Value: 3})
+
+		items := store.Items()
+		require.Contains(t, items, 1)
+		require.Contains(t, items, 3)
+		require.Equal(t, 2, evictedKey)
+
+		store.Add(4, data.Item[int, int]{Value: 4})
+		items = store.Items()
+		require.Contains(t, items, 1)
+		require.Contains(t, items, 4)
+		require.Equal(t, 3, evictedKey)
+	})
+
+	t.Run("admits new keys accessed more frequently than the victim", func(t *testing.T) {
+		t.Parallel()
+
+		var evictedKey int
+		store := tinylfu.New[int, int](2, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evictedKey = key
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		for i := 0; i < 5; i++ {
+			_, _ = store.Get(2)
+		}
+		store.Add(3, data.Item[int, int]{Value: 3})
+
+		items := store.Items()
+		require.Contains(t, items, 2)
+		require.Contains(t, items, 3)
+		require.Equal(t, 1, evictedKey)
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := tinylfu.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(1, data.Item[int, int]{Value: 11})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("evicts keys until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](10, nil, nil)
+		for i := 0; i < 20; i++ {
+			store.Add(i, data.Item[int, int]{Value: i, Weight: int64(i%4 + 1)})
+			require.LessOrEqual(t, store.Weight(), int64(10))
+		}
+
+		var weight int64
+		for _, item := range store.Items() {
+			weight += item.Cost()
+		}
+		require.Equal(t, weight, store.Weight())
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes key from all structures", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](100, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(1)
+		store.Remove(1, 2)
+
+		require.Empty(t, store.Items())
+		windowLen, probationLen, protectedLen := store.SegmentLens()
+		require.Zero(t, windowLen+probationLen+protectedLen)
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		store := tinylfu.New[int, int](100, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		windowLen, probationLen, protectedLen := store.SegmentLens()
+		require.Zero(t, windowLen+probationLen+protectedLen)
+	})
+}
@@ -0,0 +1,14 @@
+package volatilelfu
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
+
+// export for testing.
+func (s *Store[K, V]) VolatileLen() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.volatileLFU.Len()
+}
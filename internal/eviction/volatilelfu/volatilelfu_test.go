@@ -0,0 +1,202 @@
+package volatilelfu_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilelfu"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*volatilelfu.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*volatilelfu.Store[int, int])(nil)
+
+var _ ports.Ranker[int] = (*volatilelfu.Store[int, int])(nil)
+
+var _ ports.FrequencyTracker[int] = (*volatilelfu.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := volatilelfu.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilelfu.New[int, int](volatilelfu.MinimumCapacity-1, nil, nil)
+		require.Equal(t, volatilelfu.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores key and value", func(t *testing.T) {
+		t.Parallel()
+
+		key, val := 1, 10
+		store := volatilelfu.New[int, int](2, nil, nil)
+		store.Add(key, data.Item[int, int]{Value: val})
+
+		items := store.Items()
+		require.Len(t, items, 1)
+		require.Equal(t, val, items[key].Value)
+	})
+
+	t.Run("evicts least frequently used key with a ttl when at capacity", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilelfu.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2, ExpireAt: &expireAt})
+		store.Add(3, data.Item[int, int]{Value: 3, ExpireAt: &expireAt})
+		_, _ = store.Get(2)
+		_, _ = store.Get(2)
+		_, _ = store.Get(3)
+		store.Add(4, data.Item[int, int]{Value: 4})
+
+		items := store.Items()
+		require.Contains(t, items, 1)
+		require.Contains(t, items, 2)
+		require.NotContains(t, items, 3)
+		require.Contains(t, items, 4)
+	})
+
+	t.Run("evicts least frequently used key when no keys have a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilelfu.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		_, _ = store.Get(1)
+		store.Add(3, data.Item[int, int]{Value: 3})
+
+		items := store.Items()
+		require.Contains(t, items, 1)
+		require.NotContains(t, items, 2)
+		require.Contains(t, items, 3)
+	})
+
+	t.Run("stops tracking key as volatile when it is set without a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilelfu.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		require.Equal(t, 1, store.VolatileLen())
+
+		store.Add(1, data.Item[int, int]{Value: 1})
+		require.Zero(t, store.VolatileLen())
+	})
+
+	t.Run("calls onRemove with evicted key and item after eviction", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evictedKey, evictedValue int
+		store := volatilelfu.New[int, int](2, func(key int, item data.Item[int, int], _ data.Reason) {
+			evictedKey, evictedValue = key, item.Value
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(3, data.Item[int, int]{Value: 30})
+
+		require.Equal(t, 1, evictedKey)
+		require.Equal(t, 10, evictedValue)
+	})
+
+	t.Run("evicts least frequently used keys with a ttl first until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evicted []int
+		store := volatilelfu.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 3})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 8})
+
+		require.Equal(t, []int{1, 3}, evicted)
+		require.Equal(t, int64(8), store.Weight())
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes key from all structures", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilelfu.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		store.Remove(1)
+
+		require.Empty(t, store.Items())
+		require.Zero(t, store.VolatileLen())
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilelfu.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		require.Zero(t, store.VolatileLen())
+	})
+}
+
+func TestStore_Ranked(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns keys from least to most frequently used", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilelfu.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		_, _ = store.Get(1)
+		_, _ = store.Get(1)
+		_, _ = store.Get(3)
+		require.Equal(t, []int{2, 3, 1}, store.Ranked())
+	})
+}
+
+func TestStore_SetFrequency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets the frequency of stored keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilelfu.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.SetFrequency(1, 5)
+		store.SetFrequency(3, 5)
+		require.Equal(t, 5, store.Frequency(1))
+		require.Equal(t, 1, store.Frequency(2))
+		require.Zero(t, store.Frequency(3))
+		require.Equal(t, []int{2, 1}, store.Ranked())
+	})
+}
@@ -5,13 +5,17 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/eviction/volatilelru"
 	"github.com/wafer-bw/memcache/internal/ports"
 )
 
 var _ ports.Storer[int, int] = (*volatilelru.Store[int, int])(nil)
 
+var _ ports.Swapper[int, int] = (*volatilelru.Store[int, int])(nil)
+
+var _ ports.Ranker[int] = (*volatilelru.Store[int, int])(nil)
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 
@@ -19,14 +23,14 @@ func TestNew(t *testing.T) {
 		t.Parallel()
 
 		capacity := 10
-		store := volatilelru.New[int, int](capacity)
+		store := volatilelru.New[int, int](capacity, nil, nil)
 		require.Equal(t, capacity, store.Capacity())
 	})
 
 	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
 		t.Parallel()
 
-		store := volatilelru.New[int, int](volatilelru.MinimumCapacity - 1)
+		store := volatilelru.New[int, int](volatilelru.MinimumCapacity-1, nil, nil)
 		require.Equal(t, volatilelru.DefaultCapacity, store.Capacity())
 	})
 }
@@ -38,7 +42,7 @@ func TestStore_Set(t *testing.T) {
 		t.Parallel()
 
 		key, val := 1, 10
-		store := volatilelru.New[int, int](2)
+		store := volatilelru.New[int, int](2, nil, nil)
 		store.Add(key, data.Item[int, int]{Value: val})
 
 		elements, unlock := store.Elements()
@@ -60,7 +64,7 @@ func TestStore_Set(t *testing.T) {
 		t.Parallel()
 
 		expireAt := time.Now().Add(1 * time.Hour)
-		store := volatilelru.New[int, int](3)
+		store := volatilelru.New[int, int](3, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2, ExpireAt: &expireAt})
 		store.Add(3, data.Item[int, int]{Value: 3, ExpireAt: &expireAt})
@@ -76,7 +80,7 @@ func TestStore_Set(t *testing.T) {
 	t.Run("evicts least recently used key when at capacity no keys have a ttl", func(t *testing.T) {
 		t.Parallel()
 
-		store := volatilelru.New[int, int](2)
+		store := volatilelru.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2})
 		_, _ = store.Get(1)
@@ -86,6 +90,53 @@ func TestStore_Set(t *testing.T) {
 		require.Contains(t, items, 1)
 		require.Contains(t, items, 3)
 	})
+	t.Run("calls onRemove with evicted key and item after eviction", func(t *testing.T) {
+		t.Parallel()
+
+		var evictedKey, evictedValue int
+		store := volatilelru.New[int, int](2, func(key int, item data.Item[int, int], _ data.Reason) {
+			evictedKey, evictedValue = key, item.Value
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		_, _ = store.Get(1)
+		store.Add(3, data.Item[int, int]{Value: 30})
+
+		require.Equal(t, 2, evictedKey)
+		require.Equal(t, 20, evictedValue)
+	})
+
+	t.Run("re-adding an existing key does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		evictions := 0
+		store := volatilelru.New[int, int](2, func(int, data.Item[int, int], data.Reason) { evictions++ }, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(1, data.Item[int, int]{Value: 11})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(2, data.Item[int, int]{Value: 21})
+
+		require.Zero(t, evictions)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("evicts least recently used keys with a ttl first until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evicted []int
+		store := volatilelru.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 4})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 4, ExpireAt: &expireAt})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 4})
+		require.Equal(t, []int{2}, evicted)
+
+		store.Add(4, data.Item[int, int]{Value: 4, Weight: 9})
+		require.Equal(t, []int{2, 1, 3}, evicted)
+		require.Equal(t, int64(9), store.Weight())
+	})
 }
 
 func TestStore_Flush(t *testing.T) {
@@ -94,7 +145,7 @@ func TestStore_Flush(t *testing.T) {
 	t.Run("clears all keys and values", func(t *testing.T) {
 		t.Parallel()
 
-		store := volatilelru.New[int, int](2)
+		store := volatilelru.New[int, int](2, nil, nil)
 		store.Add(1, data.Item[int, int]{Value: 1})
 		store.Add(2, data.Item[int, int]{Value: 2})
 		store.Flush()
@@ -111,3 +162,18 @@ func TestStore_Flush(t *testing.T) {
 		unlock()
 	})
 }
+
+func TestStore_Ranked(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns keys from least to most recently used", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilelru.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+		_, _ = store.Get(1)
+		require.Equal(t, []int{2, 3, 1}, store.Ranked())
+	})
+}
@@ -0,0 +1,6 @@
+package volatilerandom
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
@@ -0,0 +1,134 @@
+package volatilerandom_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilerandom"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var _ ports.Storer[int, int] = (*volatilerandom.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*volatilerandom.Store[int, int])(nil)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := volatilerandom.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilerandom.New[int, int](volatilerandom.MinimumCapacity-1, nil, nil)
+		require.Equal(t, volatilerandom.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores key and value", func(t *testing.T) {
+		t.Parallel()
+
+		key, val := 1, 10
+		store := volatilerandom.New[int, int](2, nil, nil)
+		store.Add(key, data.Item[int, int]{Value: val})
+
+		items := store.Items()
+		require.Len(t, items, 1)
+		require.Equal(t, val, items[key].Value)
+	})
+
+	t.Run("evicts a random key with a ttl when at capacity", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		for i := 0; i < 100; i++ {
+			store := volatilerandom.New[int, int](3, nil, nil)
+			store.Add(1, data.Item[int, int]{Value: 1})
+			store.Add(2, data.Item[int, int]{Value: 2, ExpireAt: &expireAt})
+			store.Add(3, data.Item[int, int]{Value: 3})
+			store.Add(4, data.Item[int, int]{Value: 4})
+
+			items := store.Items()
+			require.Len(t, items, 3)
+			require.NotContains(t, items, 2)
+		}
+	})
+
+	t.Run("evicts a random key when no keys have a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilerandom.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+
+		items := store.Items()
+		require.Len(t, items, 2)
+		require.Contains(t, items, 3)
+	})
+
+	t.Run("calls onRemove with evicted key and item after eviction", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evictedKey, evictedValue int
+		store := volatilerandom.New[int, int](2, func(key int, item data.Item[int, int], _ data.Reason) {
+			evictedKey, evictedValue = key, item.Value
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20, ExpireAt: &expireAt})
+		store.Add(3, data.Item[int, int]{Value: 30})
+
+		require.Equal(t, 2, evictedKey)
+		require.Equal(t, 20, evictedValue)
+	})
+
+	t.Run("evicts random keys with a ttl first until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evicted []int
+		store := volatilerandom.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 2, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 2, ExpireAt: &expireAt})
+		for i := 3; i <= 5; i++ {
+			store.Add(i, data.Item[int, int]{Value: i, Weight: 2})
+		}
+		store.Add(6, data.Item[int, int]{Value: 6, Weight: 4})
+
+		require.ElementsMatch(t, []int{1, 2}, evicted)
+		require.Equal(t, int64(10), store.Weight())
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilerandom.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Flush()
+
+		items := store.Items()
+		require.Empty(t, items)
+	})
+}
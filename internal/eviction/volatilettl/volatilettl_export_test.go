@@ -0,0 +1,14 @@
+package volatilettl
+
+// export for testing.
+func (s *Store[K, V]) Capacity() int {
+	return s.capacity
+}
+
+// export for testing.
+func (s *Store[K, V]) ExpiriesLen() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.expiries.Len()
+}
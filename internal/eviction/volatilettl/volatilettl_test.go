@@ -0,0 +1,162 @@
+package volatilettl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilettl"
+	"github.com/wafer-bw/memcache/internal/expire"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+var (
+	_ ports.Storer[int, int]  = (*volatilettl.Store[int, int])(nil)
+	_ ports.Swapper[int, int] = (*volatilettl.Store[int, int])(nil)
+	_ expire.Indexer[int]     = (*volatilettl.Store[int, int])(nil)
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a new store with provided capacity", func(t *testing.T) {
+		t.Parallel()
+
+		capacity := 10
+		store := volatilettl.New[int, int](capacity, nil, nil)
+		require.Equal(t, capacity, store.Capacity())
+	})
+
+	t.Run("returns a new store with default capacity when provided an invalid one", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilettl.New[int, int](volatilettl.MinimumCapacity-1, nil, nil)
+		require.Equal(t, volatilettl.DefaultCapacity, store.Capacity())
+	})
+}
+
+func TestStore_Set(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stores key and value", func(t *testing.T) {
+		t.Parallel()
+
+		key, val := 1, 10
+		store := volatilettl.New[int, int](2, nil, nil)
+		store.Add(key, data.Item[int, int]{Value: val})
+
+		items := store.Items()
+		require.Len(t, items, 1)
+		require.Equal(t, val, items[key].Value)
+	})
+
+	t.Run("evicts key with nearest expiry when at capacity", func(t *testing.T) {
+		t.Parallel()
+
+		soon, later := time.Now().Add(1*time.Minute), time.Now().Add(2*time.Minute)
+		store := volatilettl.New[int, int](3, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2, ExpireAt: &later})
+		store.Add(3, data.Item[int, int]{Value: 3, ExpireAt: &soon})
+		store.Add(4, data.Item[int, int]{Value: 4})
+
+		items := store.Items()
+		require.Contains(t, items, 1)
+		require.Contains(t, items, 2)
+		require.NotContains(t, items, 3)
+		require.Contains(t, items, 4)
+	})
+
+	t.Run("evicts a random other key when no keys have a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		store := volatilettl.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Add(3, data.Item[int, int]{Value: 3})
+
+		items := store.Items()
+		require.Len(t, items, 2)
+		require.Contains(t, items, 3)
+	})
+
+	t.Run("stops tracking expiry of key when it is set without a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilettl.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		require.Equal(t, 1, store.ExpiriesLen())
+
+		store.Add(1, data.Item[int, int]{Value: 1})
+		require.Zero(t, store.ExpiriesLen())
+	})
+
+	t.Run("calls onRemove with evicted key and item after eviction", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		var evictedKey, evictedValue int
+		store := volatilettl.New[int, int](2, func(key int, item data.Item[int, int], _ data.Reason) {
+			evictedKey, evictedValue = key, item.Value
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20, ExpireAt: &expireAt})
+		store.Add(3, data.Item[int, int]{Value: 30})
+
+		require.Equal(t, 2, evictedKey)
+		require.Equal(t, 20, evictedValue)
+	})
+
+	t.Run("evicts keys nearest to expiring until the weight fits", func(t *testing.T) {
+		t.Parallel()
+
+		soon, later := time.Now().Add(1*time.Minute), time.Now().Add(2*time.Minute)
+		var evicted []int
+		store := volatilettl.New[int, int](10, func(key int, _ data.Item[int, int], _ data.Reason) {
+			evicted = append(evicted, key)
+		}, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, Weight: 3, ExpireAt: &soon})
+		store.Add(2, data.Item[int, int]{Value: 2, Weight: 3, ExpireAt: &later})
+		store.Add(3, data.Item[int, int]{Value: 3, Weight: 3})
+		store.Add(4, data.Item[int, int]{Value: 4, Weight: 5})
+
+		require.Equal(t, []int{1, 2}, evicted)
+		require.Equal(t, int64(8), store.Weight())
+	})
+}
+
+func TestStore_ExpiredKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns only expired keys", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		past, future := now.Add(-1*time.Minute), now.Add(1*time.Minute)
+		store := volatilettl.New[int, int](4, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1})
+		store.Add(2, data.Item[int, int]{Value: 2, ExpireAt: &past})
+		store.Add(3, data.Item[int, int]{Value: 3, ExpireAt: &future})
+
+		require.Equal(t, []int{2}, store.ExpiredKeys(now))
+	})
+}
+
+func TestStore_Flush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("clears all keys and values", func(t *testing.T) {
+		t.Parallel()
+
+		expireAt := time.Now().Add(1 * time.Minute)
+		store := volatilettl.New[int, int](2, nil, nil)
+		store.Add(1, data.Item[int, int]{Value: 1, ExpireAt: &expireAt})
+		store.Add(2, data.Item[int, int]{Value: 2})
+		store.Flush()
+
+		require.Empty(t, store.Items())
+		require.Zero(t, store.ExpiriesLen())
+	})
+}
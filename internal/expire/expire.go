@@ -2,11 +2,15 @@ package expire
 
 import (
 	"time"
+
+	"github.com/wafer-bw/memcache/data"
 )
 
 const (
-	DefaultSampleSize    int     = 20
-	DefaultExpirePercent float32 = 0.25
+	DefaultSampleSize    int           = 20
+	DefaultExpirePercent float32       = 0.25
+	DefaultMaxRounds     int           = 16
+	DefaultBudget        time.Duration = 25 * time.Millisecond
 )
 
 // Cacher is the interface depended upon by an expirer.
@@ -20,6 +24,12 @@ type Cacher[K comparable, V any] interface {
 	RandomKey() (K, bool)
 }
 
+// Indexer is implemented by stores which maintain an index of keys ordered by
+// expiry.
+type Indexer[K comparable] interface {
+	ExpiredKeys(now time.Time) []K
+}
+
 type AllKeys[K comparable, V any] struct {
 }
 
@@ -35,6 +45,9 @@ func (e AllKeys[K, V]) Expire(cache Cacher[K, V]) {
 type RandomSample[K comparable, V any] struct {
 	SampleSize    int
 	ExpirePercent float32
+	MaxRounds     int           // maximum number of sampling rounds per call to Expire.
+	Budget        time.Duration // maximum time spent per call to Expire.
+	Clock         data.Clock    // tells the time spent, defaults to the system clock.
 }
 
 func (e *RandomSample[K, V]) Expire(cache Cacher[K, V]) {
@@ -46,16 +59,39 @@ func (e *RandomSample[K, V]) Expire(cache Cacher[K, V]) {
 		e.ExpirePercent = DefaultExpirePercent
 	}
 
+	if e.MaxRounds <= 0 {
+		e.MaxRounds = DefaultMaxRounds
+	}
+
+	if e.Budget <= 0 {
+		e.Budget = DefaultBudget
+	}
+
+	if e.Clock == nil {
+		e.Clock = data.SystemClock{}
+	}
+
+	deadline := e.Clock.Now().Add(e.Budget)
+	for round := 0; round < e.MaxRounds; round++ {
+		if !e.sample(cache) || e.Clock.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// sample deletes expired keys from a random sample of the cache and reports
+// whether the percentage of expired keys found warrants another round.
+func (e *RandomSample[K, V]) sample(cache Cacher[K, V]) bool {
 	s := cache.Size()
 	if s == 0 {
-		return
+		return false
 	}
 
 	expiredCount := 0
 	for i := 0; i < e.SampleSize; i++ {
 		key, ok := cache.RandomKey()
 		if !ok {
-			return
+			return false
 		}
 
 		if ttl, ok := cache.TTL(key); ok && ttl != nil && *ttl <= 0 {
@@ -66,7 +102,26 @@ func (e *RandomSample[K, V]) Expire(cache Cacher[K, V]) {
 
 	percentExpired := float32(expiredCount) / float32(e.SampleSize)
 
-	if percentExpired > e.ExpirePercent {
-		e.Expire(cache)
+	return percentExpired > e.ExpirePercent
+}
+
+// Indexed deletes expired keys found using the expiry index of a store rather
+// than checking the ttl of every key in the cache.
+type Indexed[K comparable, V any] struct {
+	Indexer Indexer[K]
+	Clock   data.Clock // tells the time keys expire by, defaults to the system clock.
+}
+
+func (e Indexed[K, V]) Expire(cache Cacher[K, V]) {
+	clock := e.Clock
+	if clock == nil {
+		clock = data.SystemClock{}
+	}
+
+	keys := e.Indexer.ExpiredKeys(clock.Now())
+	if len(keys) == 0 {
+		return
 	}
+
+	cache.Delete(keys...)
 }
@@ -10,10 +10,15 @@ import (
 	"github.com/wafer-bw/memcache/internal/expire"
 	"github.com/wafer-bw/memcache/internal/mocks/mockexpire"
 	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/memcachetest"
 	"go.uber.org/mock/gomock"
 )
 
-var _ ports.Expirer[int, int] = (*expire.AllKeys[int, int])(nil)
+var (
+	_ ports.Expirer[int, int] = (*expire.AllKeys[int, int])(nil)
+	_ ports.Expirer[int, int] = (*expire.RandomSample[int, int])(nil)
+	_ ports.Expirer[int, int] = (*expire.Indexed[int, int])(nil)
+)
 
 func TestAllKeys_Expire(t *testing.T) {
 	t.Parallel()
@@ -140,6 +145,63 @@ func TestRandomSample_Expire(t *testing.T) {
 		sut.Expire(m)
 	})
 
+	t.Run("stops after max rounds even if over percentage", func(t *testing.T) {
+		t.Parallel()
+
+		expired := time.Until(time.Now().Add(-1 * time.Minute))
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		sut := expire.RandomSample[int, int]{
+			SampleSize:    2,
+			ExpirePercent: 0.25,
+			MaxRounds:     2,
+			Budget:        1 * time.Minute,
+		}
+
+		expects := make([]any, 0, 14)
+		for round := 0; round < 2; round++ {
+			expects = append(expects, m.EXPECT().Size().Return(100))
+			for i := 0; i < 2; i++ {
+				key := round*2 + i
+				expects = append(expects,
+					m.EXPECT().RandomKey().Return(key, true),
+					m.EXPECT().TTL(key).Return(&expired, true),
+					m.EXPECT().Delete(key),
+				)
+			}
+		}
+
+		gomock.InOrder(expects...)
+
+		sut.Expire(m)
+	})
+
+	t.Run("stops once budget is exhausted even if over percentage", func(t *testing.T) {
+		t.Parallel()
+
+		expired := time.Until(time.Now().Add(-1 * time.Minute))
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		sut := expire.RandomSample[int, int]{
+			SampleSize:    1,
+			ExpirePercent: 0.25,
+			MaxRounds:     100,
+			Budget:        1 * time.Nanosecond,
+		}
+
+		gomock.InOrder(
+			m.EXPECT().Size().Return(100),
+			m.EXPECT().RandomKey().Return(1, true),
+			m.EXPECT().TTL(1).DoAndReturn(func(int) (*time.Duration, bool) {
+				time.Sleep(1 * time.Millisecond)
+				return &expired, true
+			}),
+			m.EXPECT().Delete(1),
+		)
+
+		sut.Expire(m)
+	})
+
 	t.Run("returns early if size is found to be 0", func(t *testing.T) {
 		t.Parallel()
 
@@ -190,4 +252,94 @@ func TestRandomSample_Expire(t *testing.T) {
 		sut.Expire(m)
 		require.Equal(t, expire.DefaultExpirePercent, sut.ExpirePercent)
 	})
+
+	t.Run("defaults max rounds when zeroed", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		sut := expire.RandomSample[int, int]{SampleSize: 20, ExpirePercent: 0.25}
+
+		m.EXPECT().Size().Return(0)
+
+		sut.Expire(m)
+		require.Equal(t, expire.DefaultMaxRounds, sut.MaxRounds)
+	})
+
+	t.Run("defaults budget when zeroed", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		sut := expire.RandomSample[int, int]{SampleSize: 20, ExpirePercent: 0.25}
+
+		m.EXPECT().Size().Return(0)
+
+		sut.Expire(m)
+		require.Equal(t, expire.DefaultBudget, sut.Budget)
+	})
+
+	t.Run("stops sampling once the budget of its clock is spent", func(t *testing.T) {
+		t.Parallel()
+
+		expired := time.Duration(0)
+		clock := memcachetest.NewClock(time.Now())
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		sut := expire.RandomSample[int, int]{SampleSize: 1, ExpirePercent: 0.25, Budget: time.Second, Clock: clock}
+
+		m.EXPECT().Size().Return(10).Times(2)
+		m.EXPECT().RandomKey().Return(1, true).Times(2)
+		m.EXPECT().TTL(1).Return(&expired, true).Times(2)
+		m.EXPECT().Delete(1).Times(2).Do(func(...int) { clock.Advance(600 * time.Millisecond) })
+
+		sut.Expire(m)
+	})
+}
+
+func TestIndexed_Expire(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deletes expired keys found by the indexer", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		indexer := mockexpire.NewMockIndexer[int](ctrl)
+		sut := expire.Indexed[int, int]{Indexer: indexer}
+
+		gomock.InOrder(
+			indexer.EXPECT().ExpiredKeys(gomock.Any()).Return([]int{1, 3}),
+			m.EXPECT().Delete(1, 3),
+		)
+
+		sut.Expire(m)
+	})
+
+	t.Run("does not delete when no keys are expired", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		indexer := mockexpire.NewMockIndexer[int](ctrl)
+		sut := expire.Indexed[int, int]{Indexer: indexer}
+
+		indexer.EXPECT().ExpiredKeys(gomock.Any()).Return(nil)
+
+		sut.Expire(m)
+	})
+
+	t.Run("finds keys expired by the time of its clock", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		ctrl := gomock.NewController(t)
+		m := mockexpire.NewMockCacher[int, int](ctrl)
+		indexer := mockexpire.NewMockIndexer[int](ctrl)
+		sut := expire.Indexed[int, int]{Indexer: indexer, Clock: memcachetest.NewClock(now)}
+
+		indexer.EXPECT().ExpiredKeys(now).Return(nil)
+
+		sut.Expire(m)
+	})
 }
@@ -0,0 +1,115 @@
+// Package glob matches strings against the glob-style patterns used by Redis.
+package glob
+
+// Match reports whether s matches pattern, in which:
+//
+//	?        matches any single character
+//	*        matches any sequence of characters, including none
+//	[abc]    matches any one of the characters between the brackets
+//	[^abc]   matches any character not between the brackets
+//	[a-z]    matches any character in the range, combinable with the above
+//	\x       matches the character x, escaping its special meaning
+//
+// Unlike [path.Match], * and ? match any character including /, and malformed
+// patterns never fail but match as literally as possible.
+func Match(pattern, s string) bool {
+	// the position in pattern & s to backtrack to when a * is followed by a
+	// mismatch, retrying with the * consuming one more character of s.
+	star, next := -1, 0
+
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, next = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if end, ok := matchClass(pattern, p, s[i]); ok {
+					p = end
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				} else if p+1 == len(pattern) && s[i] == '\\' {
+					p++
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// matchClass reports whether c is matched by the character class starting at
+// pattern[start], returning the position just after the class if it is. A
+// class without a closing bracket extends to the end of the pattern.
+func matchClass(pattern string, start int, c byte) (int, bool) {
+	p := start + 1
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		lo := pattern[p]
+		if lo == '\\' && p+1 < len(pattern) {
+			p++
+			lo = pattern[p]
+		}
+
+		if p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']' {
+			hi := pattern[p+2]
+			if hi == '\\' && p+3 < len(pattern) {
+				p++
+				hi = pattern[p+2]
+			}
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if lo <= c && c <= hi {
+				matched = true
+			}
+			p += 3
+			continue
+		}
+
+		if lo == c {
+			matched = true
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++ // the closing bracket.
+	}
+
+	return p, matched != negate
+}
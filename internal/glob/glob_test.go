@@ -0,0 +1,54 @@
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/glob"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		"empty pattern matches empty string":      {"", "", true},
+		"empty pattern does not match a string":   {"", "a", false},
+		"literal matches itself":                  {"user:1", "user:1", true},
+		"literal does not match another string":   {"user:1", "user:2", false},
+		"star matches everything":                 {"*", "anything/at:all", true},
+		"star matches nothing":                    {"user:*", "user:", true},
+		"star matches a suffix":                   {"user:*", "user:1:name", true},
+		"star matches a prefix":                   {"*:name", "user:1:name", true},
+		"star matches in the middle":              {"user:*:name", "user:1:name", true},
+		"star backtracks":                         {"*a*b", "xaxxab", true},
+		"star does not match a missing suffix":    {"user:*:name", "user:1:age", false},
+		"question mark matches one character":     {"h?llo", "hello", true},
+		"question mark requires a character":      {"h?llo", "hllo", false},
+		"class matches a member":                  {"h[ae]llo", "hallo", true},
+		"class does not match a non member":       {"h[ae]llo", "hillo", false},
+		"negated class matches a non member":      {"h[^e]llo", "hallo", true},
+		"negated class does not match a member":   {"h[^e]llo", "hello", false},
+		"range matches within":                    {"h[a-c]llo", "hbllo", true},
+		"range does not match outside":            {"h[a-c]llo", "hdllo", false},
+		"reversed range matches within":           {"h[c-a]llo", "hbllo", true},
+		"escaped star matches a star":             {`a\*`, "a*", true},
+		"escaped star does not match other chars": {`a\*`, "ab", false},
+		"escaped bracket within class":            {`[\]]`, "]", true},
+		"unclosed class matches to the end":       {"[ab", "a", true},
+		"trailing backslash matches a backslash":  {`a\`, `a\`, true},
+		"slashes are matched by star":             {"a*c", "a/b/c", true},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, glob.Match(tc.pattern, tc.s))
+		})
+	}
+}
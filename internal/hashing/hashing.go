@@ -0,0 +1,58 @@
+// Package hashing provides hash functions for generic comparable keys.
+package hashing
+
+import (
+	"fmt"
+	"hash/maphash"
+)
+
+var seed = maphash.MakeSeed()
+
+// Default returns a hash function for keys of type K.
+//
+// Strings and integers are hashed directly. Any other key type is hashed via
+// its %#v representation which is correct for comparable values but slow, so
+// a dedicated hash function should be preferred for such keys.
+func Default[K comparable]() func(K) uint64 {
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case int:
+			return Mix(uint64(k))
+		case int8:
+			return Mix(uint64(k))
+		case int16:
+			return Mix(uint64(k))
+		case int32:
+			return Mix(uint64(k))
+		case int64:
+			return Mix(uint64(k))
+		case uint:
+			return Mix(uint64(k))
+		case uint8:
+			return Mix(uint64(k))
+		case uint16:
+			return Mix(uint64(k))
+		case uint32:
+			return Mix(uint64(k))
+		case uint64:
+			return Mix(k)
+		case uintptr:
+			return Mix(uint64(k))
+		default:
+			return maphash.String(seed, fmt.Sprintf("%#v", k))
+		}
+	}
+}
+
+// Mix scrambles the bits of x so that sequential inputs are spread evenly
+// across the output space (splitmix64 finalizer).
+func Mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
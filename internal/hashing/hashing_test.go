@@ -0,0 +1,54 @@
+package hashing_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/hashing"
+)
+
+func TestDefault(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the same hash for equal keys", func(t *testing.T) {
+		t.Parallel()
+
+		type key struct {
+			a string
+			b int
+		}
+
+		require.Equal(t, hashing.Default[string]()("a"), hashing.Default[string]()("a"))
+		require.Equal(t, hashing.Default[int]()(1), hashing.Default[int]()(1))
+		require.Equal(t, hashing.Default[key]()(key{"a", 1}), hashing.Default[key]()(key{"a", 1}))
+	})
+
+	t.Run("returns different hashes for different keys", func(t *testing.T) {
+		t.Parallel()
+
+		type key struct {
+			a string
+			b int
+		}
+
+		require.NotEqual(t, hashing.Default[string]()("a"), hashing.Default[string]()("b"))
+		require.NotEqual(t, hashing.Default[int]()(1), hashing.Default[int]()(2))
+		require.NotEqual(t, hashing.Default[key]()(key{"a", 1}), hashing.Default[key]()(key{"a", 2}))
+	})
+}
+
+func TestMix(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spreads sequential inputs", func(t *testing.T) {
+		t.Parallel()
+
+		buckets := make([]int, 8)
+		for i := uint64(0); i < 8000; i++ {
+			buckets[hashing.Mix(i)%8]++
+		}
+		for _, n := range buckets {
+			require.InDelta(t, 1000, n, 150)
+		}
+	})
+}
@@ -112,3 +112,40 @@ func (mr *MockCacherMockRecorder[K, V]) TTL(key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TTL", reflect.TypeOf((*MockCacher[K, V])(nil).TTL), key)
 }
+
+// MockIndexer is a mock of Indexer interface.
+type MockIndexer[K comparable] struct {
+	ctrl     *gomock.Controller
+	recorder *MockIndexerMockRecorder[K]
+}
+
+// MockIndexerMockRecorder is the mock recorder for MockIndexer.
+type MockIndexerMockRecorder[K comparable] struct {
+	mock *MockIndexer[K]
+}
+
+// NewMockIndexer creates a new mock instance.
+func NewMockIndexer[K comparable](ctrl *gomock.Controller) *MockIndexer[K] {
+	mock := &MockIndexer[K]{ctrl: ctrl}
+	mock.recorder = &MockIndexerMockRecorder[K]{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIndexer[K]) EXPECT() *MockIndexerMockRecorder[K] {
+	return m.recorder
+}
+
+// ExpiredKeys mocks base method.
+func (m *MockIndexer[K]) ExpiredKeys(now time.Time) []K {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpiredKeys", now)
+	ret0, _ := ret[0].([]K)
+	return ret0
+}
+
+// ExpiredKeys indicates an expected call of ExpiredKeys.
+func (mr *MockIndexerMockRecorder[K]) ExpiredKeys(now any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpiredKeys", reflect.TypeOf((*MockIndexer[K])(nil).ExpiredKeys), now)
+}
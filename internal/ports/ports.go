@@ -2,22 +2,36 @@
 package ports
 
 import (
+	"context"
+	"io"
 	"time"
 
-	"github.com/wafer-bw/memcache/internal/data"
+	"github.com/wafer-bw/memcache/data"
 	"github.com/wafer-bw/memcache/internal/expire"
 )
 
 type Cacher[K comparable, V any] interface {
 	Set(key K, value V)
 	SetEx(key K, value V, ttl time.Duration)
+	SetExStale(key K, value V, softTTL, hardTTL time.Duration)
 	Get(key K) (V, bool)
+	GetWithVersion(key K) (V, uint64, bool)
+	CompareAndSwap(key K, version uint64, value V) bool
+	CompareAndSwapEx(key K, version uint64, value V, ttl time.Duration) bool
+	CompareAndDelete(key K, version uint64) bool
+	Contains(key K) bool
+	GetOrLoad(ctx context.Context, key K, loader func(ctx context.Context, key K) (V, time.Duration, error)) (V, error)
 	TTL(key K) (*time.Duration, bool)
 	Delete(keys ...K)
 	Size() int
+	Weight() int64
+	Capacity() int
+	PolicyName() string
 	RandomKey() (K, bool)
 	Keys() []K
 	Flush()
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
 	Close()
 
 	// TODO - Consider adding the following methods:
@@ -32,12 +46,47 @@ type Storer[K comparable, V any] interface {
 	Get(key K) (data.Item[K, V], bool)
 	Remove(keys ...K)
 	Len() int
+	Weight() int64
 	RandomKey() (K, bool)
 	Keys() []K
 	Items() map[K]data.Item[K, V]
 	Flush()
 }
 
+// EvictionCounter is optionally implemented by stores which count the items
+// they evict to remain within capacity.
+type EvictionCounter interface {
+	Evictions() uint64
+}
+
+// Ranker is optionally implemented by stores which can order their keys such
+// that adding them in that order to an empty store restores the order in which
+// they would be evicted.
+type Ranker[K comparable] interface {
+	Ranked() []K
+}
+
+// FrequencyTracker is optionally implemented by stores which evict keys by
+// their access frequency.
+type FrequencyTracker[K comparable] interface {
+	Frequency(key K) int
+	SetFrequency(key K, frequency int)
+}
+
+// Swapper is optionally implemented by stores which can atomically replace or
+// remove the item of a key only while it has not expired and holds a given
+// version.
+type Swapper[K comparable, V any] interface {
+	CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool
+	CompareAndRemove(key K, version uint64) bool
+}
+
+// Peeker is optionally implemented by stores which can look up the item of a
+// key without counting it as an access of the key.
+type Peeker[K comparable, V any] interface {
+	Peek(key K) (data.Item[K, V], bool)
+}
+
 type Closer interface {
 	Close()
 	Closed() bool
@@ -59,5 +108,25 @@ type LFUTracker[K comparable] interface {
 	Inc(K)
 	Remove(K)
 	LFU() K
+	Set(K, int)
+	Frequency(K) int
+	Keys() []K
+	Len() int
+	Clear()
+}
+
+type ExpiryTracker[K comparable] interface {
+	Set(K, time.Time)
+	Remove(K)
+	Next() (K, bool)
+	Expired(time.Time) []K
+	Len() int
+	Clear()
+}
+
+type FrequencySketch[K comparable] interface {
+	Inc(K)
+	Estimate(K) int
+	Grow(int)
 	Clear()
 }
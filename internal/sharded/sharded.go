@@ -0,0 +1,233 @@
+// Package sharded provides a store which spreads keys across independent
+// shards to reduce lock contention.
+package sharded
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/expire"
+	"github.com/wafer-bw/memcache/internal/hashing"
+	"github.com/wafer-bw/memcache/internal/ports"
+)
+
+// Store routes each key to one of its shards by hash. Every shard holds its own
+// locks, so operations on keys in different shards do not contend.
+type Store[K comparable, V any] struct {
+	hash   func(K) uint64
+	shards []ports.Storer[K, V]
+}
+
+// New returns a store spreading keys across shards using hash. If every shard
+// maintains an index of keys ordered by expiry, so does the returned store.
+func New[K comparable, V any](shards []ports.Storer[K, V], hash func(K) uint64) ports.Storer[K, V] {
+	s := &Store[K, V]{hash: hash, shards: shards}
+	for _, shard := range shards {
+		if _, ok := shard.(expire.Indexer[K]); !ok {
+			return s
+		}
+	}
+
+	return &IndexedStore[K, V]{Store: s}
+}
+
+func (s *Store[K, V]) Add(key K, item data.Item[K, V]) {
+	s.shard(key).Add(key, item)
+}
+
+func (s *Store[K, V]) Get(key K) (data.Item[K, V], bool) {
+	return s.shard(key).Get(key)
+}
+
+// Peek returns the item of key from its shard without counting it as an access
+// of key, or gets it from the shard if it does not support peeking.
+func (s *Store[K, V]) Peek(key K) (data.Item[K, V], bool) {
+	shard := s.shard(key)
+	if peeker, ok := shard.(ports.Peeker[K, V]); ok {
+		return peeker.Peek(key)
+	}
+
+	return shard.Get(key)
+}
+
+func (s *Store[K, V]) Remove(keys ...K) {
+	for _, key := range keys {
+		s.shard(key).Remove(key)
+	}
+}
+
+func (s *Store[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+
+	return n
+}
+
+func (s *Store[K, V]) Weight() int64 {
+	var weight int64
+	for _, shard := range s.shards {
+		weight += shard.Weight()
+	}
+
+	return weight
+}
+
+// RandomKey returns a random key from a shard chosen in proportion to its
+// length, so that every key is about equally likely to be returned.
+func (s *Store[K, V]) RandomKey() (K, bool) {
+	lens := make([]int, len(s.shards))
+	total := 0
+	for i, shard := range s.shards {
+		lens[i] = shard.Len()
+		total += lens[i]
+	}
+
+	if total > 0 {
+		n := rand.Intn(total)
+		for i, l := range lens {
+			if n < l {
+				if key, ok := s.shards[i].RandomKey(); ok {
+					return key, true
+				}
+				break
+			}
+			n -= l
+		}
+	}
+
+	// the chosen shard was emptied concurrently so settle for any key.
+	for _, shard := range s.shards {
+		if key, ok := shard.RandomKey(); ok {
+			return key, true
+		}
+	}
+
+	return *new(K), false
+}
+
+func (s *Store[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+
+	return keys
+}
+
+func (s *Store[K, V]) Items() map[K]data.Item[K, V] {
+	items := map[K]data.Item[K, V]{}
+	for _, shard := range s.shards {
+		for key, item := range shard.Items() {
+			items[key] = item
+		}
+	}
+
+	return items
+}
+
+func (s *Store[K, V]) Flush() {
+	for _, shard := range s.shards {
+		shard.Flush()
+	}
+}
+
+// Evictions returns the number of items evicted by those shards which count
+// their evictions.
+func (s *Store[K, V]) Evictions() uint64 {
+	var n uint64
+	for _, shard := range s.shards {
+		if counter, ok := shard.(ports.EvictionCounter); ok {
+			n += counter.Evictions()
+		}
+	}
+
+	return n
+}
+
+// Ranked returns the keys of every shard, each shard's keys in the order it
+// ranks them. Because keys always belong to the same shard, adding them in this
+// order restores the order of every shard which ranks its keys.
+func (s *Store[K, V]) Ranked() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		if ranker, ok := shard.(ports.Ranker[K]); ok {
+			keys = append(keys, ranker.Ranked()...)
+		} else {
+			keys = append(keys, shard.Keys()...)
+		}
+	}
+
+	return keys
+}
+
+// Frequency returns the access frequency of key in its shard, or 0 if the
+// shard does not track frequencies.
+func (s *Store[K, V]) Frequency(key K) int {
+	if tracker, ok := s.shard(key).(ports.FrequencyTracker[K]); ok {
+		return tracker.Frequency(key)
+	}
+
+	return 0
+}
+
+// SetFrequency sets the access frequency of key in its shard, if the shard
+// tracks frequencies.
+func (s *Store[K, V]) SetFrequency(key K, frequency int) {
+	if tracker, ok := s.shard(key).(ports.FrequencyTracker[K]); ok {
+		tracker.SetFrequency(key, frequency)
+	}
+}
+
+// CompareAndSwap adds key & item to its shard only if key holds an unexpired
+// item of version, reporting whether it did. It always reports false if the
+// shard does not support compare-and-swap.
+func (s *Store[K, V]) CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool {
+	if swapper, ok := s.shard(key).(ports.Swapper[K, V]); ok {
+		return swapper.CompareAndSwap(key, version, item)
+	}
+
+	return false
+}
+
+// CompareAndRemove removes key from its shard only if it holds an unexpired
+// item of version, reporting whether it did. It always reports false if the
+// shard does not support compare-and-swap.
+func (s *Store[K, V]) CompareAndRemove(key K, version uint64) bool {
+	if swapper, ok := s.shard(key).(ports.Swapper[K, V]); ok {
+		return swapper.CompareAndRemove(key, version)
+	}
+
+	return false
+}
+
+// Shards returns the number of shards in the store.
+func (s *Store[K, V]) Shards() int {
+	return len(s.shards)
+}
+
+// shard returns the shard of key. The hash is mixed in case the hasher has
+// poorly distributed low bits.
+func (s *Store[K, V]) shard(key K) ports.Storer[K, V] {
+	return s.shards[hashing.Mix(s.hash(key))%uint64(len(s.shards))]
+}
+
+// IndexedStore is a [Store] whose shards all maintain an index of keys ordered
+// by expiry.
+type IndexedStore[K comparable, V any] struct {
+	*Store[K, V]
+}
+
+// ExpiredKeys returns all keys which expire at or before now from the expiry
+// index of every shard.
+func (s *IndexedStore[K, V]) ExpiredKeys(now time.Time) []K {
+	var keys []K
+	for _, shard := range s.shards {
+		indexer, _ := shard.(expire.Indexer[K])
+		keys = append(keys, indexer.ExpiredKeys(now)...)
+	}
+
+	return keys
+}
@@ -0,0 +1,249 @@
+package sharded_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslru"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilettl"
+	"github.com/wafer-bw/memcache/internal/expire"
+	"github.com/wafer-bw/memcache/internal/hashing"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/sharded"
+	"github.com/wafer-bw/memcache/memcachetest"
+)
+
+var _ ports.Storer[int, int] = (*sharded.Store[int, int])(nil)
+
+var _ expire.Indexer[int] = (*sharded.IndexedStore[int, int])(nil)
+
+var _ ports.EvictionCounter = (*sharded.Store[int, int])(nil)
+
+var _ ports.Ranker[int] = (*sharded.Store[int, int])(nil)
+
+var _ ports.FrequencyTracker[int] = (*sharded.Store[int, int])(nil)
+
+var _ ports.Swapper[int, int] = (*sharded.Store[int, int])(nil)
+
+func newLRUShards(n, capacity int) []ports.Storer[int, int] {
+	shards := make([]ports.Storer[int, int], n)
+	for i := range shards {
+		shards[i] = allkeyslru.New[int, int](capacity, nil, nil)
+	}
+
+	return shards
+}
+
+func TestStore_conformance(t *testing.T) {
+	t.Parallel()
+
+	memcachetest.TestPolicy(t, memcache.Policy[int, int]{
+		Name:            "sharded",
+		DefaultCapacity: allkeyslru.DefaultCapacity,
+		MinimumCapacity: 2 * allkeyslru.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[int, int], clock data.Clock) memcache.Store[int, int] {
+			shards := make([]ports.Storer[int, int], 2)
+			for i := range shards {
+				shards[i] = allkeyslru.New[int, int](capacity/2, onRemove, clock)
+			}
+			return sharded.New(shards, hashing.Default[int]())
+		},
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an indexed store when every shard is indexed", func(t *testing.T) {
+		t.Parallel()
+
+		shards := []ports.Storer[int, int]{
+			volatilettl.New[int, int](10, nil, nil),
+			volatilettl.New[int, int](10, nil, nil),
+		}
+		store := sharded.New(shards, hashing.Default[int]())
+		require.IsType(t, &sharded.IndexedStore[int, int]{}, store)
+	})
+
+	t.Run("returns a plain store when any shard is not indexed", func(t *testing.T) {
+		t.Parallel()
+
+		shards := []ports.Storer[int, int]{
+			volatilettl.New[int, int](10, nil, nil),
+			allkeyslru.New[int, int](10, nil, nil),
+		}
+		store := sharded.New(shards, hashing.Default[int]())
+		require.IsType(t, &sharded.Store[int, int]{}, store)
+	})
+}
+
+func TestStore_Add(t *testing.T) {
+	t.Parallel()
+
+	t.Run("routes keys to shards by hash", func(t *testing.T) {
+		t.Parallel()
+
+		shards := newLRUShards(4, 10)
+		store := sharded.New(shards, func(key int) uint64 { return uint64(key) })
+		for i := 0; i < 20; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		seen := map[int]bool{}
+		for _, shard := range shards {
+			require.Positive(t, shard.Len())
+			for _, key := range shard.Keys() {
+				require.False(t, seen[key], "key %d in more than one shard", key)
+				seen[key] = true
+			}
+		}
+		require.Len(t, seen, 20)
+	})
+
+	t.Run("bounds each shard by its own capacity", func(t *testing.T) {
+		t.Parallel()
+
+		shards := newLRUShards(2, 5)
+		store := sharded.New(shards, hashing.Default[int]())
+		for i := 0; i < 100; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		require.Equal(t, 10, store.Len())
+		for _, shard := range shards {
+			require.Equal(t, 5, shard.Len())
+		}
+	})
+}
+
+func TestStore_RandomKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns false when every shard is empty", func(t *testing.T) {
+		t.Parallel()
+
+		store := sharded.New(newLRUShards(4, 10), hashing.Default[int]())
+
+		_, ok := store.RandomKey()
+		require.False(t, ok)
+	})
+
+	t.Run("returns keys from every shard", func(t *testing.T) {
+		t.Parallel()
+
+		store := sharded.New(newLRUShards(4, 10), hashing.Default[int]())
+		for i := 0; i < 8; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		seen := map[int]bool{}
+		for i := 0; i < 1000; i++ {
+			key, ok := store.RandomKey()
+			require.True(t, ok)
+			seen[key] = true
+		}
+		require.Len(t, seen, 8)
+	})
+}
+
+func TestStore_Evictions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sums the evictions of every shard", func(t *testing.T) {
+		t.Parallel()
+
+		store := sharded.New(newLRUShards(4, 5), hashing.Default[int]())
+		for i := 0; i < 100; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		counter, _ := store.(ports.EvictionCounter)
+		require.Equal(t, uint64(80), counter.Evictions())
+	})
+}
+
+func TestStore_Ranked(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the keys of each shard in the order it ranks them", func(t *testing.T) {
+		t.Parallel()
+
+		shards := newLRUShards(2, 10)
+		store := sharded.New(shards, func(key int) uint64 { return uint64(key) })
+		for _, key := range []int{4, 1, 2, 3, 0} {
+			store.Add(key, data.Item[int, int]{Value: key})
+		}
+
+		ranker, _ := store.(ports.Ranker[int])
+		ranked := ranker.Ranked()
+		require.ElementsMatch(t, []int{0, 1, 2, 3, 4}, ranked)
+		for _, shard := range shards {
+			var order []int
+			for _, key := range ranked {
+				if _, ok := shard.Get(key); ok {
+					order = append(order, key)
+				}
+			}
+			want, _ := shard.(ports.Ranker[int])
+			require.Equal(t, want.Ranked(), order)
+		}
+	})
+}
+
+func TestStore_SetFrequency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets the frequency of keys in shards which track frequencies", func(t *testing.T) {
+		t.Parallel()
+
+		shards := []ports.Storer[int, int]{
+			allkeyslfu.New[int, int](10, nil, nil),
+			allkeyslru.New[int, int](10, nil, nil),
+		}
+		store := sharded.New(shards, func(key int) uint64 { return uint64(key) })
+		for i := 0; i < 4; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+
+		tracker, _ := store.(ports.FrequencyTracker[int])
+		for i := 0; i < 4; i++ {
+			tracker.SetFrequency(i, 5)
+		}
+
+		lfuKeys, lruKeys := shards[0].Keys(), shards[1].Keys()
+		require.NotEmpty(t, lfuKeys)
+		require.NotEmpty(t, lruKeys)
+		for _, key := range lfuKeys {
+			require.Equal(t, 5, tracker.Frequency(key))
+		}
+		for _, key := range lruKeys {
+			require.Zero(t, tracker.Frequency(key))
+		}
+	})
+}
+
+func TestIndexedStore_ExpiredKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns expired keys of every shard", func(t *testing.T) {
+		t.Parallel()
+
+		shards := []ports.Storer[int, int]{
+			volatilettl.New[int, int](10, nil, nil),
+			volatilettl.New[int, int](10, nil, nil),
+		}
+		store := sharded.New(shards, func(key int) uint64 { return uint64(key) })
+		past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+		for i := 0; i < 6; i++ {
+			store.Add(i, data.Item[int, int]{Value: i, ExpireAt: &past})
+		}
+		store.Add(6, data.Item[int, int]{Value: 6, ExpireAt: &future})
+
+		indexer, _ := store.(expire.Indexer[int])
+		require.ElementsMatch(t, []int{0, 1, 2, 3, 4, 5}, indexer.ExpiredKeys(time.Now()))
+	})
+}
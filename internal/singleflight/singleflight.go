@@ -0,0 +1,112 @@
+// Package singleflight provides coalescing of concurrent calls for the same key
+// into a single call.
+package singleflight
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Group coalesces concurrent calls to [Group.Do] for the same key. The zero
+// value is ready to use.
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+type call[V any] struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	value   V
+	err     error
+}
+
+// Do calls fn once for all concurrent callers of key and returns its result to
+// each of them.
+//
+// fn is run on its own goroutine with a context carrying the values of the ctx
+// of the first caller, which is only cancelled once every caller has stopped
+// waiting for it. Callers stop waiting and return the error of their ctx as
+// soon as it is done, without affecting the other callers.
+//
+// If fn panics, the panic is recovered and every caller waiting for fn panics
+// in turn with an error holding the value fn panicked with and its stack.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[K]*call[V]{}
+	}
+
+	c, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		if p, ok := c.err.(*panicError); ok {
+			panic(p)
+		}
+		return c.value, c.err
+	case <-ctx.Done():
+		g.leave(key, c)
+		return *new(V), ctx.Err()
+	}
+}
+
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(ctx context.Context) (V, error)) {
+	defer c.cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = &panicError{value: r, stack: debug.Stack()}
+		}
+
+		g.mu.Lock()
+		g.forget(key, c)
+		g.mu.Unlock()
+
+		close(c.done)
+	}()
+
+	c.value, c.err = fn(ctx)
+}
+
+// leave stops a caller waiting for c, cancelling it if nobody else is waiting
+// so that later callers of key start a new call.
+func (g *Group[K, V]) leave(key K, c *call[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+
+	c.cancel()
+	g.forget(key, c)
+}
+
+func (g *Group[K, V]) forget(key K, c *call[V]) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+// panicError is the value a call to fn panicked with, along with the stack of
+// the goroutine it panicked on.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("singleflight: fn panicked: %v\n\n%s", p.value, p.stack)
+}
@@ -0,0 +1,222 @@
+package singleflight_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/singleflight"
+)
+
+func TestGroup_Do(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the result of fn", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, string]
+		value, err := g.Do(context.Background(), 1, func(context.Context) (string, error) {
+			return "one", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "one", value)
+	})
+
+	t.Run("coalesces concurrent calls for the same key", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		var calls atomic.Int32
+		release := make(chan struct{})
+		fn := func(context.Context) (int, error) {
+			calls.Add(1)
+			<-release
+			return 10, nil
+		}
+
+		const callers = 10
+		var started, wg sync.WaitGroup
+		results := make([]int, callers)
+		for i := 0; i < callers; i++ {
+			started.Add(1)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				started.Done()
+				results[i], _ = g.Do(context.Background(), 1, fn)
+			}(i)
+		}
+		started.Wait()
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond) // let the remaining callers join.
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, int32(1), calls.Load())
+		for _, result := range results {
+			require.Equal(t, 10, result)
+		}
+	})
+
+	t.Run("does not coalesce calls for different keys", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		var calls atomic.Int32
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(key int) {
+				defer wg.Done()
+				_, _ = g.Do(context.Background(), key, func(context.Context) (int, error) {
+					calls.Add(1)
+					<-release
+					return key, nil
+				})
+			}(i)
+		}
+		require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("returns the error of fn", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		errFn := errors.New("fn failed")
+		_, err := g.Do(context.Background(), 1, func(context.Context) (int, error) {
+			return 0, errFn
+		})
+		require.ErrorIs(t, err, errFn)
+	})
+
+	t.Run("panics in every waiting caller if fn panics", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		var calls atomic.Int32
+		release := make(chan struct{})
+		fn := func(context.Context) (int, error) {
+			calls.Add(1)
+			<-release
+			panic("fn panicked")
+		}
+
+		const callers = 3
+		var started sync.WaitGroup
+		recovered := make(chan any, callers)
+		for i := 0; i < callers; i++ {
+			started.Add(1)
+			go func() {
+				defer func() { recovered <- recover() }()
+				started.Done()
+				_, _ = g.Do(context.Background(), 1, fn)
+			}()
+		}
+		started.Wait()
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond) // let the remaining callers join.
+		close(release)
+
+		for i := 0; i < callers; i++ {
+			r := <-recovered
+			err, ok := r.(error)
+			require.True(t, ok, "caller did not panic with an error: %v", r)
+			require.ErrorContains(t, err, "fn panicked")
+		}
+		require.Equal(t, int32(1), calls.Load())
+
+		value, err := g.Do(context.Background(), 1, func(context.Context) (int, error) {
+			return 1, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, value)
+	})
+
+	t.Run("returns the error of ctx without waiting for fn", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		release := make(chan struct{})
+		defer close(release)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := g.Do(ctx, 1, func(context.Context) (int, error) {
+			<-release
+			return 1, nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("cancels fn once every caller stops waiting", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		cancelled := make(chan struct{})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := g.Do(ctx, 1, func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return 0, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("fn was not cancelled")
+		}
+
+		value, err := g.Do(context.Background(), 1, func(context.Context) (int, error) {
+			return 2, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, value)
+	})
+
+	t.Run("does not cancel fn while other callers are waiting", func(t *testing.T) {
+		t.Parallel()
+
+		var g singleflight.Group[int, int]
+		var once sync.Once
+		started, release := make(chan struct{}), make(chan struct{})
+		fn := func(ctx context.Context) (int, error) {
+			once.Do(func() { close(started) })
+			select {
+			case <-release:
+				return 1, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := g.Do(ctx, 1, fn)
+			errs <- err
+		}()
+		<-started
+
+		results := make(chan int)
+		go func() {
+			value, _ := g.Do(context.Background(), 1, fn)
+			results <- value
+		}()
+		time.Sleep(10 * time.Millisecond) // let the second caller join.
+
+		cancel()
+		require.ErrorIs(t, <-errs, context.Canceled)
+		close(release)
+		require.Equal(t, 1, <-results)
+	})
+}
@@ -0,0 +1,106 @@
+// Package cmsketch provides a count-min sketch for estimating the access
+// frequency of keys in a fixed amount of memory.
+package cmsketch
+
+const (
+	depth      = 4  // number of rows, each using an independent index.
+	widthRatio = 4  // counters per row for each key of capacity, reducing collisions.
+	maxCount   = 15 // counters saturate at this value, like 4-bit counters.
+	minWidth   = 16
+	resetRatio = 10 // counters are aged after resetRatio * width increments.
+)
+
+type Store[K comparable] struct {
+	hash      func(K) uint64
+	rows      [depth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func New[K comparable](capacity int, hash func(K) uint64) *Store[K] {
+	s := &Store[K]{hash: hash}
+	s.resize(width(capacity))
+
+	return s
+}
+
+// Grow widens the sketch if it is too narrow for capacity keys, resetting all
+// counters.
+func (s *Store[K]) Grow(capacity int) {
+	if w := width(capacity); w > len(s.rows[0]) {
+		s.resize(w)
+	}
+}
+
+// Inc records an access of key, periodically halving all counters so that
+// the sketch favors recent popularity over historic popularity.
+func (s *Store[K]) Inc(key K) {
+	h := s.hash(key)
+	for i := range s.rows {
+		index := s.index(h, i)
+		if s.rows[i][index] < maxCount {
+			s.rows[i][index]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+// Estimate returns the estimated access frequency of key.
+func (s *Store[K]) Estimate(key K) int {
+	h := s.hash(key)
+	estimate := maxCount
+	for i := range s.rows {
+		if count := int(s.rows[i][s.index(h, i)]); count < estimate {
+			estimate = count
+		}
+	}
+
+	return estimate
+}
+
+func (s *Store[K]) Clear() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.additions = 0
+}
+
+// index derives the counter index of a row from the key's hash using double
+// hashing so that only one hash is computed per key.
+func (s *Store[K]) index(h uint64, row int) uint64 {
+	h1, h2 := h, (h>>32)|1
+	return (h1 + uint64(row)*h2) & s.mask
+}
+
+func (s *Store[K]) resize(w int) {
+	s.mask = uint64(w - 1)
+	s.resetAt = resetRatio * w
+	s.additions = 0
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+}
+
+func (s *Store[K]) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// width returns the number of counters per row needed for capacity keys.
+func width(capacity int) int {
+	w := minWidth
+	for w < widthRatio*capacity {
+		w <<= 1
+	}
+
+	return w
+}
@@ -0,0 +1,95 @@
+package cmsketch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/hashing"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/cmsketch"
+)
+
+var _ ports.FrequencySketch[int] = (*cmsketch.Store[int])(nil)
+
+func TestStore_Estimate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("estimates frequency of keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](64, hashing.Default[int]())
+		for i := 0; i < 5; i++ {
+			store.Inc(1)
+		}
+		store.Inc(2)
+
+		require.Equal(t, 5, store.Estimate(1))
+		require.Equal(t, 1, store.Estimate(2))
+		require.Zero(t, store.Estimate(3))
+	})
+
+	t.Run("saturates counters", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](64, hashing.Default[int]())
+		for i := 0; i < 100; i++ {
+			store.Inc(1)
+		}
+
+		require.Equal(t, 15, store.Estimate(1))
+	})
+
+	t.Run("ages counters after enough increments", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](4, hashing.Default[int]())
+		for i := 0; i < 8; i++ {
+			store.Inc(-1)
+		}
+		for i := 0; i < 10*16-8; i++ {
+			store.Inc(i % 16)
+		}
+
+		require.LessOrEqual(t, store.Estimate(-1), 4)
+	})
+}
+
+func TestStore_Grow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps counters when already wide enough", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](64, hashing.Default[int]())
+		store.Inc(1)
+		store.Grow(32)
+
+		require.Equal(t, 1, store.Estimate(1))
+	})
+
+	t.Run("resets counters when widened", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](64, hashing.Default[int]())
+		store.Inc(1)
+		store.Grow(1024)
+
+		require.Zero(t, store.Estimate(1))
+		store.Inc(1)
+		require.Equal(t, 1, store.Estimate(1))
+	})
+}
+
+func TestStore_Clear(t *testing.T) {
+	t.Parallel()
+
+	t.Run("resets all counters", func(t *testing.T) {
+		t.Parallel()
+
+		store := cmsketch.New[int](64, hashing.Default[int]())
+		store.Inc(1)
+		store.Clear()
+
+		require.Zero(t, store.Estimate(1))
+	})
+}
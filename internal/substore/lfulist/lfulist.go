@@ -1,5 +1,7 @@
 package lfulist
 
+import "sort"
+
 // TODO: this may be better just as part of an eviction policy store because
 //       node contents may change when we need to include ttls
 
@@ -91,8 +93,11 @@ func (s *Store[K]) Inc(key K) {
 	nextList.pushBack(node)
 	s.frequencies[node.freq] = nextList
 
-	if list.size == 0 && s.min == node.freq-1 {
-		s.min++
+	if list.size == 0 {
+		delete(s.frequencies, node.freq-1)
+		if s.min == node.freq-1 {
+			s.min++
+		}
 	}
 }
 
@@ -102,16 +107,94 @@ func (s *Store[K]) Remove(key K) {
 		return
 	}
 
-	s.frequencies[node.freq].remove(node)
+	list := s.frequencies[node.freq]
+	list.remove(node)
 	delete(s.nodes, key)
+
+	if list.size == 0 {
+		delete(s.frequencies, node.freq)
+		if s.min == node.freq {
+			s.resetMin()
+		}
+	}
+}
+
+// Set the frequency of key to freq, which is at least 1, tracking key if it is
+// not already tracked. The key is treated as the most recently used key of its
+// frequency.
+func (s *Store[K]) Set(key K, freq int) {
+	s.Remove(key)
+
+	node := &freqNode[K]{key: key, freq: max(freq, 1)}
+	list, ok := s.frequencies[node.freq]
+	if !ok {
+		list = newList[K]()
+		s.frequencies[node.freq] = list
+	}
+	list.pushBack(node)
+	s.nodes[key] = node
+
+	if s.min == 0 || node.freq < s.min {
+		s.min = node.freq
+	}
+}
+
+// Frequency returns the frequency of key, or 0 if it is not tracked.
+func (s *Store[K]) Frequency(key K) int {
+	if node, ok := s.nodes[key]; ok {
+		return node.freq
+	}
+
+	return 0
 }
 
+// Keys returns every tracked key in the order they would be returned by LFU
+// if they were removed one at a time.
+func (s *Store[K]) Keys() []K {
+	freqs := make([]int, 0, len(s.frequencies))
+	for freq := range s.frequencies {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	keys := make([]K, 0, len(s.nodes))
+	for _, freq := range freqs {
+		list := s.frequencies[freq]
+		for node := list.head.next; node != list.tail; node = node.next {
+			keys = append(keys, node.key)
+		}
+	}
+
+	return keys
+}
+
+// LFU returns the least frequently used key, or the zero value if the store is
+// empty.
 func (s *Store[K]) LFU() K {
+	if len(s.nodes) == 0 {
+		return *new(K)
+	}
+
 	return s.frequencies[s.min].head.next.key
 }
 
+func (s *Store[K]) Len() int {
+	return len(s.nodes)
+}
+
 func (s *Store[K]) Clear() {
 	clear(s.nodes)
 	clear(s.frequencies)
 	s.min = 0
 }
+
+// resetMin finds the lowest frequency with keys after the list at the previous
+// minimum was emptied by a removal.
+func (s *Store[K]) resetMin() {
+	s.min = 0
+	for freq := range s.frequencies {
+		if s.min == 0 || freq < s.min {
+			s.min = freq
+		}
+	}
+}
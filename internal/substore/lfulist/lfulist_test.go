@@ -29,4 +29,81 @@ func TestStore(t *testing.T) {
 		key := store.LFU()
 		require.Equal(t, 3, key)
 	})
+
+	t.Run("returns least frequently used key after removing the only key at the minimum frequency", func(t *testing.T) {
+		t.Parallel()
+
+		store := lfulist.New[int](4)
+		store.Inc(1)
+		store.Inc(2)
+		store.Inc(2)
+		store.Inc(2)
+		store.Inc(3)
+		store.Inc(3)
+		store.Remove(1)
+		require.Equal(t, 3, store.LFU())
+
+		store.Remove(3)
+		require.Equal(t, 2, store.LFU())
+	})
+
+	t.Run("returns zero value when empty", func(t *testing.T) {
+		t.Parallel()
+
+		store := lfulist.New[int](4)
+		store.Inc(1)
+		store.Remove(1)
+		require.Zero(t, store.LFU())
+		require.Zero(t, store.Len())
+	})
+
+	t.Run("returns number of tracked keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := lfulist.New[int](4)
+		store.Inc(1)
+		store.Inc(1)
+		store.Inc(2)
+		require.Equal(t, 2, store.Len())
+
+		store.Clear()
+		require.Zero(t, store.Len())
+	})
+
+	t.Run("sets the frequency of keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := lfulist.New[int](4)
+		store.Inc(1)
+		store.Inc(1)
+		store.Set(2, 5)
+		store.Set(1, 7)
+		store.Set(3, 0)
+		require.Equal(t, 7, store.Frequency(1))
+		require.Equal(t, 5, store.Frequency(2))
+		require.Equal(t, 1, store.Frequency(3))
+		require.Zero(t, store.Frequency(4))
+		require.Equal(t, 3, store.Len())
+		require.Equal(t, 3, store.LFU())
+
+		store.Remove(3)
+		require.Equal(t, 2, store.LFU())
+	})
+
+	t.Run("returns keys in the order they would be evicted", func(t *testing.T) {
+		t.Parallel()
+
+		store := lfulist.New[int](4)
+		store.Inc(1)
+		store.Inc(2)
+		store.Inc(3)
+		store.Inc(1)
+		store.Inc(4)
+		require.Equal(t, []int{2, 3, 4, 1}, store.Keys())
+
+		for _, want := range store.Keys() {
+			require.Equal(t, want, store.LFU())
+			store.Remove(want)
+		}
+	})
 }
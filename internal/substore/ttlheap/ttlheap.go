@@ -0,0 +1,113 @@
+// Package ttlheap provides a store of keys ordered by their expiry.
+package ttlheap
+
+import (
+	"container/heap"
+	"time"
+)
+
+type entry[K comparable] struct {
+	key      K
+	expireAt time.Time
+}
+
+// entries is a min-heap of entries ordered by expireAt which keeps indices up
+// to date as entries move.
+type entries[K comparable] struct {
+	list    []entry[K]
+	indices map[K]int
+}
+
+func (e *entries[K]) Len() int           { return len(e.list) }
+func (e *entries[K]) Less(i, j int) bool { return e.list[i].expireAt.Before(e.list[j].expireAt) }
+
+func (e *entries[K]) Swap(i, j int) {
+	e.list[i], e.list[j] = e.list[j], e.list[i]
+	e.indices[e.list[i].key] = i
+	e.indices[e.list[j].key] = j
+}
+
+func (e *entries[K]) Push(x any) {
+	ent, _ := x.(entry[K])
+	e.indices[ent.key] = len(e.list)
+	e.list = append(e.list, ent)
+}
+
+func (e *entries[K]) Pop() any {
+	last := e.list[len(e.list)-1]
+	e.list = e.list[:len(e.list)-1]
+	delete(e.indices, last.key)
+	return last
+}
+
+type Store[K comparable] struct {
+	entries *entries[K]
+}
+
+func New[K comparable](capacity int) *Store[K] {
+	return &Store[K]{
+		entries: &entries[K]{
+			list:    make([]entry[K], 0, capacity),
+			indices: make(map[K]int, capacity),
+		},
+	}
+}
+
+// Set the expiry of key, adding it if it is not already tracked.
+func (s *Store[K]) Set(key K, expireAt time.Time) {
+	if index, ok := s.entries.indices[key]; ok {
+		s.entries.list[index].expireAt = expireAt
+		heap.Fix(s.entries, index)
+		return
+	}
+
+	heap.Push(s.entries, entry[K]{key: key, expireAt: expireAt})
+}
+
+func (s *Store[K]) Remove(key K) {
+	index, ok := s.entries.indices[key]
+	if !ok {
+		return
+	}
+
+	heap.Remove(s.entries, index)
+}
+
+// Next returns the key which expires soonest, or false if the store is empty.
+func (s *Store[K]) Next() (K, bool) {
+	if len(s.entries.list) == 0 {
+		return *new(K), false
+	}
+
+	return s.entries.list[0].key, true
+}
+
+// Expired returns all keys which expire at or before now without removing
+// them. Only the expired portion of the heap is visited.
+func (s *Store[K]) Expired(now time.Time) []K {
+	var keys []K
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if i >= len(s.entries.list) || s.entries.list[i].expireAt.After(now) {
+			continue
+		}
+
+		keys = append(keys, s.entries.list[i].key)
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+
+	return keys
+}
+
+func (s *Store[K]) Len() int {
+	return len(s.entries.list)
+}
+
+func (s *Store[K]) Clear() {
+	s.entries.list = s.entries.list[:0]
+	clear(s.entries.indices)
+}
@@ -0,0 +1,128 @@
+package ttlheap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/ports"
+	"github.com/wafer-bw/memcache/internal/substore/ttlheap"
+)
+
+var _ ports.ExpiryTracker[int] = (*ttlheap.Store[int])(nil)
+
+func TestStore_Next(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns key which expires soonest", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		store := ttlheap.New[int](4)
+		store.Set(1, now.Add(3*time.Minute))
+		store.Set(2, now.Add(1*time.Minute))
+		store.Set(3, now.Add(2*time.Minute))
+
+		key, ok := store.Next()
+		require.True(t, ok)
+		require.Equal(t, 2, key)
+	})
+
+	t.Run("reorders keys when their expiry is updated", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		store := ttlheap.New[int](4)
+		store.Set(1, now.Add(3*time.Minute))
+		store.Set(2, now.Add(1*time.Minute))
+		store.Set(2, now.Add(4*time.Minute))
+
+		key, ok := store.Next()
+		require.True(t, ok)
+		require.Equal(t, 1, key)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("returns false when empty", func(t *testing.T) {
+		t.Parallel()
+
+		store := ttlheap.New[int](4)
+
+		_, ok := store.Next()
+		require.False(t, ok)
+	})
+}
+
+func TestStore_Remove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes key", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		store := ttlheap.New[int](4)
+		store.Set(1, now.Add(1*time.Minute))
+		store.Set(2, now.Add(2*time.Minute))
+		store.Set(3, now.Add(3*time.Minute))
+		store.Remove(1)
+		store.Remove(1)
+
+		key, ok := store.Next()
+		require.True(t, ok)
+		require.Equal(t, 2, key)
+		require.Equal(t, 2, store.Len())
+	})
+}
+
+func TestStore_Expired(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns only keys expiring at or before now", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		store := ttlheap.New[int](8)
+		for i := 1; i <= 8; i++ {
+			offset := time.Duration(i) * time.Minute
+			if i%2 == 0 {
+				offset = -offset
+			}
+			store.Set(i, now.Add(offset))
+		}
+		store.Set(9, now)
+
+		require.ElementsMatch(t, []int{2, 4, 6, 8, 9}, store.Expired(now))
+	})
+
+	t.Run("returns no keys when none are expired", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		store := ttlheap.New[int](4)
+		store.Set(1, now.Add(1*time.Minute))
+
+		require.Empty(t, store.Expired(now))
+	})
+}
+
+func TestStore_Clear(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes all keys", func(t *testing.T) {
+		t.Parallel()
+
+		store := ttlheap.New[int](4)
+		store.Set(1, time.Now())
+		store.Set(2, time.Now())
+		store.Clear()
+
+		require.Zero(t, store.Len())
+		_, ok := store.Next()
+		require.False(t, ok)
+
+		store.Set(3, time.Now())
+		key, ok := store.Next()
+		require.True(t, ok)
+		require.Equal(t, 3, key)
+	})
+}
@@ -0,0 +1,68 @@
+// Package workpool provides a bounded pool of goroutines which can be stopped.
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool runs functions on at most a fixed number of goroutines at a time.
+type Pool struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// New returns a pool which runs at most workers functions at a time.
+func New(workers int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Pool{
+		ctx:     ctx,
+		cancel:  cancel,
+		workers: make(chan struct{}, max(workers, 1)),
+	}
+}
+
+// TryGo runs fn on a new goroutine if the pool has an idle worker and is not
+// closed, reporting whether it did. The ctx passed to fn is cancelled when the
+// pool is closed.
+func (p *Pool) TryGo(fn func(ctx context.Context)) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	select {
+	case p.workers <- struct{}{}:
+	default:
+		return false
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.workers
+			p.wg.Done()
+		}()
+		fn(p.ctx)
+	}()
+
+	return true
+}
+
+// Close stops the pool from running any more functions, cancels the ctx of
+// those running, and waits for them to return. Subsequent calls do nothing.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.cancel()
+	p.wg.Wait()
+}
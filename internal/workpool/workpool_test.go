@@ -0,0 +1,96 @@
+package workpool_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/internal/workpool"
+)
+
+func TestPool_TryGo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs fn on another goroutine", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(1)
+		defer p.Close()
+
+		done := make(chan struct{})
+		require.True(t, p.TryGo(func(context.Context) { close(done) }))
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("fn did not run")
+		}
+	})
+
+	t.Run("does not run fn when every worker is busy", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(2)
+		defer p.Close()
+
+		release := make(chan struct{})
+		defer close(release)
+		require.True(t, p.TryGo(func(context.Context) { <-release }))
+		require.True(t, p.TryGo(func(context.Context) { <-release }))
+		require.False(t, p.TryGo(func(context.Context) { t.Error("fn should not run") }))
+	})
+
+	t.Run("reuses workers once they are idle", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(1)
+		defer p.Close()
+
+		var runs atomic.Int32
+		for i := 0; i < 3; i++ {
+			require.Eventually(t, func() bool {
+				return p.TryGo(func(context.Context) { runs.Add(1) })
+			}, time.Second, time.Millisecond)
+		}
+		require.Eventually(t, func() bool { return runs.Load() == 3 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("does not run fn once closed", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(1)
+		p.Close()
+		require.False(t, p.TryGo(func(context.Context) { t.Error("fn should not run") }))
+	})
+}
+
+func TestPool_Close(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cancels and waits for running functions", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(1)
+		var returned atomic.Bool
+		started := make(chan struct{})
+		p.TryGo(func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+			returned.Store(true)
+		})
+		<-started
+
+		p.Close()
+		require.True(t, returned.Load())
+	})
+
+	t.Run("subsequent calls do not panic", func(t *testing.T) {
+		t.Parallel()
+
+		p := workpool.New(1)
+		p.Close()
+		p.Close()
+	})
+}
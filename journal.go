@@ -0,0 +1,637 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/wafer-bw/memcache/codec"
+	"github.com/wafer-bw/memcache/data"
+)
+
+// FsyncPolicy controls how often the journal of a cache is synced to disk, see
+// [WithJournal].
+type FsyncPolicy int
+
+const (
+	// FsyncAlways syncs the journal after every write, so that no writes are
+	// lost unless the disk fails, at the cost of a sync per write.
+	FsyncAlways FsyncPolicy = iota + 1
+	// FsyncEverySecond syncs the journal once a second, so that at most a
+	// second of writes are lost if the machine crashes.
+	FsyncEverySecond
+	// FsyncNever leaves syncing the journal to the operating system. Writes
+	// survive the process crashing but may be lost if the machine crashes.
+	FsyncNever
+)
+
+// Journals are written in the following format, with integers encoded as
+// varints unless stated otherwise:
+//
+//	magic    the bytes "MCAO"
+//	version  of the format as a big endian uint16
+//	codec    length prefixed name of the codec of the cache
+//	records  one per write
+//
+// Each record is made up of the length of its payload, its payload, and the
+// CRC-32C checksum of its payload as a big endian uint32. Payloads start with
+// a byte identifying their operation:
+//
+//	set     its length prefixed key & value as encoded by the codec, a byte of
+//	        flags marking which of its expiry and stale times follow as unix
+//	        nanoseconds, and its load duration
+//	delete  the number of keys followed by each length prefixed key
+//	flush   nothing
+const journalVersion uint16 = 1
+
+var journalMagic = [4]byte{'M', 'C', 'A', 'O'}
+
+const (
+	journalSet byte = iota + 1
+	journalDelete
+	journalFlush
+)
+
+// journal is the append-only file recording the writes to a cache.
+type journal[K comparable, V any] struct {
+	mu          sync.Mutex
+	file        *os.File
+	path        string
+	codec       codec.Codec[K, V]
+	fsync       FsyncPolicy
+	size        int64 // current size of the journal.
+	rewrittenTo int64 // size of the journal after it was last rewritten.
+	rewriteSize int64 // minimum size of the journal before it is rewritten.
+	rewriting   bool
+	pending     [][]byte // records written while the journal is rewritten.
+	err         error    // error which stopped records being written.
+	rewrites    sync.WaitGroup
+	items       func() []data.Entry[K, V]           // items to rewrite the journal from.
+	peek        func(key K) (data.Item[K, V], bool) // current items of keys to record.
+}
+
+// openJournal replays the journal at path into the cache, creating it if it
+// does not exist, and opens it for appending. A journal whose tail is not a
+// complete record, for example because the process crashed while writing it,
+// is truncated to its last complete record. Any other corruption is returned as
+// an [ErrInvalidJournal] without modifying the journal.
+func (c *Cache[K, V]) openJournal(path string) (*journal[K, V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := c.replayJournal(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	j := &journal[K, V]{
+		file:        f,
+		path:        path,
+		codec:       c.codec,
+		fsync:       c.journalFsync,
+		rewriteSize: c.journalRewriteSize,
+		items:       c.journalItems,
+		peek:        c.peek,
+	}
+
+	if size == 0 {
+		header := journalHeader(c.codec.Name())
+		if _, err := f.Write(header); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		size = int64(len(header))
+	} else {
+		if err := f.Truncate(size); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	j.size, j.rewrittenTo = size, size
+
+	return j, nil
+}
+
+// replayJournal applies every complete record of the journal in f to the cache
+// and returns the size of the journal up to its last complete record, or 0 if
+// the journal is empty. Only the last record may be incomplete.
+func (c *Cache[K, V]) replayJournal(f *os.File) (int64, error) {
+	r := &countingReader{r: bufio.NewReader(f)}
+
+	header := journalHeader(c.codec.Name())
+	got := make([]byte, len(header))
+	if n, err := io.ReadFull(r, got); n == 0 && errors.Is(err, io.EOF) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidJournal, io.ErrUnexpectedEOF)
+	}
+	if !bytes.Equal(got[:len(journalMagic)], journalMagic[:]) {
+		return 0, fmt.Errorf("%w: not a journal", ErrInvalidJournal)
+	}
+	if v := binary.BigEndian.Uint16(got[len(journalMagic):]); v != journalVersion {
+		return 0, fmt.Errorf("%w: version %d is not supported, expected %d", ErrInvalidJournal, v, journalVersion)
+	}
+	if !bytes.Equal(got, header) {
+		return 0, fmt.Errorf("%w: codec does not match %q", ErrInvalidJournal, c.codec.Name())
+	}
+
+	now := c.clock.Now()
+	for {
+		complete := r.n
+		payload, err := readJournalRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// the journal ends, possibly with an incomplete record.
+			return complete, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if err := c.applyJournalRecord(payload, now); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// applyJournalRecord applies the operation recorded in payload to the store of
+// the cache, deleting rather than setting items which expired before now.
+func (c *Cache[K, V]) applyJournalRecord(payload []byte, now time.Time) error {
+	d := &decoder{b: payload}
+	switch d.byte() {
+	case journalSet:
+		key, err := c.codec.DecodeKey(d.bytes())
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidJournal, err)
+		}
+		value, err := c.codec.DecodeValue(d.bytes())
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidJournal, err)
+		}
+		item := data.Item[K, V]{Value: value}
+		flags := d.byte()
+		if flags&snapshotExpireAt != 0 {
+			item.ExpireAt = d.time()
+		}
+		if flags&snapshotStaleAt != 0 {
+			item.StaleAt = d.time()
+		}
+		item.LoadDuration = time.Duration(d.varint())
+		if d.err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidJournal, d.err)
+		}
+
+		if item.IsExpiredAt(now) {
+			c.store.Remove(key)
+		} else {
+			c.add(key, item)
+		}
+	case journalDelete:
+		n := d.uvarint()
+		keys := make([]K, 0, min(n, uint64(len(payload))))
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			key, err := c.codec.DecodeKey(d.bytes())
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidJournal, err)
+			}
+			keys = append(keys, key)
+		}
+		if d.err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidJournal, d.err)
+		}
+		c.store.Remove(keys...)
+	case journalFlush:
+		c.store.Flush()
+	default:
+		return fmt.Errorf("%w: unknown operation %d", ErrInvalidJournal, payload[0])
+	}
+
+	return nil
+}
+
+// journalItems returns every unexpired item of the cache in the order the
+// store ranks them.
+func (c *Cache[K, V]) journalItems() []data.Entry[K, V] {
+	items := c.store.Items()
+	now := c.clock.Now()
+
+	entries := make([]data.Entry[K, V], 0, len(items))
+	for _, key := range c.rankedKeys(items) {
+		if item := items[key]; !item.IsExpiredAt(now) {
+			entries = append(entries, data.Entry[K, V]{Key: key, Item: item})
+		}
+	}
+
+	return entries
+}
+
+// record the current item of each of keys, or their deletion if they no longer
+// exist. Keys whose key or value cannot be encoded by the codec are not
+// recorded.
+//
+// Writes to the cache record the keys they wrote once they have been applied,
+// and the items recorded are read while appending them, so that the last
+// record of each key in the journal holds its current item even when
+// concurrent writes are recorded in a different order than they were applied.
+func (j *journal[K, V]) record(keys ...K) {
+	if j == nil || len(keys) == 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var records [][]byte
+	var deleted []K
+	for _, key := range keys {
+		item, ok := j.peek(key)
+		if !ok {
+			deleted = append(deleted, key)
+			continue
+		}
+		if record, err := j.setRecord(key, item); err == nil {
+			records = append(records, record)
+		}
+	}
+	if len(deleted) > 0 {
+		var payload []byte
+		var n uint64
+		for _, key := range deleted {
+			encoded, err := j.codec.EncodeKey(key)
+			if err != nil {
+				continue
+			}
+			payload = appendBytes(payload, encoded)
+			n++
+		}
+		if n > 0 {
+			records = append(records, journalRecord(append(binary.AppendUvarint([]byte{journalDelete}, n), payload...)))
+		}
+	}
+	j.append(records...)
+}
+
+// flush records the cache being flushed, followed by the current items of the
+// cache, which were set since it was flushed.
+func (j *journal[K, V]) flush() {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	records := [][]byte{journalRecord([]byte{journalFlush})}
+	for _, entry := range j.items() {
+		if record, err := j.setRecord(entry.Key, entry.Item); err == nil {
+			records = append(records, record)
+		}
+	}
+	j.append(records...)
+}
+
+func (j *journal[K, V]) setRecord(key K, item data.Item[K, V]) ([]byte, error) {
+	encodedKey, err := j.codec.EncodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encodedValue, err := j.codec.EncodeValue(item.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if item.ExpireAt != nil {
+		flags |= snapshotExpireAt
+	}
+	if item.StaleAt != nil {
+		flags |= snapshotStaleAt
+	}
+
+	payload := appendBytes([]byte{journalSet}, encodedKey)
+	payload = appendBytes(payload, encodedValue)
+	payload = append(payload, flags)
+	if item.ExpireAt != nil {
+		payload = binary.AppendVarint(payload, item.ExpireAt.UnixNano())
+	}
+	if item.StaleAt != nil {
+		payload = binary.AppendVarint(payload, item.StaleAt.UnixNano())
+	}
+	payload = binary.AppendVarint(payload, int64(item.LoadDuration))
+
+	return journalRecord(payload), nil
+}
+
+// append records to the journal, syncing them if the fsync policy is always,
+// and starting a rewrite of the journal if it has grown large enough. j.mu
+// must be held.
+func (j *journal[K, V]) append(records ...[]byte) {
+	if j.file == nil || j.err != nil || len(records) == 0 {
+		return
+	}
+
+	for _, record := range records {
+		if _, err := j.file.Write(record); err != nil {
+			j.undo()
+			return
+		}
+		j.size += int64(len(record))
+		if j.rewriting {
+			j.pending = append(j.pending, record)
+		}
+	}
+	if j.fsync == FsyncAlways {
+		_ = j.file.Sync()
+	}
+
+	if !j.rewriting && j.size >= max(j.rewriteSize, 2*j.rewrittenTo) {
+		j.rewriting = true
+		j.rewrites.Add(1)
+		go j.rewrite()
+	}
+}
+
+// undo a failed write by truncating the journal back to its last complete
+// record, so that later records are not appended to a partial one. If that
+// fails, the error is kept and no more records are written. j.mu must be held.
+func (j *journal[K, V]) undo() {
+	if err := j.file.Truncate(j.size); err != nil {
+		j.err = err
+		return
+	}
+	if _, err := j.file.Seek(j.size, io.SeekStart); err != nil {
+		j.err = err
+	}
+}
+
+// rewrite the journal from the current items of the cache, so that it no
+// longer holds records which have since been overwritten or deleted.
+//
+// The new journal is written to a temporary file while records continue to be
+// appended to the current one. Records appended in the meantime are then
+// copied to the new journal, which replaces the current one by being renamed
+// over it.
+func (j *journal[K, V]) rewrite() {
+	defer j.rewrites.Done()
+
+	f, err := j.writeRewrite()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending := j.pending
+	j.pending, j.rewriting = nil, false
+	if err != nil || j.file == nil || j.err != nil {
+		if f != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+		return
+	}
+
+	size, err := finishRewrite(f, pending, j.path)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return
+	}
+
+	_ = j.file.Close()
+	j.file, j.size, j.rewrittenTo = f, size, size
+}
+
+// writeRewrite writes a new journal holding the current items of the cache to
+// a temporary file next to the journal.
+func (j *journal[K, V]) writeRewrite() (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(journalHeader(j.codec.Name())); err != nil {
+		return f, err
+	}
+	for _, entry := range j.items() {
+		record, err := j.setRecord(entry.Key, entry.Item)
+		if err != nil {
+			continue
+		}
+		if _, err := w.Write(record); err != nil {
+			return f, err
+		}
+	}
+
+	return f, w.Flush()
+}
+
+// finishRewrite appends pending records to the rewritten journal in f, syncs
+// it and renames it to path, returning its size.
+func finishRewrite(f *os.File, pending [][]byte, path string) (int64, error) {
+	for _, record := range pending {
+		if _, err := f.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, err
+	}
+
+	return f.Seek(0, io.SeekCurrent)
+}
+
+// sync the journal to disk.
+func (j *journal[K, V]) sync() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file != nil {
+		_ = j.file.Sync()
+	}
+}
+
+// close the journal after waiting for any rewrite to finish.
+func (j *journal[K, V]) close() {
+	if j == nil {
+		return
+	}
+
+	j.rewrites.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file != nil {
+		_ = j.file.Sync()
+		_ = j.file.Close()
+		j.file = nil
+	}
+}
+
+func (c *Cache[K, V]) runJournalSync(ticker Ticker) {
+	defer c.journalSyncing.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			c.journal.sync()
+		case <-c.closer.Ch():
+			return
+		}
+	}
+}
+
+func journalHeader(codec string) []byte {
+	header := binary.BigEndian.AppendUint16(journalMagic[:], journalVersion)
+	return appendBytes(header, []byte(codec))
+}
+
+// journalRecord frames payload as a record.
+func journalRecord(payload []byte) []byte {
+	record := binary.AppendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64+4), uint64(len(payload)))
+	record = append(record, payload...)
+	return binary.BigEndian.AppendUint32(record, crc32.Checksum(payload, castagnoli))
+}
+
+// readJournalRecord reads the next record from r and returns its payload once
+// its checksum is verified.
+func readJournalRecord(r *countingReader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > maxJournalRecord {
+		return nil, fmt.Errorf("%w: record length %d", ErrInvalidJournal, n)
+	}
+
+	record := make([]byte, n+4)
+	if _, err := io.ReadFull(r, record); errors.Is(err, io.EOF) {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	payload := record[:n]
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(record[n:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidJournal)
+	}
+
+	return payload, nil
+}
+
+// maxJournalRecord is the maximum length of a record, which guards against
+// allocating huge buffers when a corrupt length is read.
+const maxJournalRecord = 1 << 30
+
+func appendBytes(b, p []byte) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(p))), p...)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+// decoder decodes the parts of a record payload. The first error decoding is
+// kept, after which zero values are returned.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.b) == 0 {
+		d.fail()
+		return 0
+	}
+
+	b := d.b[0]
+	d.b = d.b[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.b)) {
+		d.fail()
+		return nil
+	}
+
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) time() *time.Time {
+	nanos := d.varint()
+	if d.err != nil {
+		return nil
+	}
+
+	t := time.Unix(0, nanos)
+	return &t
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = io.ErrUnexpectedEOF
+	}
+}
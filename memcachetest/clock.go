@@ -0,0 +1,90 @@
+package memcachetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+)
+
+var _ memcache.Clock = (*Clock)(nil)
+
+// Clock is a fake [memcache.Clock] whose time only moves when it is advanced,
+// allowing expiration to be tested without sleeping. It is safe for concurrent
+// use.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+// NewClock returns a fake clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTicker returns a ticker which ticks every time the clock is advanced by d.
+// Like a [time.Ticker], it holds at most one tick and drops ticks for slow
+// receivers. It panics if d is not greater than 0.
+func (c *Clock) NewTicker(d time.Duration) memcache.Ticker {
+	if d <= 0 {
+		panic("memcachetest: non-positive interval for NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{clock: c, ch: make(chan time.Time, 1), interval: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+
+	return t
+}
+
+// Advance moves the clock forward by d, delivering a tick to every ticker
+// which became due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
+
+type ticker struct {
+	clock    *Clock
+	ch       chan time.Time
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ticker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package memcachetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache/memcachetest"
+)
+
+func TestClock_Now(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := memcachetest.NewClock(now)
+	require.Equal(t, now, clock.Now())
+
+	clock.Advance(time.Minute)
+	require.Equal(t, now.Add(time.Minute), clock.Now())
+}
+
+func TestClock_NewTicker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ticks when advanced past its interval", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		clock.Advance(999 * time.Millisecond)
+		select {
+		case <-ticker.C():
+			t.Fatal("ticker should not have ticked")
+		default:
+		}
+
+		clock.Advance(time.Millisecond)
+		select {
+		case tick := <-ticker.C():
+			require.Equal(t, now.Add(time.Second), tick)
+		default:
+			t.Fatal("ticker should have ticked")
+		}
+	})
+
+	t.Run("drops ticks which are not received", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		clock.Advance(3 * time.Second)
+		require.Equal(t, now.Add(time.Second), <-ticker.C())
+		select {
+		case <-ticker.C():
+			t.Fatal("ticker should have dropped later ticks")
+		default:
+		}
+
+		clock.Advance(time.Second)
+		require.Equal(t, now.Add(4*time.Second), <-ticker.C())
+	})
+
+	t.Run("does not tick once stopped", func(t *testing.T) {
+		t.Parallel()
+
+		clock := memcachetest.NewClock(now)
+		ticker := clock.NewTicker(time.Second)
+		ticker.Stop()
+
+		clock.Advance(time.Minute)
+		select {
+		case <-ticker.C():
+			t.Fatal("ticker should not have ticked")
+		default:
+		}
+	})
+
+	t.Run("panics if the interval is not greater than 0", func(t *testing.T) {
+		t.Parallel()
+
+		require.Panics(t, func() { memcachetest.NewClock(now).NewTicker(0) })
+	})
+}
@@ -0,0 +1,427 @@
+// Package memcachetest provides utilities for testing code built on top of
+// [github.com/wafer-bw/memcache].
+package memcachetest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/data"
+)
+
+// conformanceCapacity is the capacity used for stores under test unless the
+// policy requires a larger one.
+const conformanceCapacity = 10
+
+// frequencyTracker is implemented by stores which track the access frequency
+// of their keys, whether or not they can set it as [memcache.FrequencyTracker]
+// requires.
+type frequencyTracker interface {
+	Frequency(key int) int
+}
+
+// TestPolicy runs a suite of conformance tests against the stores created by
+// policy, verifying they satisfy the contract of [memcache.Store].
+//
+// Custom policies should pass this suite before being used with
+// [memcache.Open].
+func TestPolicy(t *testing.T, policy memcache.Policy[int, int]) {
+	t.Helper()
+
+	capacity := conformanceCapacity
+	if capacity < policy.MinimumCapacity {
+		capacity = policy.MinimumCapacity
+	}
+
+	clock := NewClock(time.Now())
+	newStore := func(onRemove data.RemoveFunc[int, int]) memcache.Store[int, int] {
+		store := policy.NewStore(capacity, onRemove, clock)
+		require.NotNil(t, store, "policy returned a nil store")
+		return store
+	}
+
+	t.Run("get returns added item", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+
+		item, ok := store.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 10, item.Value)
+	})
+
+	t.Run("get returns false for missing key", func(t *testing.T) {
+		store := newStore(nil)
+
+		_, ok := store.Get(1)
+		require.False(t, ok)
+	})
+
+	t.Run("peek returns items without counting as an access if it implements Peek", func(t *testing.T) {
+		store := newStore(nil)
+		peeker, ok := store.(memcache.Peeker[int, int])
+		if !ok {
+			t.Skip("store does not implement peek")
+		}
+
+		_, ok = peeker.Peek(1)
+		require.False(t, ok)
+
+		for key := 1; key <= 3; key++ {
+			store.Add(key, data.Item[int, int]{Value: key * 10})
+		}
+		ranker, isRanker := store.(memcache.Ranker[int])
+		var ranked []int
+		if isRanker {
+			ranked = ranker.Ranked()
+		}
+		tracker, isTracker := store.(frequencyTracker)
+		var frequency int
+		if isTracker {
+			frequency = tracker.Frequency(1)
+		}
+
+		item, ok := peeker.Peek(1)
+		require.True(t, ok)
+		require.Equal(t, 10, item.Value)
+		if isRanker {
+			require.Equal(t, ranked, ranker.Ranked(), "peek changed the eviction order")
+		}
+		if isTracker {
+			require.Equal(t, frequency, tracker.Frequency(1), "peek changed the access frequency")
+		}
+	})
+
+	t.Run("add replaces the item of an existing key", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(1, data.Item[int, int]{Value: 11})
+
+		item, ok := store.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 11, item.Value)
+		require.Equal(t, 1, store.Len())
+	})
+
+	t.Run("remove deletes keys and ignores missing keys", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Add(3, data.Item[int, int]{Value: 30})
+		store.Remove(1, 2, 4)
+
+		_, ok := store.Get(1)
+		require.False(t, ok)
+		_, ok = store.Get(2)
+		require.False(t, ok)
+		_, ok = store.Get(3)
+		require.True(t, ok)
+		require.Equal(t, 1, store.Len())
+	})
+
+	t.Run("random key returns false when empty", func(t *testing.T) {
+		store := newStore(nil)
+
+		_, ok := store.RandomKey()
+		require.False(t, ok)
+	})
+
+	t.Run("random key returns a key in the store", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Remove(1)
+
+		key, ok := store.RandomKey()
+		require.True(t, ok)
+		require.Equal(t, 2, key)
+	})
+
+	t.Run("keys and items return copies of store contents", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+
+		require.ElementsMatch(t, []int{1, 2}, store.Keys())
+
+		items := store.Items()
+		require.Len(t, items, 2)
+		require.Equal(t, 10, items[1].Value)
+		require.Equal(t, 20, items[2].Value)
+
+		delete(items, 1)
+		require.Equal(t, 2, store.Len())
+	})
+
+	t.Run("flush removes all keys", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20})
+		store.Flush()
+
+		require.Zero(t, store.Len())
+		require.Empty(t, store.Keys())
+		require.Empty(t, store.Items())
+		_, ok := store.RandomKey()
+		require.False(t, ok)
+
+		store.Add(3, data.Item[int, int]{Value: 30})
+		require.Equal(t, 1, store.Len())
+	})
+
+	t.Run("does not breach capacity and notifies of evicted keys", func(t *testing.T) {
+		var mu sync.Mutex
+		evicted := map[int]int{}
+		store := newStore(func(key int, item data.Item[int, int], reason data.Reason) {
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(t, data.Evicted, reason)
+			evicted[key] = item.Value
+		})
+
+		adds := capacity * 3
+		for i := 0; i < adds; i++ {
+			store.Add(i, data.Item[int, int]{Value: i * 10})
+			require.LessOrEqual(t, store.Len(), capacity)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.LessOrEqual(t, store.Len()+len(evicted), adds)
+		for key, value := range evicted {
+			require.Equal(t, key*10, value, "evicted item does not belong to key %d", key)
+			_, ok := store.Get(key)
+			require.False(t, ok, "evicted key %d still in store", key)
+		}
+	})
+
+	t.Run("counts its evictions if it implements Evictions", func(t *testing.T) {
+		var evictions uint64
+		store := newStore(func(_ int, _ data.Item[int, int], reason data.Reason) {
+			if reason == data.Evicted {
+				evictions++
+			}
+		})
+		counter, ok := store.(memcache.EvictionCounter)
+		if !ok {
+			t.Skip("store does not count its evictions")
+		}
+
+		for i := 0; i < capacity*3; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+		store.Remove(store.Keys()...)
+
+		require.Positive(t, evictions)
+		require.Equal(t, evictions, counter.Evictions())
+	})
+
+	t.Run("notifies of removed keys with their reason", func(t *testing.T) {
+		reasons := map[int]data.Reason{}
+		store := newStore(func(key int, item data.Item[int, int], reason data.Reason) {
+			require.Equal(t, key*10, item.Value)
+			reasons[key] = reason
+		})
+		expireAt := clock.Now().Add(time.Minute)
+		store.Add(1, data.Item[int, int]{Value: 10})
+		store.Add(2, data.Item[int, int]{Value: 20, ExpireAt: &expireAt})
+		store.Add(3, data.Item[int, int]{Value: 30})
+		clock.Advance(2 * time.Minute) // expires 2 by the time of the store's clock.
+
+		store.Remove(1, 2, 4)
+		require.Equal(t, map[int]data.Reason{1: data.Deleted, 2: data.Expired}, reasons)
+
+		store.Flush()
+		require.Equal(t, map[int]data.Reason{1: data.Deleted, 2: data.Expired, 3: data.Deleted}, reasons)
+	})
+
+	t.Run("notifies after releasing its locks", func(t *testing.T) {
+		var store memcache.Store[int, int]
+		store = newStore(func(key int, _ data.Item[int, int], _ data.Reason) {
+			_, ok := store.Get(key)
+			require.False(t, ok)
+			_ = store.Len()
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < capacity*2; i++ {
+				store.Add(i, data.Item[int, int]{Value: i})
+			}
+			store.Remove(capacity*2 - 1)
+			if swapper, ok := store.(memcache.Swapper[int, int]); ok {
+				for _, key := range store.Keys() {
+					item, _ := store.Get(key)
+					swapper.CompareAndSwap(key, item.Version, data.Item[int, int]{Value: key, Version: item.Version + 1})
+					swapper.CompareAndRemove(key, item.Version+1)
+				}
+			}
+			store.Flush()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("store deadlocked when its callback used the store")
+		}
+	})
+
+	t.Run("tracks the total weight of items", func(t *testing.T) {
+		store := newStore(nil)
+		store.Add(1, data.Item[int, int]{Value: 10, Weight: 2})
+		store.Add(2, data.Item[int, int]{Value: 20, Weight: 3})
+		require.Equal(t, int64(5), store.Weight())
+
+		store.Add(1, data.Item[int, int]{Value: 11, Weight: 4})
+		require.Equal(t, int64(7), store.Weight())
+
+		store.Remove(2)
+		require.Equal(t, int64(4), store.Weight())
+
+		store.Flush()
+		require.Zero(t, store.Weight())
+	})
+
+	t.Run("does not breach capacity by weight", func(t *testing.T) {
+		store := newStore(nil)
+		for i := 0; i < capacity*3; i++ {
+			store.Add(i%(capacity*2), data.Item[int, int]{Value: i, Weight: int64(i%3 + 1)})
+			require.LessOrEqual(t, store.Weight(), int64(capacity))
+
+			var weight int64
+			for _, item := range store.Items() {
+				weight += item.Cost()
+			}
+			require.Equal(t, weight, store.Weight())
+		}
+	})
+
+	t.Run("updating existing keys at capacity does not evict", func(t *testing.T) {
+		evictions := 0
+		store := newStore(func(int, data.Item[int, int], data.Reason) { evictions++ })
+		for i := 0; i < capacity; i++ {
+			store.Add(i, data.Item[int, int]{Value: i})
+		}
+		evictions = 0
+
+		keys := store.Keys()
+		for _, key := range keys {
+			store.Add(key, data.Item[int, int]{Value: key + 1})
+		}
+
+		require.Zero(t, evictions)
+		require.Len(t, store.Keys(), len(keys))
+	})
+
+	t.Run("compares and swaps items by version if it implements CompareAndSwap", func(t *testing.T) {
+		store := newStore(nil)
+		swapper, ok := store.(memcache.Swapper[int, int])
+		if !ok {
+			t.Skip("store does not implement compare-and-swap")
+		}
+
+		require.False(t, swapper.CompareAndSwap(1, 1, data.Item[int, int]{Value: 11, Version: 2}), "swapped a missing key")
+
+		store.Add(1, data.Item[int, int]{Value: 10, Version: 1})
+		require.False(t, swapper.CompareAndSwap(1, 3, data.Item[int, int]{Value: 11, Version: 2}), "swapped another version")
+		require.True(t, swapper.CompareAndSwap(1, 1, data.Item[int, int]{Value: 11, Version: 2}))
+		require.False(t, swapper.CompareAndSwap(1, 1, data.Item[int, int]{Value: 12, Version: 3}), "swapped a replaced version")
+
+		item, ok := store.Get(1)
+		require.True(t, ok)
+		require.Equal(t, 11, item.Value)
+		require.Equal(t, uint64(2), item.Version)
+
+		expireAt := clock.Now().Add(time.Minute)
+		store.Add(2, data.Item[int, int]{Value: 20, Version: 4, ExpireAt: &expireAt})
+		clock.Advance(2 * time.Minute)
+		require.False(t, swapper.CompareAndSwap(2, 4, data.Item[int, int]{Value: 21, Version: 5}), "swapped an expired item")
+	})
+
+	t.Run("compares and removes items by version if it implements CompareAndRemove", func(t *testing.T) {
+		reasons := map[int]data.Reason{}
+		store := newStore(func(key int, item data.Item[int, int], reason data.Reason) {
+			require.Equal(t, key*10, item.Value)
+			reasons[key] = reason
+		})
+		swapper, ok := store.(memcache.Swapper[int, int])
+		if !ok {
+			t.Skip("store does not implement compare-and-swap")
+		}
+
+		require.False(t, swapper.CompareAndRemove(1, 1), "removed a missing key")
+
+		store.Add(1, data.Item[int, int]{Value: 10, Version: 1})
+		require.False(t, swapper.CompareAndRemove(1, 2), "removed another version")
+		require.True(t, swapper.CompareAndRemove(1, 1))
+		_, ok = store.Get(1)
+		require.False(t, ok)
+		require.Equal(t, map[int]data.Reason{1: data.Deleted}, reasons)
+
+		expireAt := clock.Now().Add(time.Minute)
+		store.Add(2, data.Item[int, int]{Value: 20, Version: 3, ExpireAt: &expireAt})
+		clock.Advance(2 * time.Minute)
+		require.False(t, swapper.CompareAndRemove(2, 3), "removed an expired item")
+		require.Equal(t, 1, store.Len())
+	})
+
+	t.Run("compares and swaps atomically under concurrent access", func(t *testing.T) {
+		store := newStore(nil)
+		swapper, ok := store.(memcache.Swapper[int, int])
+		if !ok {
+			t.Skip("store does not implement compare-and-swap")
+		}
+
+		var versions atomic.Uint64
+		store.Add(1, data.Item[int, int]{Value: 0, Version: versions.Add(1)})
+
+		const writers, increments = 8, 100
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for n := 0; n < increments; {
+					item, _ := store.Get(1)
+					if swapper.CompareAndSwap(1, item.Version, data.Item[int, int]{Value: item.Value + 1, Version: versions.Add(1)}) {
+						n++
+					}
+					store.Add(i+2, data.Item[int, int]{Value: n}) // contend for the locks of the store.
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		item, ok := store.Get(1)
+		require.True(t, ok, "key was evicted")
+		require.Equal(t, writers*increments, item.Value)
+	})
+
+	t.Run("supports concurrent access", func(t *testing.T) {
+		store := newStore(nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < capacity*10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := i % (capacity * 2)
+				store.Add(key, data.Item[int, int]{Value: i})
+				_, _ = store.Get(key)
+				_, _ = store.RandomKey()
+				_ = store.Len()
+				if i%7 == 0 {
+					store.Remove(key)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		require.LessOrEqual(t, store.Len(), capacity)
+		require.Len(t, store.Keys(), store.Len())
+	})
+}
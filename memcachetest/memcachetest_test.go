@@ -0,0 +1,36 @@
+package memcachetest_test
+
+import (
+	"testing"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/memcachetest"
+)
+
+func TestTestPolicy(t *testing.T) {
+	t.Parallel()
+
+	policies := []memcache.Policy[int, int]{
+		memcache.NoEvictionPolicy[int, int](),
+		memcache.AllKeysLRUPolicy[int, int](),
+		memcache.VolatileLRUPolicy[int, int](),
+		memcache.AllKeysLFUPolicy[int, int](),
+		memcache.VolatileLFUPolicy[int, int](),
+		memcache.AllKeysRandomPolicy[int, int](),
+		memcache.VolatileRandomPolicy[int, int](),
+		memcache.VolatileTTLPolicy[int, int](),
+		memcache.TinyLFUPolicy[int, int](),
+		memcache.ARCPolicy[int, int](),
+		memcache.SIEVEPolicy[int, int](),
+		memcache.S3FIFOPolicy[int, int](),
+	}
+
+	for _, policy := range policies {
+		policy := policy
+		t.Run(policy.Name, func(t *testing.T) {
+			t.Parallel()
+
+			memcachetest.TestPolicy(t, policy)
+		})
+	}
+}
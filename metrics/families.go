@@ -0,0 +1,83 @@
+package metrics
+
+const namespace string = "memcache"
+
+// family of metrics sharing a name, with one or more samples per cache.
+type family struct {
+	name    string
+	help    string
+	kind    string // gauge or counter.
+	samples func(s snapshot) []sample
+}
+
+// sample of a family for a single cache, with an optional extra label.
+type sample struct {
+	label      string
+	labelValue string
+	value      float64
+}
+
+// one returns a family sampling a single unlabelled value per cache.
+func one(name, help, kind string, value func(s snapshot) float64) family {
+	return family{
+		name: namespace + "_" + name,
+		help: help,
+		kind: kind,
+		samples: func(s snapshot) []sample {
+			return []sample{{value: value(s)}}
+		},
+	}
+}
+
+var families = []family{
+	one("size", "Number of items in the cache.", "gauge", func(s snapshot) float64 {
+		return float64(s.size)
+	}),
+	one("capacity", "Maximum total weight of the items in the cache, 0 if unlimited.", "gauge", func(s snapshot) float64 {
+		return float64(s.capacity)
+	}),
+	one("weight", "Total weight of the items in the cache.", "gauge", func(s snapshot) float64 {
+		return float64(s.weight)
+	}),
+	one("hit_ratio", "Fraction of lookups which found a value.", "gauge", func(s snapshot) float64 {
+		return s.stats.HitRatio()
+	}),
+	one("hits_total", "Lookups which found a value.", "counter", func(s snapshot) float64 {
+		return float64(s.stats.Hits)
+	}),
+	one("misses_total", "Lookups which did not find a value.", "counter", func(s snapshot) float64 {
+		return float64(s.stats.Misses)
+	}),
+	one("expired_lookups_total", "Lookups which found an expired value and reported it as missing.", "counter", func(s snapshot) float64 {
+		return float64(s.stats.ExpiredLookups)
+	}),
+	one("sets_total", "Values set in the cache.", "counter", func(s snapshot) float64 {
+		return float64(s.stats.Sets)
+	}),
+	one("deletes_total", "Keys deleted from the cache.", "counter", func(s snapshot) float64 {
+		return float64(s.stats.Deletes)
+	}),
+	{
+		name: namespace + "_evictions_total",
+		help: "Values removed from the cache by its policy to remain within capacity, or by active or passive expiration.",
+		kind: "counter",
+		samples: func(s snapshot) []sample {
+			return []sample{
+				{label: "reason", labelValue: "capacity", value: float64(s.stats.Evictions)},
+				{label: "reason", labelValue: "active_expiration", value: float64(s.stats.ActiveExpirations)},
+				{label: "reason", labelValue: "passive_expiration", value: float64(s.stats.PassiveExpirations)},
+			}
+		},
+	},
+	{
+		name: namespace + "_loads_total",
+		help: "Calls to the loader of GetOrLoad by result.",
+		kind: "counter",
+		samples: func(s snapshot) []sample {
+			return []sample{
+				{label: "result", labelValue: "success", value: float64(s.stats.LoadSuccesses)},
+				{label: "result", labelValue: "failure", value: float64(s.stats.LoadFailures)},
+			}
+		},
+	},
+}
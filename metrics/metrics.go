@@ -0,0 +1,217 @@
+// Package metrics exposes the stats of named caches in the Prometheus text
+// exposition format and via [expvar].
+//
+// Metrics are gathered from [memcache.Cache.Stats] every time they are served,
+// so there is nothing to update and no cost to caches between scrapes.
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wafer-bw/memcache"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+// served by [Registry].
+const ContentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	ErrInvalidName    = errors.New("provided name must not be empty")
+	ErrInvalidCache   = errors.New("provided cache must not be nil")
+	ErrNameRegistered = errors.New("provided name is already registered")
+	ErrNamePublished  = errors.New("provided name is already published")
+)
+
+// Cache is the subset of [memcache.Cache] which metrics are gathered from.
+type Cache interface {
+	Stats() memcache.Stats
+	Size() int
+	Weight() int64
+	Capacity() int
+	PolicyName() string
+}
+
+// Registry holds named caches and serves their metrics. The zero value is
+// ready to use.
+//
+// Registry implements [http.Handler], serving the metrics of every registered
+// cache in the Prometheus text exposition format.
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]Cache
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register cache under name, which is used as the value of the cache label of
+// its metrics.
+func (r *Registry) Register(name string, cache Cache) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+	if cache == nil {
+		return ErrInvalidCache
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.caches[name]; ok {
+		return ErrNameRegistered
+	}
+	if r.caches == nil {
+		r.caches = map[string]Cache{}
+	}
+	r.caches[name] = cache
+
+	return nil
+}
+
+// Unregister the cache registered under name, if any.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.caches, name)
+}
+
+// ServeHTTP writes the metrics of every registered cache in the Prometheus
+// text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = r.WriteTo(w)
+}
+
+// WriteTo writes the metrics of every registered cache to w in the Prometheus
+// text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	snapshots := r.snapshots()
+
+	var b strings.Builder
+	for _, family := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", family.name, family.kind)
+		for _, s := range snapshots {
+			for _, sample := range family.samples(s) {
+				b.WriteString(family.name)
+				b.WriteString(`{cache="`)
+				b.WriteString(escape(s.name))
+				b.WriteString(`",policy="`)
+				b.WriteString(escape(s.policy))
+				b.WriteByte('"')
+				if sample.label != "" {
+					b.WriteString(`,` + sample.label + `="`)
+					b.WriteString(escape(sample.labelValue))
+					b.WriteByte('"')
+				}
+				b.WriteString("} ")
+				b.WriteString(strconv.FormatFloat(sample.value, 'g', -1, 64))
+				b.WriteByte('\n')
+			}
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Publish the metrics of every registered cache via [expvar] under name, as a
+// map of cache names to their metrics.
+//
+// Names published via expvar are global to the process and can never be
+// unpublished, so Publish returns [ErrNamePublished] if name has already been
+// published by anyone.
+func (r *Registry) Publish(name string) error {
+	if name == "" {
+		return ErrInvalidName
+	}
+
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	if expvar.Get(name) != nil {
+		return ErrNamePublished
+	}
+	expvar.Publish(name, expvar.Func(r.vars))
+
+	return nil
+}
+
+// publishMu serializes checking for & publishing names because expvar panics
+// when a name is published twice.
+var publishMu sync.Mutex
+
+// vars returns the metrics of every registered cache keyed by cache name and
+// then by metric name, which is the name of the Prometheus metric without its
+// namespace or _total suffix, joined with the value of its extra label if it
+// has one.
+func (r *Registry) vars() any {
+	vars := map[string]map[string]any{}
+	for _, s := range r.snapshots() {
+		metrics := map[string]any{"policy": s.policy}
+		for _, family := range families {
+			key := strings.TrimSuffix(strings.TrimPrefix(family.name, namespace+"_"), "_total")
+			for _, sample := range family.samples(s) {
+				if sample.label != "" {
+					metrics[key+"_"+sample.labelValue] = sample.value
+				} else {
+					metrics[key] = sample.value
+				}
+			}
+		}
+		vars[s.name] = metrics
+	}
+
+	return vars
+}
+
+// snapshots returns a snapshot of every registered cache ordered by name.
+func (r *Registry) snapshots() []snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshots := make([]snapshot, 0, len(r.caches))
+	for name, cache := range r.caches {
+		snapshots = append(snapshots, snapshot{
+			name:     name,
+			policy:   cache.PolicyName(),
+			size:     cache.Size(),
+			capacity: cache.Capacity(),
+			weight:   cache.Weight(),
+			stats:    cache.Stats(),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].name < snapshots[j].name
+	})
+
+	return snapshots
+}
+
+// snapshot of the metrics of a named cache.
+type snapshot struct {
+	name     string
+	policy   string
+	size     int
+	capacity int
+	weight   int64
+	stats    memcache.Stats
+}
+
+// escape label value v as required by the Prometheus text exposition format.
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/metrics"
+)
+
+func ExampleRegistry() {
+	cache, err := memcache.OpenAllKeysLRUCache[int, string](10)
+	if err != nil {
+		panic(err)
+	}
+	defer cache.Close()
+
+	registry := metrics.NewRegistry()
+	if err := registry.Register("users", cache); err != nil {
+		panic(err)
+	}
+
+	// serve the registry, usually via http.Handle("/metrics", registry).
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	cache.Set(1, "one")
+	_, _ = cache.Get(1)
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "memcache_hits_total") || strings.HasPrefix(line, "memcache_size") {
+			fmt.Println(line)
+		}
+	}
+	// Output:
+	// memcache_size{cache="users",policy="allkeyslru"} 1
+	// memcache_hits_total{cache="users",policy="allkeyslru"} 1
+}
@@ -0,0 +1,240 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/metrics"
+)
+
+var _ metrics.Cache = (*memcache.Cache[int, int])(nil)
+
+var _ http.Handler = (*metrics.Registry)(nil)
+
+func TestRegistry_Register(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an error when name is empty", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		err = metrics.NewRegistry().Register("", cache)
+		require.ErrorIs(t, err, metrics.ErrInvalidName)
+	})
+
+	t.Run("returns an error when cache is nil", func(t *testing.T) {
+		t.Parallel()
+
+		err := metrics.NewRegistry().Register("cache", nil)
+		require.ErrorIs(t, err, metrics.ErrInvalidCache)
+	})
+
+	t.Run("returns an error when name is already registered", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		registry := metrics.NewRegistry()
+		require.NoError(t, registry.Register("cache", cache))
+		err = registry.Register("cache", cache)
+		require.ErrorIs(t, err, metrics.ErrNameRegistered)
+	})
+
+	t.Run("registers names again once unregistered", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		registry := &metrics.Registry{}
+		require.NoError(t, registry.Register("cache", cache))
+		registry.Unregister("cache")
+		require.NoError(t, registry.Register("cache", cache))
+	})
+}
+
+func TestRegistry_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes the metrics of every cache ordered by name", func(t *testing.T) {
+		t.Parallel()
+
+		a, err := memcache.OpenAllKeysLRUCache[int, int](2)
+		require.NoError(t, err)
+		defer a.Close()
+		b, err := memcache.OpenNoEvictionCache[int, int]()
+		require.NoError(t, err)
+		defer b.Close()
+
+		a.Set(1, 1)
+		a.Set(2, 2)
+		a.Set(3, 3)
+		_, _ = a.Get(3)
+		_, _ = a.Get(1)
+		b.Set(1, 1)
+		b.Delete(1)
+
+		registry := metrics.NewRegistry()
+		require.NoError(t, registry.Register("b", b))
+		require.NoError(t, registry.Register("a", a))
+
+		var out strings.Builder
+		n, err := registry.WriteTo(&out)
+		require.NoError(t, err)
+		require.Equal(t, int64(out.Len()), n)
+		require.Equal(t, `# HELP memcache_size Number of items in the cache.
+# TYPE memcache_size gauge
+memcache_size{cache="a",policy="allkeyslru"} 2
+memcache_size{cache="b",policy="noevict"} 0
+# HELP memcache_capacity Maximum total weight of the items in the cache, 0 if unlimited.
+# TYPE memcache_capacity gauge
+memcache_capacity{cache="a",policy="allkeyslru"} 2
+memcache_capacity{cache="b",policy="noevict"} 0
+# HELP memcache_weight Total weight of the items in the cache.
+# TYPE memcache_weight gauge
+memcache_weight{cache="a",policy="allkeyslru"} 2
+memcache_weight{cache="b",policy="noevict"} 0
+# HELP memcache_hit_ratio Fraction of lookups which found a value.
+# TYPE memcache_hit_ratio gauge
+memcache_hit_ratio{cache="a",policy="allkeyslru"} 0.5
+memcache_hit_ratio{cache="b",policy="noevict"} 0
+# HELP memcache_hits_total Lookups which found a value.
+# TYPE memcache_hits_total counter
+memcache_hits_total{cache="a",policy="allkeyslru"} 1
+memcache_hits_total{cache="b",policy="noevict"} 0
+# HELP memcache_misses_total Lookups which did not find a value.
+# TYPE memcache_misses_total counter
+memcache_misses_total{cache="a",policy="allkeyslru"} 1
+memcache_misses_total{cache="b",policy="noevict"} 0
+# HELP memcache_expired_lookups_total Lookups which found an expired value and reported it as missing.
+# TYPE memcache_expired_lookups_total counter
+memcache_expired_lookups_total{cache="a",policy="allkeyslru"} 0
+memcache_expired_lookups_total{cache="b",policy="noevict"} 0
+# HELP memcache_sets_total Values set in the cache.
+# TYPE memcache_sets_total counter
+memcache_sets_total{cache="a",policy="allkeyslru"} 3
+memcache_sets_total{cache="b",policy="noevict"} 1
+# HELP memcache_deletes_total Keys deleted from the cache.
+# TYPE memcache_deletes_total counter
+memcache_deletes_total{cache="a",policy="allkeyslru"} 0
+memcache_deletes_total{cache="b",policy="noevict"} 1
+# HELP memcache_evictions_total Values removed from the cache by its policy to remain within capacity, or by active or passive expiration.
+# TYPE memcache_evictions_total counter
+memcache_evictions_total{cache="a",policy="allkeyslru",reason="capacity"} 1
+memcache_evictions_total{cache="a",policy="allkeyslru",reason="active_expiration"} 0
+memcache_evictions_total{cache="a",policy="allkeyslru",reason="passive_expiration"} 0
+memcache_evictions_total{cache="b",policy="noevict",reason="capacity"} 0
+memcache_evictions_total{cache="b",policy="noevict",reason="active_expiration"} 0
+memcache_evictions_total{cache="b",policy="noevict",reason="passive_expiration"} 0
+# HELP memcache_loads_total Calls to the loader of GetOrLoad by result.
+# TYPE memcache_loads_total counter
+memcache_loads_total{cache="a",policy="allkeyslru",result="success"} 0
+memcache_loads_total{cache="a",policy="allkeyslru",result="failure"} 0
+memcache_loads_total{cache="b",policy="noevict",result="success"} 0
+memcache_loads_total{cache="b",policy="noevict",result="failure"} 0
+`, out.String())
+	})
+
+	t.Run("escapes label values", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+
+		registry := metrics.NewRegistry()
+		require.NoError(t, registry.Register("a\"b\\c\nd", cache))
+
+		var out strings.Builder
+		_, err = registry.WriteTo(&out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), `memcache_size{cache="a\"b\\c\nd",policy="allkeyslru"} 0`)
+	})
+
+	t.Run("writes only help and type lines without caches", func(t *testing.T) {
+		t.Parallel()
+
+		var out strings.Builder
+		_, err := metrics.NewRegistry().WriteTo(&out)
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			require.True(t, strings.HasPrefix(line, "# "), line)
+		}
+	})
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serves metrics in the prometheus text format", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Set(1, 1)
+
+		registry := metrics.NewRegistry()
+		require.NoError(t, registry.Register("cache", cache))
+
+		rec := httptest.NewRecorder()
+		registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+		require.Contains(t, rec.Body.String(), `memcache_size{cache="cache",policy="allkeyslru"} 1`+"\n")
+	})
+}
+
+func TestRegistry_Publish(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publishes metrics via expvar", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := memcache.OpenAllKeysLRUCache[int, int](10)
+		require.NoError(t, err)
+		defer cache.Close()
+		cache.Set(1, 1)
+		_, _ = cache.Get(1)
+
+		registry := metrics.NewRegistry()
+		require.NoError(t, registry.Register("cache", cache))
+		require.NoError(t, registry.Publish("TestRegistry_Publish"))
+
+		var vars map[string]map[string]any
+		require.NoError(t, json.Unmarshal([]byte(expvar.Get("TestRegistry_Publish").String()), &vars))
+		require.Equal(t, "allkeyslru", vars["cache"]["policy"])
+		require.Equal(t, 1.0, vars["cache"]["size"])
+		require.Equal(t, 1.0, vars["cache"]["hits"])
+		require.Equal(t, 1.0, vars["cache"]["hit_ratio"])
+		require.Equal(t, 0.0, vars["cache"]["evictions_capacity"])
+		require.Equal(t, 0.0, vars["cache"]["loads_failure"])
+	})
+
+	t.Run("returns an error when name is empty", func(t *testing.T) {
+		t.Parallel()
+
+		err := metrics.NewRegistry().Publish("")
+		require.ErrorIs(t, err, metrics.ErrInvalidName)
+	})
+
+	t.Run("returns an error when name is already published", func(t *testing.T) {
+		t.Parallel()
+
+		expvar.NewInt("TestRegistry_Publish_published")
+		err := metrics.NewRegistry().Publish("TestRegistry_Publish_published")
+		require.ErrorIs(t, err, metrics.ErrNamePublished)
+	})
+}
@@ -0,0 +1,284 @@
+package memcache
+
+import (
+	"github.com/wafer-bw/memcache/data"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslfu"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeyslru"
+	"github.com/wafer-bw/memcache/internal/eviction/allkeysrandom"
+	"github.com/wafer-bw/memcache/internal/eviction/arc"
+	"github.com/wafer-bw/memcache/internal/eviction/noevict"
+	"github.com/wafer-bw/memcache/internal/eviction/s3fifo"
+	"github.com/wafer-bw/memcache/internal/eviction/sieve"
+	"github.com/wafer-bw/memcache/internal/eviction/tinylfu"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilelfu"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilelru"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilerandom"
+	"github.com/wafer-bw/memcache/internal/eviction/volatilettl"
+)
+
+// Store is the storage backing a [Cache], responsible for holding items and
+// evicting them according to its policy when it would breach its capacity.
+//
+// The capacity of a store is the maximum total [data.Item.Cost] of the items it
+// holds, which is the number of keys unless items are given a weight.
+//
+// Stores may also implement the following optional interfaces, which a [Cache]
+// detects by type assertion:
+//   - [EvictionCounter], whose count is reported by [Cache.Stats].
+//   - [Ranker] and [FrequencyTracker], used by [Cache.Snapshot] and
+//     [Cache.Restore] to preserve the metadata of their policy.
+//   - [Swapper], used by [Cache.CompareAndSwap], [Cache.CompareAndSwapEx] and
+//     [Cache.CompareAndDelete], without which those are only atomic with
+//     respect to each other rather than to every write to the cache.
+//   - [Peeker], used by [Cache.Contains], without which it uses Get.
+//
+// Implementations must be safe for concurrent use. Custom implementations can
+// be verified using [github.com/wafer-bw/memcache/memcachetest.TestPolicy].
+type Store[K comparable, V any] interface {
+	// Add key & item to the store, replacing any existing item, and evicting
+	// as many other keys as needed for the store to remain within its
+	// capacity.
+	Add(key K, item data.Item[K, V])
+	// Get the item of key, or false if it does not exist.
+	Get(key K) (data.Item[K, V], bool)
+	// Remove keys from the store.
+	Remove(keys ...K)
+	// Len returns the number of keys in the store.
+	Len() int
+	// Weight returns the total cost of all items in the store.
+	Weight() int64
+	// RandomKey returns a random key, or false if the store is empty.
+	RandomKey() (K, bool)
+	// Keys returns a copy of all keys in the store.
+	Keys() []K
+	// Items returns a copy of all keys & items in the store.
+	Items() map[K]data.Item[K, V]
+	// Flush removes all keys from the store.
+	Flush()
+}
+
+// EvictionCounter is optionally implemented by a [Store] which counts the items
+// it evicts to remain within its capacity.
+type EvictionCounter interface {
+	// Evictions returns the number of items the store has evicted.
+	Evictions() uint64
+}
+
+// Ranker is optionally implemented by a [Store] which can order its keys by
+// the order they would be evicted in.
+type Ranker[K comparable] interface {
+	// Ranked returns keys in an order which, when they are added to an empty
+	// store in that order, restores the order they would be evicted in.
+	Ranked() []K
+}
+
+// FrequencyTracker is optionally implemented by a [Store] which evicts keys by
+// their access frequency.
+type FrequencyTracker[K comparable] interface {
+	// Frequency returns the access frequency of key.
+	Frequency(key K) int
+	// SetFrequency sets the access frequency of key.
+	SetFrequency(key K, frequency int)
+}
+
+// Swapper is optionally implemented by a [Store] which can atomically replace
+// or remove the item of a key only while it has not expired and holds a given
+// version.
+//
+// Both methods must report whether they changed the store, compare and change
+// it while holding the same locks as Add, and notify onRemove as Add and Remove
+// do.
+type Swapper[K comparable, V any] interface {
+	// CompareAndSwap adds key & item only if key holds an unexpired item of
+	// version.
+	CompareAndSwap(key K, version uint64, item data.Item[K, V]) bool
+	// CompareAndRemove removes key only if it holds an unexpired item of
+	// version.
+	CompareAndRemove(key K, version uint64) bool
+}
+
+// Peeker is optionally implemented by a [Store] which can look up the item of
+// a key without counting it as an access of the key.
+type Peeker[K comparable, V any] interface {
+	// Peek returns the same as Get without counting as an access of key.
+	Peek(key K) (data.Item[K, V], bool)
+}
+
+// Policy describes how to create the [Store] of a [Cache] opened via [Open].
+type Policy[K comparable, V any] struct {
+	// Name of the policy used in errors.
+	Name string
+	// DefaultCapacity is used when no capacity is provided via [WithCapacity].
+	DefaultCapacity int
+	// MinimumCapacity is the smallest capacity the policy supports.
+	MinimumCapacity int
+	// NewStore returns a new store with the provided capacity. The store must
+	// call onRemove, if it is not nil, for every key it evicts, removes or
+	// flushes after releasing its locks, see [data.RemoveFunc]. The store must
+	// tell the time using clock, which is never nil.
+	NewStore func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V]
+}
+
+// NoEvictionPolicy ignores any additional keys that would cause the cache to
+// breach its capacity. A capacity of 0 (default) is unbounded.
+func NoEvictionPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            noevict.PolicyName,
+		DefaultCapacity: noevict.DefaultCapacity,
+		MinimumCapacity: noevict.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return noevict.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// AllKeysLRUPolicy evicts the least recently used key when the cache would
+// breach its capacity.
+func AllKeysLRUPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            allkeyslru.PolicyName,
+		DefaultCapacity: allkeyslru.DefaultCapacity,
+		MinimumCapacity: allkeyslru.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return allkeyslru.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// VolatileLRUPolicy evicts the least recently used key with a ttl when the
+// cache would breach its capacity. If no keys have a ttl, then the least
+// recently used key is evicted.
+func VolatileLRUPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            volatilelru.PolicyName,
+		DefaultCapacity: volatilelru.DefaultCapacity,
+		MinimumCapacity: volatilelru.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return volatilelru.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// AllKeysLFUPolicy evicts the least frequently used key when the cache would
+// breach its capacity.
+func AllKeysLFUPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            allkeyslfu.PolicyName,
+		DefaultCapacity: allkeyslfu.DefaultCapacity,
+		MinimumCapacity: allkeyslfu.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return allkeyslfu.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// VolatileLFUPolicy evicts the least frequently used key with a ttl when the
+// cache would breach its capacity. If no keys have a ttl, then the least
+// frequently used key is evicted.
+func VolatileLFUPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            volatilelfu.PolicyName,
+		DefaultCapacity: volatilelfu.DefaultCapacity,
+		MinimumCapacity: volatilelfu.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return volatilelfu.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// AllKeysRandomPolicy evicts a random key when the cache would breach its
+// capacity.
+func AllKeysRandomPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            allkeysrandom.PolicyName,
+		DefaultCapacity: allkeysrandom.DefaultCapacity,
+		MinimumCapacity: allkeysrandom.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return allkeysrandom.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// VolatileRandomPolicy evicts a random key with a ttl when the cache would
+// breach its capacity. If no keys have a ttl, then a random key is evicted.
+func VolatileRandomPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            volatilerandom.PolicyName,
+		DefaultCapacity: volatilerandom.DefaultCapacity,
+		MinimumCapacity: volatilerandom.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return volatilerandom.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// VolatileTTLPolicy evicts the key with a ttl which is nearest to expiring when
+// the cache would breach its capacity. If no keys have a ttl, then a random key
+// is evicted.
+func VolatileTTLPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            volatilettl.PolicyName,
+		DefaultCapacity: volatilettl.DefaultCapacity,
+		MinimumCapacity: volatilettl.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return volatilettl.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// TinyLFUPolicy evicts keys using W-TinyLFU. New keys enter a small lru
+// admission window and are only admitted to the main segmented lru if they are
+// estimated to be accessed more frequently than the key they would replace.
+func TinyLFUPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            tinylfu.PolicyName,
+		DefaultCapacity: tinylfu.DefaultCapacity,
+		MinimumCapacity: tinylfu.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return tinylfu.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// ARCPolicy evicts keys using an Adaptive Replacement Cache which self-tunes
+// the balance between evicting least recently and least frequently used keys.
+func ARCPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            arc.PolicyName,
+		DefaultCapacity: arc.DefaultCapacity,
+		MinimumCapacity: arc.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return arc.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// SIEVEPolicy evicts keys using SIEVE. A hand sweeps from the oldest to the
+// newest key, sparing and resetting keys which were accessed since it last
+// passed them, and evicting the first key which was not. Because a hit only
+// marks a key as accessed, gets only acquire a read lock.
+func SIEVEPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            sieve.PolicyName,
+		DefaultCapacity: sieve.DefaultCapacity,
+		MinimumCapacity: sieve.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return sieve.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
+
+// S3FIFOPolicy evicts keys using S3-FIFO. New keys enter a small fifo queue and
+// are only promoted to the main fifo queue if they are accessed again before
+// reaching its end, quickly evicting keys which are only accessed once. Because
+// a hit only increments a counter, gets only acquire a read lock.
+func S3FIFOPolicy[K comparable, V any]() Policy[K, V] {
+	return Policy[K, V]{
+		Name:            s3fifo.PolicyName,
+		DefaultCapacity: s3fifo.DefaultCapacity,
+		MinimumCapacity: s3fifo.MinimumCapacity,
+		NewStore: func(capacity int, onRemove data.RemoveFunc[K, V], clock data.Clock) Store[K, V] {
+			return s3fifo.New[K, V](capacity, onRemove, clock)
+		},
+	}
+}
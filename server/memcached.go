@@ -0,0 +1,595 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+)
+
+const (
+	// MemcachedVersion is the version of memcached whose text protocol is
+	// served, as reported by the version command.
+	MemcachedVersion string = "1.6.0"
+
+	// maxMemcachedKeyLength is the maximum length of a key.
+	maxMemcachedKeyLength int = 250
+	// maxMemcachedLineLength is the maximum length of a command line.
+	maxMemcachedLineLength int = 64 * 1024
+	// maxMemcachedItemSize is the maximum size of a value.
+	maxMemcachedItemSize int = 1024 * 1024
+	// maxMemcachedRelativeExptime is the largest exptime which is relative to
+	// now, larger ones being absolute unix times.
+	maxMemcachedRelativeExptime int64 = 60 * 60 * 24 * 30
+)
+
+// NewMemcached returns a server which serves cache to memcached clients using
+// the memcached text protocol. The following commands are supported:
+//
+//	get, gets                         GetWithVersion
+//	set, add, replace, append, prepend
+//	                                  Set, SetEx or Delete
+//	cas                               CompareAndSwap, CompareAndSwapEx or
+//	                                  CompareAndDelete
+//	delete                            Delete
+//	incr, decr, touch                 Get then Set or SetEx
+//	flush_all [delay]                 Flush
+//	stats, stats reset                Stats & ResetStats
+//	version, verbosity, quit
+//
+// An exptime of 0 never expires, up to 30 days is relative seconds from now,
+// and beyond that is an absolute unix time. Items stored with an exptime which
+// has already passed are deleted.
+//
+// Flags are accepted but not stored, and are always returned as 0. CAS tokens
+// are the versions of items, so a cas fails if the item was set in the meantime
+// by any client or by any other user of cache.
+func NewMemcached(cache *memcache.Cache[string, []byte]) (*Server, error) {
+	s, err := newServer(cache)
+	if err != nil {
+		return nil, err
+	}
+	s.serveConn = s.serveMemcached
+
+	return s, nil
+}
+
+// memcachedClientError is replied as a CLIENT_ERROR to malformed commands.
+type memcachedClientError string
+
+func (e memcachedClientError) Error() string {
+	return string(e)
+}
+
+const errBadCommandLine memcachedClientError = "bad command line format"
+
+// memcachedConn is a connection from a memcached client.
+type memcachedConn struct {
+	*Server
+	r       *bufio.Reader
+	w       *bufio.Writer
+	noreply bool
+	closing bool
+}
+
+func (s *Server) serveMemcached(conn net.Conn) {
+	c := &memcachedConn{
+		Server: s,
+		r:      bufio.NewReader(conn),
+		w:      bufio.NewWriter(conn),
+	}
+
+	for !c.closing {
+		line, err := readLine(c.r, maxMemcachedLineLength)
+		if errors.Is(err, errLineTooLong) {
+			c.writeLine("CLIENT_ERROR line too long")
+			_ = c.w.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+
+		if err := c.execute(bytes.Fields(line)); err != nil {
+			var clientErr memcachedClientError
+			if !errors.As(err, &clientErr) {
+				return
+			}
+			c.noreply = false
+			c.writeLine("CLIENT_ERROR " + clientErr.Error())
+		}
+
+		// replies to pipelined commands are flushed together.
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	_ = c.w.Flush()
+}
+
+// execute the command made up of fields. Malformed commands return a
+// memcachedClientError, and errors reading from the connection are returned
+// as is.
+func (c *memcachedConn) execute(fields [][]byte) error {
+	if len(fields) == 0 {
+		c.writeLine("ERROR")
+		return nil
+	}
+
+	c.noreply = false
+	name, args := string(fields[0]), fields[1:]
+	switch name {
+	case "get":
+		return c.get(args, false)
+	case "gets":
+		return c.get(args, true)
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return c.store(name, args)
+	case "delete":
+		return c.delete(args)
+	case "incr", "decr":
+		return c.incr(name, args)
+	case "touch":
+		return c.touch(args)
+	case "flush_all":
+		return c.flushAll(args)
+	case "stats":
+		return c.stats(args)
+	case "version":
+		c.writeLine("VERSION " + MemcachedVersion)
+	case "verbosity":
+		if _, err := c.noreplyArg(args, 1); err != nil {
+			return err
+		}
+		c.reply("OK")
+	case "quit":
+		c.closing = true
+	default:
+		c.writeLine("ERROR")
+	}
+
+	return nil
+}
+
+func (c *memcachedConn) get(args [][]byte, withCAS bool) error {
+	if len(args) == 0 {
+		c.writeLine("ERROR")
+		return nil
+	}
+	for _, arg := range args {
+		if err := validKey(arg); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range args {
+		value, version, ok := c.cache.GetWithVersion(string(arg))
+		if !ok {
+			continue
+		}
+
+		c.w.WriteString("VALUE ")
+		c.w.Write(arg)
+		c.w.WriteString(" 0 " + strconv.Itoa(len(value)))
+		if withCAS {
+			c.w.WriteString(" " + strconv.FormatUint(version, 10))
+		}
+		c.w.WriteString("\r\n")
+		c.w.Write(value)
+		c.w.WriteString("\r\n")
+	}
+	c.writeLine("END")
+
+	return nil
+}
+
+// store serves the storage commands, which are followed by a data block:
+//
+//	<command> <key> <flags> <exptime> <bytes> [noreply]
+//	cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (c *memcachedConn) store(name string, args [][]byte) error {
+	n := 4
+	if name == "cas" {
+		n = 5
+	}
+	args, err := c.noreplyArg(args, n)
+	if err != nil {
+		return err
+	}
+
+	key := string(args[0])
+	if err := validKey(args[0]); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(string(args[1]), 10, 32); err != nil {
+		return errBadCommandLine
+	}
+	exptime, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errBadCommandLine
+	}
+	size, err := strconv.Atoi(string(args[3]))
+	if err != nil || size < 0 {
+		return errBadCommandLine
+	}
+	var token uint64
+	if name == "cas" {
+		if token, err = strconv.ParseUint(string(args[4]), 10, 64); err != nil {
+			return errBadCommandLine
+		}
+	}
+
+	if size > maxMemcachedItemSize {
+		if _, err := c.r.Discard(size + 2); err != nil {
+			return err
+		}
+		c.noreply = false
+		c.writeLine("SERVER_ERROR object too large for cache")
+		return nil
+	}
+	value := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, value); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(value, []byte("\r\n")) {
+		return memcachedClientError("bad data chunk")
+	}
+	value = value[:size:size]
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	ttl := memcachedTTL(exptime, time.Now())
+	switch name {
+	case "set":
+		c.set(key, value, ttl)
+	case "add":
+		if c.cache.Contains(key) {
+			c.reply("NOT_STORED")
+			return nil
+		}
+		c.set(key, value, ttl)
+	case "replace":
+		if !c.cache.Contains(key) {
+			c.reply("NOT_STORED")
+			return nil
+		}
+		c.set(key, value, ttl)
+	case "append", "prepend":
+		// the flags & exptime of the existing item are kept.
+		existing, ok := c.cache.Get(key)
+		if !ok {
+			c.reply("NOT_STORED")
+			return nil
+		}
+		if name == "append" {
+			value = append(append(make([]byte, 0, len(existing)+len(value)), existing...), value...)
+		} else {
+			value = append(value, existing...)
+		}
+		c.setKeepTTL(key, value)
+	case "cas":
+		var swapped bool
+		switch {
+		case ttl < 0:
+			swapped = c.cache.CompareAndDelete(key, token)
+		case ttl == 0:
+			swapped = c.cache.CompareAndSwap(key, token, value)
+		default:
+			swapped = c.cache.CompareAndSwapEx(key, token, value, ttl)
+		}
+		if !swapped && c.cache.Contains(key) {
+			c.reply("EXISTS")
+			return nil
+		} else if !swapped {
+			c.reply("NOT_FOUND")
+			return nil
+		}
+	}
+	c.reply("STORED")
+
+	return nil
+}
+
+// delete serves:
+//
+//	delete <key> [0] [noreply]
+func (c *memcachedConn) delete(args [][]byte) error {
+	if len(args) > 1 && string(args[len(args)-1]) == "noreply" {
+		c.noreply = true
+		args = args[:len(args)-1]
+	}
+	// a time of 0 is accepted for compatibility with older clients.
+	if len(args) == 2 && string(args[1]) == "0" {
+		args = args[:1]
+	}
+	if len(args) != 1 {
+		return memcachedClientError("bad command line format.  Usage: delete <key> [noreply]")
+	}
+	if err := validKey(args[0]); err != nil {
+		return err
+	}
+	key := string(args[0])
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	if !c.cache.Contains(key) {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	c.cache.Delete(key)
+	c.reply("DELETED")
+
+	return nil
+}
+
+// incr serves:
+//
+//	incr <key> <value> [noreply]
+//	decr <key> <value> [noreply]
+//
+// Incrementing wraps around at the maximum uint64 while decrementing stops at
+// 0, the same as memcached.
+func (c *memcachedConn) incr(name string, args [][]byte) error {
+	args, err := c.noreplyArg(args, 2)
+	if err != nil {
+		return err
+	}
+	if err := validKey(args[0]); err != nil {
+		return err
+	}
+	key := string(args[0])
+	delta, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		return memcachedClientError("invalid numeric delta argument")
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	existing, ok := c.cache.Get(key)
+	if !ok {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	n, err := strconv.ParseUint(string(existing), 10, 64)
+	if err != nil {
+		c.noreply = false
+		c.writeLine("CLIENT_ERROR cannot increment or decrement non-numeric value")
+		return nil
+	}
+
+	switch {
+	case name == "incr":
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+	value := strconv.FormatUint(n, 10)
+	c.setKeepTTL(key, []byte(value))
+	c.reply(value)
+
+	return nil
+}
+
+// touch serves:
+//
+//	touch <key> <exptime> [noreply]
+func (c *memcachedConn) touch(args [][]byte) error {
+	args, err := c.noreplyArg(args, 2)
+	if err != nil {
+		return err
+	}
+	if err := validKey(args[0]); err != nil {
+		return err
+	}
+	key := string(args[0])
+	exptime, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return memcachedClientError("invalid exptime argument")
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	value, ok := c.cache.Get(key)
+	if !ok {
+		c.reply("NOT_FOUND")
+		return nil
+	}
+	c.set(key, value, memcachedTTL(exptime, time.Now()))
+	c.reply("TOUCHED")
+
+	return nil
+}
+
+// flushAll serves:
+//
+//	flush_all [delay] [noreply]
+//
+// A delayed flush replaces any earlier one which has not happened yet.
+func (c *memcachedConn) flushAll(args [][]byte) error {
+	if len(args) > 0 && string(args[len(args)-1]) == "noreply" {
+		c.noreply = true
+		args = args[:len(args)-1]
+	}
+	if len(args) > 1 {
+		return errBadCommandLine
+	}
+	var delay int64
+	if len(args) == 1 {
+		var err error
+		if delay, err = strconv.ParseInt(string(args[0]), 10, 64); err != nil || delay < 0 {
+			return errBadCommandLine
+		}
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	if ttl := memcachedTTL(delay, time.Now()); ttl > 0 {
+		s := c.Server
+		c.flushTimer = time.AfterFunc(ttl, func() {
+			s.writes.Lock()
+			defer s.writes.Unlock()
+
+			s.cache.Flush()
+		})
+	} else {
+		c.cache.Flush()
+	}
+	c.reply("OK")
+
+	return nil
+}
+
+// stats serves:
+//
+//	stats
+//	stats reset
+func (c *memcachedConn) stats(args [][]byte) error {
+	switch {
+	case len(args) == 1 && string(args[0]) == "reset":
+		c.cache.ResetStats()
+		c.writeLine("RESET")
+		return nil
+	case len(args) > 0:
+		c.writeLine("ERROR")
+		return nil
+	}
+
+	now := time.Now()
+	stats := c.cache.Stats()
+	for _, stat := range []struct {
+		name  string
+		value any
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(now.Sub(c.started).Seconds())},
+		{"time", now.Unix()},
+		{"version", MemcachedVersion},
+		{"pointer_size", strconv.IntSize},
+		{"curr_connections", c.connections()},
+		{"policy", c.cache.PolicyName()},
+		{"capacity", c.cache.Capacity()},
+		{"weight", c.cache.Weight()},
+		{"curr_items", c.cache.Size()},
+		{"cmd_get", stats.Hits + stats.Misses},
+		{"cmd_set", stats.Sets},
+		{"get_hits", stats.Hits},
+		{"get_misses", stats.Misses},
+		{"get_expired", stats.ExpiredLookups},
+		{"hit_ratio", strconv.FormatFloat(stats.HitRatio(), 'f', 4, 64)},
+		{"deletes", stats.Deletes},
+		{"evictions", stats.Evictions},
+		{"active_expirations", stats.ActiveExpirations},
+		{"passive_expirations", stats.PassiveExpirations},
+		{"load_successes", stats.LoadSuccesses},
+		{"load_failures", stats.LoadFailures},
+	} {
+		c.writeLine(fmt.Sprintf("STAT %s %v", stat.name, stat.value))
+	}
+	c.writeLine("END")
+
+	return nil
+}
+
+// set key to value, which expires after ttl if it is positive or is deleted if
+// ttl is negative.
+func (c *memcachedConn) set(key string, value []byte, ttl time.Duration) {
+	switch {
+	case ttl < 0:
+		c.cache.Delete(key)
+	case ttl == 0:
+		c.cache.Set(key, value)
+	default:
+		c.cache.SetEx(key, value, ttl)
+	}
+}
+
+// setKeepTTL sets key to value keeping the ttl of its existing value.
+func (c *memcachedConn) setKeepTTL(key string, value []byte) {
+	ttl, ok := c.cache.TTL(key)
+	if !ok || ttl == nil {
+		c.cache.Set(key, value)
+		return
+	}
+
+	c.cache.SetEx(key, value, *ttl)
+}
+
+// noreplyArg returns args without its trailing noreply argument if it has one,
+// in which case replies to the command are suppressed, and checks the number
+// of remaining arguments is n.
+func (c *memcachedConn) noreplyArg(args [][]byte, n int) ([][]byte, error) {
+	if len(args) == n+1 && string(args[n]) == "noreply" {
+		c.noreply = true
+		args = args[:n]
+	}
+	if len(args) != n {
+		return nil, errBadCommandLine
+	}
+
+	return args, nil
+}
+
+// reply writes line unless the command asked for no reply.
+func (c *memcachedConn) reply(line string) {
+	if c.noreply {
+		return
+	}
+
+	c.writeLine(line)
+}
+
+func (c *memcachedConn) writeLine(line string) {
+	c.w.WriteString(line + "\r\n")
+}
+
+// memcachedTTL converts exptime to a ttl, which is 0 for items which never
+// expire and negative for items which have already expired.
+func memcachedTTL(exptime int64, now time.Time) time.Duration {
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime < 0:
+		return -1
+	case exptime <= maxMemcachedRelativeExptime:
+		return time.Duration(exptime) * time.Second
+	}
+
+	ttl := time.Unix(exptime, 0).Sub(now)
+	if ttl <= 0 {
+		return -1
+	}
+
+	return ttl
+}
+
+func validKey(key []byte) error {
+	if len(key) > maxMemcachedKeyLength {
+		return errBadCommandLine
+	}
+	for _, b := range key {
+		if b <= ' ' || b == 0x7f {
+			return errBadCommandLine
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package server_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/server"
+)
+
+// memcachedClient sends commands to a memcached server and reads its replies
+// line by line.
+type memcachedClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// do sends the command line, followed by data blocks if there are any, and
+// returns the first line of the reply.
+func (c *memcachedClient) do(t *testing.T, line string, blocks ...string) string {
+	t.Helper()
+
+	c.send(t, line, blocks...)
+	return c.line(t)
+}
+
+func (c *memcachedClient) send(t *testing.T, line string, blocks ...string) {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString(line + "\r\n")
+	for _, block := range blocks {
+		b.WriteString(block + "\r\n")
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	require.NoError(t, err)
+}
+
+func (c *memcachedClient) line(t *testing.T) string {
+	t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// lines returns every line of a reply up to and including its END line.
+func (c *memcachedClient) lines(t *testing.T, line string) []string {
+	t.Helper()
+
+	c.send(t, line)
+	var lines []string
+	for {
+		lines = append(lines, c.line(t))
+		if lines[len(lines)-1] == "END" {
+			return lines
+		}
+	}
+}
+
+// serveMemcached serves cache over loopback and returns a client connected to
+// it.
+func serveMemcached(t *testing.T, cache *memcache.Cache[string, []byte]) *memcachedClient {
+	t.Helper()
+
+	s, err := server.NewMemcached(cache)
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, s.Close())
+		require.ErrorIs(t, <-done, server.ErrServerClosed)
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &memcachedClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestNewMemcached(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an error if the cache is nil", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := server.NewMemcached(nil)
+		require.ErrorIs(t, err, server.ErrInvalidCache)
+		require.Nil(t, s)
+	})
+}
+
+func TestServer_memcached(t *testing.T) {
+	t.Parallel()
+
+	t.Run("get & set read and write the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, []string{"END"}, client.lines(t, "get a"))
+		require.Equal(t, "STORED", client.do(t, "set a 0 0 5", "hello"))
+		require.Equal(t, "STORED", client.do(t, "set b 0 0 0", ""))
+		require.Equal(t, []string{"VALUE a 0 5", "hello", "VALUE b 0 0", "", "END"}, client.lines(t, "get a missing b"))
+
+		value, ok := cache.Get("a")
+		require.True(t, ok)
+		require.Equal(t, []byte("hello"), value)
+		ttl, _ := cache.TTL("a")
+		require.Nil(t, ttl)
+	})
+
+	t.Run("set honours relative & absolute exptimes", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "STORED", client.do(t, "set a 0 10 1", "1"))
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, 10*time.Second, *ttl)
+
+		absolute := time.Now().Add(time.Hour).Unix()
+		require.Equal(t, "STORED", client.do(t, "set b 0 "+strconv.FormatInt(absolute, 10)+" 1", "2"))
+		ttl, _ = cache.TTL("b")
+		require.InDelta(t, time.Hour, *ttl, float64(5*time.Second))
+
+		require.Equal(t, "STORED", client.do(t, "set c 0 -1 1", "3"))
+		require.Equal(t, "STORED", client.do(t, "set d 0 1000000000 1", "4"), "absolute time in the past")
+		require.Equal(t, 2, cache.Size())
+
+		clock.Advance(time.Minute)
+		require.Equal(t, []string{"VALUE b 0 1", "2", "END"}, client.lines(t, "get a b"))
+	})
+
+	t.Run("add only stores missing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "STORED", client.do(t, "add a 0 0 1", "1"))
+		require.Equal(t, "NOT_STORED", client.do(t, "add a 0 0 1", "2"))
+		require.Equal(t, []string{"VALUE a 0 1", "1", "END"}, client.lines(t, "get a"))
+	})
+
+	t.Run("add does not count as an access of existing keys when evicting", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t, memcache.WithCapacity[string, []byte](2))
+		client := serveMemcached(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.Set("b", []byte("2"))
+		require.Equal(t, "NOT_STORED", client.do(t, "add a 0 0 1", "2"))
+		cache.Set("c", []byte("3"))
+
+		require.ElementsMatch(t, []string{"b", "c"}, cache.Keys())
+	})
+
+	t.Run("replace only stores existing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "NOT_STORED", client.do(t, "replace a 0 0 1", "1"))
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "STORED", client.do(t, "replace a 0 0 1", "2"))
+		require.Equal(t, []string{"VALUE a 0 1", "2", "END"}, client.lines(t, "get a"))
+	})
+
+	t.Run("append & prepend extend existing values keeping their ttl", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "NOT_STORED", client.do(t, "append a 0 0 1", "x"))
+		cache.SetEx("a", []byte("b"), time.Minute)
+		require.Equal(t, "STORED", client.do(t, "append a 0 0 1", "c"))
+		require.Equal(t, "STORED", client.do(t, "prepend a 0 0 1", "a"))
+		require.Equal(t, []string{"VALUE a 0 3", "abc", "END"}, client.lines(t, "get a"))
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, time.Minute, *ttl)
+	})
+
+	t.Run("cas stores only if the value is unchanged since gets", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "NOT_FOUND", client.do(t, "cas a 0 0 1 1", "1"))
+		cache.Set("a", []byte("1"))
+
+		lines := client.lines(t, "gets a")
+		require.Len(t, lines, 3)
+		fields := strings.Fields(lines[0])
+		require.Len(t, fields, 5)
+		token := fields[4]
+
+		require.Equal(t, "STORED", client.do(t, "cas a 0 0 1 "+token, "2"))
+		require.Equal(t, "EXISTS", client.do(t, "cas a 0 0 1 "+token, "3"))
+		require.Equal(t, []string{"VALUE a 0 1", "2", "END"}, client.lines(t, "get a"))
+	})
+
+	t.Run("cas fails if the value was set since gets, even to the same value", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		cache.Set("a", []byte("1"))
+
+		fields := strings.Fields(client.lines(t, "gets a")[0])
+		require.Len(t, fields, 5)
+		token := fields[4]
+
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "EXISTS", client.do(t, "cas a 0 0 1 "+token, "2"))
+
+		_, version, _ := cache.GetWithVersion("a")
+		require.Equal(t, "STORED", client.do(t, "cas a 0 60 1 "+strconv.FormatUint(version, 10), "2"))
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, time.Minute, *ttl)
+
+		_, version, _ = cache.GetWithVersion("a")
+		require.Equal(t, "STORED", client.do(t, "cas a 0 -1 1 "+strconv.FormatUint(version, 10), "3"))
+		require.Equal(t, "NOT_FOUND", client.do(t, "cas a 0 0 1 "+strconv.FormatUint(version, 10), "4"))
+	})
+
+	t.Run("delete deletes existing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "DELETED", client.do(t, "delete a"))
+		require.Equal(t, "NOT_FOUND", client.do(t, "delete a"))
+		require.Equal(t, "NOT_FOUND", client.do(t, "delete a 0"))
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("incr & decr change numeric values keeping their ttl", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "NOT_FOUND", client.do(t, "incr a 1"))
+		cache.SetEx("a", []byte("10"), time.Minute)
+		require.Equal(t, "15", client.do(t, "incr a 5"))
+		require.Equal(t, "12", client.do(t, "decr a 3"))
+		require.Equal(t, "0", client.do(t, "decr a 100"), "stops at 0")
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, time.Minute, *ttl)
+
+		cache.Set("b", []byte("18446744073709551615"))
+		require.Equal(t, "0", client.do(t, "incr b 1"), "wraps around")
+
+		cache.Set("c", []byte("x"))
+		require.Equal(t, "CLIENT_ERROR cannot increment or decrement non-numeric value", client.do(t, "incr c 1"))
+		require.Equal(t, "CLIENT_ERROR invalid numeric delta argument", client.do(t, "incr a x"))
+	})
+
+	t.Run("touch sets the ttl of existing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "NOT_FOUND", client.do(t, "touch a 10"))
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "TOUCHED", client.do(t, "touch a 10"))
+		ttl, _ := cache.TTL("a")
+		require.Equal(t, 10*time.Second, *ttl)
+	})
+
+	t.Run("flush_all flushes the cache now or after a delay", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "OK", client.do(t, "flush_all"))
+		require.Zero(t, cache.Size())
+
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "OK", client.do(t, "flush_all 1"))
+		require.Equal(t, 1, cache.Size())
+		require.Eventually(t, func() bool { return cache.Size() == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("noreply suppresses replies", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		client.send(t, "set a 0 0 1 noreply", "1")
+		client.send(t, "add a 0 0 1 noreply", "2")
+		client.send(t, "incr a 1 noreply")
+		client.send(t, "delete b noreply")
+		require.Equal(t, []string{"VALUE a 0 1", "2", "END"}, client.lines(t, "get a"))
+	})
+
+	t.Run("stats reports the stats of the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.Get("a")
+		cache.Get("b")
+
+		stats := map[string]string{}
+		lines := client.lines(t, "stats")
+		for _, line := range lines[:len(lines)-1] {
+			fields := strings.Fields(line)
+			require.Len(t, fields, 3)
+			require.Equal(t, "STAT", fields[0])
+			stats[fields[1]] = fields[2]
+		}
+		require.Equal(t, "1", stats["curr_items"])
+		require.Equal(t, "1", stats["get_hits"])
+		require.Equal(t, "1", stats["get_misses"])
+		require.Equal(t, "2", stats["cmd_get"])
+		require.Equal(t, "1", stats["cmd_set"])
+		require.Equal(t, "allkeyslru", stats["policy"])
+		require.Equal(t, server.MemcachedVersion, stats["version"])
+
+		require.Equal(t, "RESET", client.do(t, "stats reset"))
+		require.Zero(t, cache.Stats().Hits)
+	})
+
+	t.Run("version reports the memcached version", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "VERSION "+server.MemcachedVersion, client.do(t, "version"))
+	})
+
+	t.Run("replies with errors to unknown & malformed commands", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		require.Equal(t, "ERROR", client.do(t, "nope"))
+		require.Equal(t, "ERROR", client.do(t, "get"))
+		require.Equal(t, "CLIENT_ERROR bad command line format", client.do(t, "set a 0 0"))
+		require.Equal(t, "CLIENT_ERROR bad command line format", client.do(t, "get "+strings.Repeat("a", 251)))
+		require.Equal(t, "CLIENT_ERROR bad data chunk", client.do(t, "set a 0 0 1", "toolong"))
+	})
+
+	t.Run("quit closes the connection", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveMemcached(t, cache)
+		client.send(t, "quit")
+		_, err := client.r.ReadByte()
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
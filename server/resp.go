@@ -0,0 +1,541 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/internal/glob"
+)
+
+const (
+	// maxRESPArgs is the maximum number of arguments of a command.
+	maxRESPArgs int = 64 * 1024
+	// maxRESPBulkLength is the maximum length of an argument of a command.
+	maxRESPBulkLength int = 16 * 1024 * 1024
+	// maxRESPInlineLength is the maximum length of a line of the protocol.
+	maxRESPInlineLength int = 64 * 1024
+)
+
+// NewRESP returns a server which serves cache to Redis clients using RESP2, or
+// RESP3 for clients which switch to it using HELLO 3.
+//
+// The following commands are supported, mapping directly onto the methods of
+// the cache:
+//
+//	GET key                                      Get
+//	SET key value [EX secs | PX millis] [NX|XX]  Set or SetEx
+//	DEL key [key ...]                            Delete
+//	EXISTS key [key ...]                         Contains
+//	TTL key, PTTL key                            TTL
+//	DBSIZE                                       Size
+//	RANDOMKEY                                    RandomKey
+//	KEYS pattern                                 Keys
+//	FLUSHDB [ASYNC|SYNC]                         Flush
+//	INFO [section]                               Stats, Size, ...
+//
+// Along with PING [message], QUIT and HELLO [protover [SETNAME name]].
+//
+// Keys are matched by KEYS using Redis' glob-style patterns, and are returned
+// sorted. Commands may have at most 65536 arguments of at most 16MiB each, and
+// clients sending larger ones are disconnected with a protocol error.
+func NewRESP(cache *memcache.Cache[string, []byte]) (*Server, error) {
+	s, err := newServer(cache)
+	if err != nil {
+		return nil, err
+	}
+	s.serveConn = s.serveRESP
+
+	return s, nil
+}
+
+// respProtocolError is returned when a client sends something other than a
+// command, after which its connection is closed.
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+type respCommand struct {
+	// arity is the exact number of arguments of the command including its
+	// name, or if negative, the minimum number.
+	arity int
+	run   func(c *respConn, args [][]byte)
+}
+
+var respCommands = map[string]respCommand{
+	"PING":      {-1, (*respConn).ping},
+	"HELLO":     {-1, (*respConn).hello},
+	"QUIT":      {-1, (*respConn).quit},
+	"GET":       {2, (*respConn).get},
+	"SET":       {-3, (*respConn).set},
+	"DEL":       {-2, (*respConn).del},
+	"EXISTS":    {-2, (*respConn).exists},
+	"TTL":       {2, func(c *respConn, args [][]byte) { c.ttl(args, time.Second) }},
+	"PTTL":      {2, func(c *respConn, args [][]byte) { c.ttl(args, time.Millisecond) }},
+	"DBSIZE":    {1, (*respConn).dbsize},
+	"RANDOMKEY": {1, (*respConn).randomKey},
+	"KEYS":      {2, (*respConn).keys},
+	"FLUSHDB":   {-1, (*respConn).flushdb},
+	"INFO":      {-1, (*respConn).info},
+}
+
+// respConn is a connection from a RESP client.
+type respConn struct {
+	*Server
+	r        *bufio.Reader
+	w        *bufio.Writer
+	protocol int
+	closing  bool
+}
+
+func (s *Server) serveRESP(conn net.Conn) {
+	c := &respConn{
+		Server:   s,
+		r:        bufio.NewReader(conn),
+		w:        bufio.NewWriter(conn),
+		protocol: 2,
+	}
+
+	for !c.closing {
+		args, err := c.readCommand()
+		if err != nil {
+			var protocolErr respProtocolError
+			if errors.As(err, &protocolErr) {
+				c.writeError("ERR " + protocolErr.Error())
+				_ = c.w.Flush()
+			}
+			return
+		}
+
+		if len(args) > 0 {
+			c.execute(args)
+		}
+
+		// replies to pipelined commands are flushed together.
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	_ = c.w.Flush()
+}
+
+func (c *respConn) execute(args [][]byte) {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := respCommands[name]
+	if !ok {
+		c.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	cmd.run(c, args[1:])
+}
+
+func (c *respConn) ping(args [][]byte) {
+	switch len(args) {
+	case 0:
+		c.writeSimple("PONG")
+	case 1:
+		c.writeBulk(args[0])
+	default:
+		c.writeError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (c *respConn) hello(args [][]byte) {
+	protocol := c.protocol
+	if len(args) > 0 {
+		v, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			c.writeError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != 2 && v != 3 {
+			c.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+		protocol = v
+
+		for i := 1; i < len(args); i++ {
+			if !strings.EqualFold(string(args[i]), "SETNAME") || i+1 == len(args) {
+				c.writeError("ERR syntax error")
+				return
+			}
+			i++ // connection names are accepted but not used.
+		}
+	}
+	c.protocol = protocol
+
+	c.writeMap(5)
+	c.writeBulkString("server")
+	c.writeBulkString("memcache")
+	c.writeBulkString("proto")
+	c.writeInteger(int64(c.protocol))
+	c.writeBulkString("mode")
+	c.writeBulkString("standalone")
+	c.writeBulkString("role")
+	c.writeBulkString("master")
+	c.writeBulkString("modules")
+	c.writeArray(0)
+}
+
+func (c *respConn) quit(_ [][]byte) {
+	c.writeSimple("OK")
+	c.closing = true
+}
+
+func (c *respConn) get(args [][]byte) {
+	value, ok := c.cache.Get(string(args[0]))
+	if !ok {
+		c.writeNull()
+		return
+	}
+
+	c.writeBulk(value)
+}
+
+func (c *respConn) set(args [][]byte) {
+	key, value := string(args[0]), args[1]
+
+	var nx, xx bool
+	var ttl time.Duration
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if ttl != 0 || i+1 == len(args) {
+				c.writeError("ERR syntax error")
+				return
+			}
+			i++
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				c.writeError("ERR value is not an integer or out of range")
+				return
+			}
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				c.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		c.writeError("ERR syntax error")
+		return
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	if nx || xx {
+		if exists := c.cache.Contains(key); (nx && exists) || (xx && !exists) {
+			c.writeNull()
+			return
+		}
+	}
+
+	if ttl > 0 {
+		c.cache.SetEx(key, value, ttl)
+	} else {
+		c.cache.Set(key, value)
+	}
+	c.writeSimple("OK")
+}
+
+func (c *respConn) del(args [][]byte) {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	var n int64
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok && c.cache.Contains(key) {
+			n++
+		}
+		seen[key] = struct{}{}
+	}
+	c.cache.Delete(keys...)
+
+	c.writeInteger(n)
+}
+
+func (c *respConn) exists(args [][]byte) {
+	var n int64
+	for _, arg := range args {
+		if c.cache.Contains(string(arg)) {
+			n++
+		}
+	}
+
+	c.writeInteger(n)
+}
+
+// ttl replies with the TTL of a key rounded to unit, -1 if it does not expire
+// or -2 if it does not exist.
+func (c *respConn) ttl(args [][]byte, unit time.Duration) {
+	ttl, ok := c.cache.TTL(string(args[0]))
+	switch {
+	case !ok || (ttl != nil && *ttl <= 0):
+		c.writeInteger(-2)
+	case ttl == nil:
+		c.writeInteger(-1)
+	default:
+		c.writeInteger(int64((*ttl + unit/2) / unit))
+	}
+}
+
+func (c *respConn) dbsize(_ [][]byte) {
+	c.writeInteger(int64(c.cache.Size()))
+}
+
+func (c *respConn) randomKey(_ [][]byte) {
+	key, ok := c.cache.RandomKey()
+	if !ok {
+		c.writeNull()
+		return
+	}
+
+	c.writeBulkString(key)
+}
+
+func (c *respConn) keys(args [][]byte) {
+	pattern := string(args[0])
+
+	var keys []string
+	for _, key := range c.cache.Keys() {
+		if glob.Match(pattern, key) && c.cache.Contains(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	c.writeArray(len(keys))
+	for _, key := range keys {
+		c.writeBulkString(key)
+	}
+}
+
+func (c *respConn) flushdb(args [][]byte) {
+	if len(args) > 1 {
+		c.writeError("ERR wrong number of arguments for 'flushdb' command")
+		return
+	}
+	if len(args) == 1 {
+		if mode := strings.ToUpper(string(args[0])); mode != "ASYNC" && mode != "SYNC" {
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	c.cache.Flush()
+	c.writeSimple("OK")
+}
+
+// info replies with the server, clients, keyspace and stats sections of INFO,
+// or only the requested ones, as lines of field:value.
+func (c *respConn) info(args [][]byte) {
+	sections := map[string]bool{}
+	for _, arg := range args {
+		sections[strings.ToLower(string(arg))] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["default"] || sections["everything"]
+
+	stats := c.cache.Stats()
+	var b strings.Builder
+	for _, section := range []struct {
+		name   string
+		fields []infoField
+	}{
+		{"server", []infoField{
+			{"server", "memcache"},
+			{"policy", c.cache.PolicyName()},
+			{"uptime_in_seconds", int64(time.Since(c.started).Seconds())},
+		}},
+		{"clients", []infoField{
+			{"connected_clients", c.connections()},
+		}},
+		{"keyspace", []infoField{
+			{"keys", c.cache.Size()},
+			{"weight", c.cache.Weight()},
+			{"capacity", c.cache.Capacity()},
+		}},
+		{"stats", []infoField{
+			{"keyspace_hits", stats.Hits},
+			{"keyspace_misses", stats.Misses},
+			{"hit_ratio", strconv.FormatFloat(stats.HitRatio(), 'f', 4, 64)},
+			{"sets", stats.Sets},
+			{"deletes", stats.Deletes},
+			{"evicted_keys", stats.Evictions},
+			{"expired_keys", stats.ActiveExpirations + stats.PassiveExpirations},
+			{"active_expirations", stats.ActiveExpirations},
+			{"passive_expirations", stats.PassiveExpirations},
+			{"expired_lookups", stats.ExpiredLookups},
+			{"load_successes", stats.LoadSuccesses},
+			{"load_failures", stats.LoadFailures},
+		}},
+	} {
+		if !all && !sections[section.name] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(section.name[:1]) + section.name[1:] + "\r\n")
+		for _, field := range section.fields {
+			fmt.Fprintf(&b, "%s:%v\r\n", field.name, field.value)
+		}
+	}
+
+	c.writeBulkString(b.String())
+}
+
+type infoField struct {
+	name  string
+	value any
+}
+
+// readCommand reads the arguments of the next command, sent either as an array
+// of bulk strings or inline as a line of space separated arguments.
+func (c *respConn) readCommand() ([][]byte, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxRESPArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+
+	args := make([][]byte, 0, max(min(n, 64), 0))
+	for i := 0; i < n; i++ {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%s'", line))
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxRESPBulkLength {
+			return nil, respProtocolError("invalid bulk length")
+		}
+
+		arg, err := c.readBulk(size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// readBulk reads a bulk string of size bytes followed by CRLF, growing the
+// returned slice as bytes are read rather than up front so that clients cannot
+// make the server allocate more memory than they send.
+func (c *respConn) readBulk(size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, c.r, int64(size)+2); err != nil {
+		return nil, err
+	}
+	arg := buf.Bytes()
+	if !bytes.HasSuffix(arg, []byte("\r\n")) {
+		return nil, respProtocolError("expected CRLF after bulk string")
+	}
+
+	return arg[:size:size], nil
+}
+
+// readLine reads the next line without its line ending.
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := readLine(c.r, maxRESPInlineLength)
+	if errors.Is(err, errLineTooLong) {
+		return nil, respProtocolError("too big inline request")
+	}
+
+	return line, err
+}
+
+func (c *respConn) writeSimple(s string) {
+	c.w.WriteString("+" + s + "\r\n")
+}
+
+func (c *respConn) writeError(s string) {
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	c.w.WriteString("-" + s + "\r\n")
+}
+
+func (c *respConn) writeInteger(n int64) {
+	c.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (c *respConn) writeBulk(b []byte) {
+	c.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	c.w.Write(b)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeBulkString(s string) {
+	c.w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (c *respConn) writeNull() {
+	if c.protocol == 3 {
+		c.w.WriteString("_\r\n")
+		return
+	}
+	c.w.WriteString("$-1\r\n")
+}
+
+func (c *respConn) writeArray(n int) {
+	c.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// writeMap writes the header of a map of n pairs, which is an array of its
+// flattened pairs in RESP2.
+func (c *respConn) writeMap(n int) {
+	if c.protocol == 3 {
+		c.w.WriteString("%" + strconv.Itoa(n) + "\r\n")
+		return
+	}
+	c.writeArray(2 * n)
+}
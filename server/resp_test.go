@@ -0,0 +1,493 @@
+package server_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wafer-bw/memcache"
+	"github.com/wafer-bw/memcache/memcachetest"
+	"github.com/wafer-bw/memcache/server"
+)
+
+var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// respError is an error reply.
+type respError string
+
+// respNull is a null reply.
+type respNull struct{}
+
+// respClient sends commands to a RESP server and parses its replies into
+// strings for simple strings, respError, int64, []byte for bulk strings,
+// respNull, []any for arrays and map[string]any for maps.
+type respClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *respClient) do(t *testing.T, args ...string) any {
+	t.Helper()
+
+	c.send(t, args...)
+	return c.reply(t)
+}
+
+func (c *respClient) send(t *testing.T, args ...string) {
+	t.Helper()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	require.NoError(t, err)
+}
+
+func (c *respClient) reply(t *testing.T) any {
+	t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	require.NoError(t, err)
+	line = strings.TrimSuffix(line, "\r\n")
+
+	kind, rest := line[0], line[1:]
+	switch kind {
+	case '+':
+		return rest
+	case '-':
+		return respError(rest)
+	case ':':
+		n, err := strconv.ParseInt(rest, 10, 64)
+		require.NoError(t, err)
+		return n
+	case '_':
+		return respNull{}
+	case '$':
+		n, err := strconv.Atoi(rest)
+		require.NoError(t, err)
+		if n < 0 {
+			return respNull{}
+		}
+		b := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, b)
+		require.NoError(t, err)
+		return b[:n]
+	case '*':
+		n, err := strconv.Atoi(rest)
+		require.NoError(t, err)
+		array := make([]any, n)
+		for i := range array {
+			array[i] = c.reply(t)
+		}
+		return array
+	case '%':
+		n, err := strconv.Atoi(rest)
+		require.NoError(t, err)
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			key := c.reply(t)
+			m[string(key.([]byte))] = c.reply(t)
+		}
+		return m
+	default:
+		t.Fatalf("unexpected reply %q", line)
+		return nil
+	}
+}
+
+// serveRESP serves cache over loopback and returns a client connected to it.
+func serveRESP(t *testing.T, cache *memcache.Cache[string, []byte]) *respClient {
+	t.Helper()
+
+	s, err := server.NewRESP(cache)
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, s.Close())
+		require.ErrorIs(t, <-done, server.ErrServerClosed)
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &respClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func openCache(t *testing.T, options ...memcache.Option[string, []byte]) (*memcache.Cache[string, []byte], *memcachetest.Clock) {
+	t.Helper()
+
+	clock := memcachetest.NewClock(now)
+	options = append(options, memcache.WithClock[string, []byte](clock))
+	cache, err := memcache.OpenAllKeysLRUCache(100, options...)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	return cache, clock
+}
+
+func TestNewRESP(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns an error if the cache is nil", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := server.NewRESP(nil)
+		require.ErrorIs(t, err, server.ErrInvalidCache)
+		require.Nil(t, s)
+	})
+}
+
+func TestServer_RESP(t *testing.T) {
+	t.Parallel()
+
+	t.Run("PING replies with PONG or the message", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, "PONG", client.do(t, "PING"))
+		require.Equal(t, []byte("hello"), client.do(t, "ping", "hello"))
+	})
+
+	t.Run("GET & SET read and write the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, respNull{}, client.do(t, "GET", "a"))
+		require.Equal(t, "OK", client.do(t, "SET", "a", "1"))
+		require.Equal(t, []byte("1"), client.do(t, "GET", "a"))
+
+		value, ok := cache.Get("a")
+		require.True(t, ok)
+		require.Equal(t, []byte("1"), value)
+		ttl, _ := cache.TTL("a")
+		require.Nil(t, ttl)
+	})
+
+	t.Run("SET with EX or PX sets a ttl", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, "OK", client.do(t, "SET", "a", "1", "EX", "10"))
+		require.Equal(t, "OK", client.do(t, "SET", "b", "2", "px", "1500"))
+		require.Equal(t, int64(10), client.do(t, "TTL", "a"))
+		require.Equal(t, int64(10_000), client.do(t, "PTTL", "a"))
+		require.Equal(t, int64(2), client.do(t, "TTL", "b"), "rounds to the nearest second")
+
+		clock.Advance(2 * time.Second)
+		require.Equal(t, respNull{}, client.do(t, "GET", "b"))
+		require.Equal(t, int64(-2), client.do(t, "TTL", "b"))
+	})
+
+	t.Run("SET with NX only sets missing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, "OK", client.do(t, "SET", "a", "1", "NX"))
+		require.Equal(t, respNull{}, client.do(t, "SET", "a", "2", "NX"))
+		require.Equal(t, []byte("1"), client.do(t, "GET", "a"))
+	})
+
+	t.Run("SET with XX only sets existing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, respNull{}, client.do(t, "SET", "a", "1", "XX"))
+		require.Equal(t, respNull{}, client.do(t, "GET", "a"))
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "OK", client.do(t, "SET", "a", "2", "XX", "EX", "5"))
+		require.Equal(t, []byte("2"), client.do(t, "GET", "a"))
+	})
+
+	t.Run("SET replies with an error for invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, respError("ERR syntax error"), client.do(t, "SET", "a", "1", "NX", "XX"))
+		require.Equal(t, respError("ERR syntax error"), client.do(t, "SET", "a", "1", "EX", "1", "PX", "1"))
+		require.Equal(t, respError("ERR syntax error"), client.do(t, "SET", "a", "1", "EX"))
+		require.Equal(t, respError("ERR syntax error"), client.do(t, "SET", "a", "1", "KEEPTTL"))
+		require.Equal(t, respError("ERR value is not an integer or out of range"), client.do(t, "SET", "a", "1", "EX", "x"))
+		require.Equal(t, respError("ERR invalid expire time in 'set' command"), client.do(t, "SET", "a", "1", "EX", "0"))
+		require.Equal(t, respError("ERR wrong number of arguments for 'set' command"), client.do(t, "SET", "a"))
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("DEL deletes keys and replies with how many existed", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.Set("b", []byte("2"))
+		require.Equal(t, int64(2), client.do(t, "DEL", "a", "b", "a", "c"))
+		require.Zero(t, cache.Size())
+	})
+
+	t.Run("EXISTS replies with how many keys exist", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.SetEx("b", []byte("2"), time.Second)
+		require.Equal(t, int64(3), client.do(t, "EXISTS", "a", "b", "a", "c"))
+
+		clock.Advance(time.Minute)
+		require.Equal(t, int64(1), client.do(t, "EXISTS", "b", "a"))
+	})
+
+	t.Run("EXISTS & KEYS do not count as accesses of keys when evicting", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t, memcache.WithCapacity[string, []byte](2))
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.Set("b", []byte("2"))
+		require.Equal(t, int64(1), client.do(t, "EXISTS", "a"))
+		require.Len(t, client.do(t, "KEYS", "a"), 1)
+		cache.Set("c", []byte("3"))
+
+		require.ElementsMatch(t, []string{"b", "c"}, cache.Keys())
+	})
+
+	t.Run("TTL & PTTL reply with -1 for keys without a ttl and -2 for missing keys", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		require.Equal(t, int64(-1), client.do(t, "TTL", "a"))
+		require.Equal(t, int64(-1), client.do(t, "PTTL", "a"))
+		require.Equal(t, int64(-2), client.do(t, "TTL", "b"))
+		require.Equal(t, int64(-2), client.do(t, "PTTL", "b"))
+	})
+
+	t.Run("DBSIZE replies with the size of the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, int64(0), client.do(t, "DBSIZE"))
+		cache.Set("a", []byte("1"))
+		cache.Set("b", []byte("2"))
+		require.Equal(t, int64(2), client.do(t, "DBSIZE"))
+	})
+
+	t.Run("RANDOMKEY replies with a key or null if the cache is empty", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, respNull{}, client.do(t, "RANDOMKEY"))
+		cache.Set("a", []byte("1"))
+		require.Equal(t, []byte("a"), client.do(t, "RANDOMKEY"))
+	})
+
+	t.Run("KEYS replies with the sorted keys matching the pattern", func(t *testing.T) {
+		t.Parallel()
+
+		cache, clock := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("user:2", []byte("2"))
+		cache.Set("user:1", []byte("1"))
+		cache.SetEx("user:3", []byte("3"), time.Second)
+		cache.Set("order:1", []byte("1"))
+		clock.Advance(time.Minute)
+
+		require.Equal(t, []any{[]byte("user:1"), []byte("user:2")}, client.do(t, "KEYS", "user:*"))
+		require.Equal(t, []any{}, client.do(t, "KEYS", "missing*"))
+	})
+
+	t.Run("FLUSHDB flushes the cache", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		require.Equal(t, "OK", client.do(t, "FLUSHDB"))
+		require.Zero(t, cache.Size())
+		require.Equal(t, "OK", client.do(t, "FLUSHDB", "ASYNC"))
+		require.Equal(t, respError("ERR syntax error"), client.do(t, "FLUSHDB", "NOW"))
+	})
+
+	t.Run("INFO replies with the requested sections", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		cache.Set("a", []byte("1"))
+		cache.Get("a")
+		cache.Get("b")
+
+		info := string(client.do(t, "INFO").([]byte))
+		for _, line := range []string{"# Server", "policy:allkeyslru", "# Clients", "connected_clients:1", "# Keyspace", "keys:1", "capacity:100", "# Stats", "keyspace_hits:1", "keyspace_misses:1"} {
+			require.Contains(t, strings.Split(info, "\r\n"), line)
+		}
+
+		info = string(client.do(t, "INFO", "stats").([]byte))
+		require.True(t, strings.HasPrefix(info, "# Stats\r\n"))
+		require.NotContains(t, info, "# Server")
+	})
+
+	t.Run("HELLO switches protocol version", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+
+		reply := client.do(t, "HELLO")
+		require.IsType(t, []any{}, reply, "replies with a flattened map in RESP2")
+		require.Contains(t, reply, int64(2))
+		require.Equal(t, respNull{}, client.do(t, "GET", "a"))
+
+		reply = client.do(t, "HELLO", "3", "SETNAME", "test")
+		require.IsType(t, map[string]any{}, reply)
+		require.Equal(t, int64(3), reply.(map[string]any)["proto"])
+		require.Equal(t, respNull{}, client.do(t, "GET", "a"))
+
+		require.Equal(t, respError("NOPROTO unsupported protocol version"), client.do(t, "HELLO", "4"))
+	})
+
+	t.Run("replies with an error for unknown commands and wrong arities", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, respError("ERR unknown command 'NOPE'"), client.do(t, "NOPE"))
+		require.Equal(t, respError("ERR wrong number of arguments for 'get' command"), client.do(t, "GET"))
+		require.Equal(t, respError("ERR wrong number of arguments for 'get' command"), client.do(t, "GET", "a", "b"))
+	})
+
+	t.Run("serves inline commands", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		_, err := io.WriteString(client.conn, "SET a 1\r\nGET a\n")
+		require.NoError(t, err)
+		require.Equal(t, "OK", client.reply(t))
+		require.Equal(t, []byte("1"), client.reply(t))
+	})
+
+	t.Run("serves pipelined commands in order", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		for i := 0; i < 100; i++ {
+			client.send(t, "SET", strconv.Itoa(i), strconv.Itoa(i))
+			client.send(t, "GET", strconv.Itoa(i))
+		}
+		for i := 0; i < 100; i++ {
+			require.Equal(t, "OK", client.reply(t))
+			require.Equal(t, []byte(strconv.Itoa(i)), client.reply(t))
+		}
+	})
+
+	t.Run("QUIT closes the connection", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		require.Equal(t, "OK", client.do(t, "QUIT"))
+		_, err := client.r.ReadByte()
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("closes the connection after a protocol error", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		client := serveRESP(t, cache)
+		_, err := io.WriteString(client.conn, "*1\r\n+GET\r\n")
+		require.NoError(t, err)
+		reply := client.reply(t)
+		require.IsType(t, respError(""), reply)
+		require.True(t, strings.HasPrefix(string(reply.(respError)), "ERR Protocol error"))
+		_, err = client.r.ReadByte()
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("rejects commands with too many or too long arguments", func(t *testing.T) {
+		t.Parallel()
+
+		for name, command := range map[string]string{
+			"arguments": "*100000\r\n",
+			"length":    "*2\r\n$3\r\nGET\r\n$1000000000\r\n",
+		} {
+			command := command
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				cache, _ := openCache(t)
+				client := serveRESP(t, cache)
+				_, err := io.WriteString(client.conn, command)
+				require.NoError(t, err)
+				reply := client.reply(t)
+				require.IsType(t, respError(""), reply)
+				require.True(t, strings.HasPrefix(string(reply.(respError)), "ERR Protocol error"))
+			})
+		}
+	})
+}
+
+func TestServer_Close(t *testing.T) {
+	t.Parallel()
+
+	t.Run("closes connections and stops serving", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		s, err := server.NewRESP(cache)
+		require.NoError(t, err)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		done := make(chan error, 1)
+		go func() { done <- s.Serve(l) }()
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		client := &respClient{conn: conn, r: bufio.NewReader(conn)}
+		require.Equal(t, "PONG", client.do(t, "PING"))
+
+		require.NoError(t, s.Close())
+		require.ErrorIs(t, <-done, server.ErrServerClosed)
+		_, err = client.r.ReadByte()
+		require.Error(t, err)
+	})
+
+	t.Run("serving after closing returns ErrServerClosed", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := openCache(t)
+		s, err := server.NewRESP(cache)
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		require.ErrorIs(t, s.Serve(l), server.ErrServerClosed)
+		_, err = l.Accept()
+		require.True(t, errors.Is(err, net.ErrClosed))
+	})
+}
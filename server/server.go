@@ -0,0 +1,209 @@
+// Package server serves a cache over TCP using protocols spoken by existing
+// clients, so that processes written in other languages, or tools such as
+// redis-cli, can share a cache with the Go process which owns it.
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wafer-bw/memcache"
+)
+
+var (
+	ErrInvalidCache = errors.New("provided cache must not be nil")
+	ErrServerClosed = errors.New("server closed")
+)
+
+// errLineTooLong is returned by readLine for lines longer than its limit.
+var errLineTooLong = errors.New("line too long")
+
+// Server serves a cache to the clients of a protocol over TCP.
+type Server struct {
+	cache     *memcache.Cache[string, []byte]
+	serveConn func(conn net.Conn)
+
+	// writes serializes commands which write to the cache, so that
+	// conditional writes such as SET with NX are atomic with respect to the
+	// other clients of the server. They are not atomic with respect to writes
+	// made to the cache directly.
+	writes sync.Mutex
+	// flushTimer flushes the cache after the delay of the last delayed
+	// flush_all, guarded by writes.
+	flushTimer *time.Timer
+
+	started time.Time
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	serving   sync.WaitGroup
+}
+
+func newServer(cache *memcache.Cache[string, []byte]) (*Server, error) {
+	if cache == nil {
+		return nil, ErrInvalidCache
+	}
+
+	return &Server{
+		cache:     cache,
+		started:   time.Now(),
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}, nil
+}
+
+// ListenAndServe listens on the TCP network address addr and serves incoming
+// connections, see [Server.Serve].
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts & serves incoming connections on l until the server is closed,
+// after which it returns [ErrServerClosed]. Serve takes ownership of l and
+// closes it before returning.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+	defer l.Close()
+
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// back off temporary errors such as running out of file
+				// descriptors rather than spinning.
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		if !s.trackConn(conn) {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrackConn(conn)
+			defer conn.Close()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close the server, closing its listeners and connections and waiting for the
+// commands being served to return. The cache is not closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.writes.Lock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+	s.writes.Unlock()
+
+	s.serving.Wait()
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Server) track(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.serving.Add(1)
+	return true
+}
+
+// connections returns the number of open connections.
+func (s *Server) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+	s.serving.Done()
+}
+
+// readLine reads the next line from r without its line ending, which is either
+// CRLF or LF, returning errLineTooLong if it is longer than limit.
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return nil, errLineTooLong
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+	}
+
+	return bytes.TrimSuffix(line[:len(line)-1], []byte("\r")), nil
+}
@@ -0,0 +1,526 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wafer-bw/memcache/data"
+)
+
+// Snapshots are written in the following format, with integers encoded as
+// varints unless stated otherwise:
+//
+//	magic    the bytes "MCSN"
+//	version  of the format as a big endian uint16
+//	codec    length prefixed name of the codec of the cache
+//	policy   length prefixed name of the policy of the cache
+//	entries  one per item, each starting with a byte of 1
+//	trailer  a byte of 0, the number of entries, and the CRC-32C checksum of
+//	         every preceding byte as a big endian uint32
+//
+// Each entry is made up of its length prefixed key & value as encoded by the
+// codec, a byte of flags marking which of its expiry and stale times follow as
+// unix nanoseconds, its load duration and its access frequency.
+const snapshotVersion uint16 = 1
+
+var snapshotMagic = [4]byte{'M', 'C', 'S', 'N'}
+
+const (
+	snapshotTrailer byte = iota
+	snapshotEntry
+)
+
+const (
+	snapshotExpireAt byte = 1 << iota
+	snapshotStaleAt
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// SnapshotVersionError is returned by [Cache.Restore] for snapshots written in
+// an unsupported version of the snapshot format.
+type SnapshotVersionError struct {
+	Version   uint16
+	Supported uint16
+}
+
+func (e SnapshotVersionError) Error() string {
+	return fmt.Sprintf("snapshot version %d is not supported, expected %d", e.Version, e.Supported)
+}
+
+func (e SnapshotVersionError) Unwrap() error {
+	return ErrInvalidSnapshot
+}
+
+// SnapshotCodecError is returned by [Cache.Restore] for snapshots written by a
+// cache using a different codec, see [WithCodec].
+type SnapshotCodecError struct {
+	Codec    string
+	Expected string
+}
+
+func (e SnapshotCodecError) Error() string {
+	return fmt.Sprintf("snapshot codec %q does not match %q", e.Codec, e.Expected)
+}
+
+func (e SnapshotCodecError) Unwrap() error {
+	return ErrInvalidSnapshot
+}
+
+// SnapshotChecksumError is returned by [Cache.Restore] for snapshots whose
+// contents do not match their checksum, usually because they are corrupt.
+type SnapshotChecksumError struct {
+	Checksum uint32
+	Expected uint32
+}
+
+func (e SnapshotChecksumError) Error() string {
+	return fmt.Sprintf("snapshot checksum %08x does not match %08x", e.Checksum, e.Expected)
+}
+
+func (e SnapshotChecksumError) Unwrap() error {
+	return ErrInvalidSnapshot
+}
+
+// snapshotItem is an item of a snapshot whose key & value are still encoded.
+type snapshotItem struct {
+	key          []byte
+	value        []byte
+	expireAt     *time.Time
+	staleAt      *time.Time
+	loadDuration time.Duration
+	frequency    int // access frequency if tracked by the policy, otherwise 0.
+}
+
+// Snapshot writes every unexpired item in the cache to w so that they can be
+// restored via [Cache.Restore], for example by a new process after a restart.
+//
+// Keys and values are encoded using the codec of the cache, see [WithCodec].
+// Expiry times are written as absolute times.
+//
+// Where the policy of the cache supports it, items are written in the order
+// they would be evicted along with their access frequency, so that restoring
+// them into a cache of the same policy preserves which items are evicted
+// first. Every lru and lfu policy supports this.
+func (c *Cache[K, V]) Snapshot(w io.Writer) error {
+	sw := &snapshotWriter{w: bufio.NewWriter(w), crc: crc32.New(castagnoli)}
+	sw.write(snapshotMagic[:])
+	sw.write(binary.BigEndian.AppendUint16(nil, snapshotVersion))
+	sw.bytes([]byte(c.codec.Name()))
+	sw.bytes([]byte(c.policyName))
+
+	items := c.store.Items()
+	tracker, _ := c.store.(FrequencyTracker[K])
+	now := c.clock.Now()
+	var count uint64
+	for _, key := range c.rankedKeys(items) {
+		item := items[key]
+		if item.IsExpiredAt(now) {
+			continue
+		}
+
+		encodedKey, err := c.codec.EncodeKey(key)
+		if err != nil {
+			return err
+		}
+		encodedValue, err := c.codec.EncodeValue(item.Value)
+		if err != nil {
+			return err
+		}
+		entry := snapshotItem{
+			key:          encodedKey,
+			value:        encodedValue,
+			expireAt:     item.ExpireAt,
+			staleAt:      item.StaleAt,
+			loadDuration: item.LoadDuration,
+		}
+		if tracker != nil {
+			entry.frequency = tracker.Frequency(key)
+		}
+		sw.entry(entry)
+		count++
+	}
+
+	sw.write([]byte{snapshotTrailer})
+	sw.uvarint(count)
+	if sw.err != nil {
+		return sw.err
+	}
+	if _, err := sw.w.Write(binary.BigEndian.AppendUint32(nil, sw.crc.Sum32())); err != nil {
+		return err
+	}
+
+	return sw.w.Flush()
+}
+
+// Restore sets every item written to r by [Cache.Snapshot] in the cache,
+// skipping items which have expired since. Existing keys of the cache are kept
+// unless restored items replace them.
+//
+// The snapshot is read and verified in full before any item is set, so invalid
+// snapshots leave the cache untouched. Their errors wrap [ErrInvalidSnapshot],
+// and are a [SnapshotVersionError], [SnapshotCodecError] or
+// [SnapshotChecksumError] where applicable. Items are set in the order they
+// were written, so restoring more items than the cache can hold evicts as
+// usual.
+func (c *Cache[K, V]) Restore(r io.Reader) error {
+	items, err := readSnapshot(r, c.codec.Name())
+	if err != nil {
+		return err
+	}
+
+	entries := make([]data.Entry[K, V], len(items))
+	for i, item := range items {
+		key, err := c.codec.DecodeKey(item.key)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+		}
+		value, err := c.codec.DecodeValue(item.value)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+		}
+		entries[i] = data.Entry[K, V]{Key: key, Item: data.Item[K, V]{
+			Value:        value,
+			ExpireAt:     item.expireAt,
+			StaleAt:      item.staleAt,
+			LoadDuration: item.loadDuration,
+		}}
+	}
+
+	tracker, _ := c.store.(FrequencyTracker[K])
+	now := c.clock.Now()
+	for i, entry := range entries {
+		if entry.Item.IsExpiredAt(now) {
+			continue
+		}
+
+		c.add(entry.Key, entry.Item)
+		if tracker != nil && items[i].frequency > 0 {
+			tracker.SetFrequency(entry.Key, items[i].frequency)
+		}
+	}
+
+	return nil
+}
+
+// readSnapshot reads & verifies the snapshot in r, which must have been written
+// using the codec named codec.
+func readSnapshot(r io.Reader, codec string) ([]snapshotItem, error) {
+	sr := &snapshotReader{r: bufio.NewReader(r), crc: crc32.New(castagnoli)}
+
+	magic, err := sr.read(uint64(len(snapshotMagic)))
+	if err != nil {
+		return nil, invalidSnapshot(err)
+	}
+	if !bytes.Equal(magic, snapshotMagic[:]) {
+		return nil, fmt.Errorf("%w: not a snapshot", ErrInvalidSnapshot)
+	}
+
+	version, err := sr.read(2)
+	if err != nil {
+		return nil, invalidSnapshot(err)
+	}
+	if v := binary.BigEndian.Uint16(version); v != snapshotVersion {
+		return nil, SnapshotVersionError{Version: v, Supported: snapshotVersion}
+	}
+
+	name, err := sr.bytes()
+	if err != nil {
+		return nil, invalidSnapshot(err)
+	}
+	if string(name) != codec {
+		return nil, SnapshotCodecError{Codec: string(name), Expected: codec}
+	}
+
+	if _, err := sr.bytes(); err != nil { // policy.
+		return nil, invalidSnapshot(err)
+	}
+
+	var items []snapshotItem
+	for {
+		tag, err := sr.ReadByte()
+		if err != nil {
+			return nil, invalidSnapshot(err)
+		}
+
+		switch tag {
+		case snapshotEntry:
+			item, err := sr.entry()
+			if err != nil {
+				return nil, invalidSnapshot(err)
+			}
+			items = append(items, item)
+		case snapshotTrailer:
+			count, err := sr.uvarint()
+			if err != nil {
+				return nil, invalidSnapshot(err)
+			}
+			expected := sr.crc.Sum32()
+			checksum, err := sr.read(4)
+			if err != nil {
+				return nil, invalidSnapshot(err)
+			}
+			if c := binary.BigEndian.Uint32(checksum); c != expected {
+				return nil, SnapshotChecksumError{Checksum: c, Expected: expected}
+			}
+			if count != uint64(len(items)) {
+				return nil, fmt.Errorf("%w: has %d entries, expected %d", ErrInvalidSnapshot, len(items), count)
+			}
+			return items, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown tag %d", ErrInvalidSnapshot, tag)
+		}
+	}
+}
+
+// invalidSnapshot wraps err reading a snapshot, which is unexpected even if it
+// is io.EOF because snapshots end with a trailer.
+func invalidSnapshot(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+}
+
+// snapshotWriter writes the parts of a snapshot while checksumming them. The
+// first error writing is kept, after which writes do nothing.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *snapshotWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	_, _ = w.crc.Write(p)
+	_, w.err = w.w.Write(p)
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	w.write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *snapshotWriter) varint(v int64) {
+	w.write(w.buf[:binary.PutVarint(w.buf[:], v)])
+}
+
+func (w *snapshotWriter) bytes(p []byte) {
+	w.uvarint(uint64(len(p)))
+	w.write(p)
+}
+
+func (w *snapshotWriter) entry(item snapshotItem) {
+	var flags byte
+	if item.expireAt != nil {
+		flags |= snapshotExpireAt
+	}
+	if item.staleAt != nil {
+		flags |= snapshotStaleAt
+	}
+
+	w.write([]byte{snapshotEntry})
+	w.bytes(item.key)
+	w.bytes(item.value)
+	w.write([]byte{flags})
+	if item.expireAt != nil {
+		w.varint(item.expireAt.UnixNano())
+	}
+	if item.staleAt != nil {
+		w.varint(item.staleAt.UnixNano())
+	}
+	w.varint(int64(item.loadDuration))
+	w.uvarint(uint64(item.frequency))
+}
+
+// snapshotReader reads the parts of a snapshot while checksumming them.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (r *snapshotReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		_, _ = r.crc.Write([]byte{b})
+	}
+
+	return b, err
+}
+
+// read n bytes, growing the returned slice as bytes are read rather than up
+// front so that corrupt lengths cannot exhaust memory.
+func (r *snapshotReader) read(n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("length %d is too long", n)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r.r, int64(n)); err != nil {
+		return nil, err
+	}
+	_, _ = r.crc.Write(buf.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func (r *snapshotReader) uvarint() (uint64, error) {
+	return binary.ReadUvarint(r)
+}
+
+func (r *snapshotReader) varint() (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+func (r *snapshotReader) bytes() ([]byte, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.read(n)
+}
+
+func (r *snapshotReader) entry() (snapshotItem, error) {
+	var item snapshotItem
+	var err error
+	if item.key, err = r.bytes(); err != nil {
+		return item, err
+	}
+	if item.value, err = r.bytes(); err != nil {
+		return item, err
+	}
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return item, err
+	}
+	if flags&snapshotExpireAt != 0 {
+		if item.expireAt, err = r.time(); err != nil {
+			return item, err
+		}
+	}
+	if flags&snapshotStaleAt != 0 {
+		if item.staleAt, err = r.time(); err != nil {
+			return item, err
+		}
+	}
+
+	loadDuration, err := r.varint()
+	if err != nil {
+		return item, err
+	}
+	item.loadDuration = time.Duration(loadDuration)
+
+	frequency, err := r.uvarint()
+	if err != nil {
+		return item, err
+	}
+	item.frequency = int(min(frequency, math.MaxInt))
+
+	return item, nil
+}
+
+func (r *snapshotReader) time() (*time.Time, error) {
+	nanos, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(0, nanos)
+
+	return &t, nil
+}
+
+// rankedKeys returns the keys of items in the order the store ranks them, if it
+// does, followed by any keys of items it did not rank.
+func (c *Cache[K, V]) rankedKeys(items map[K]data.Item[K, V]) []K {
+	keys := make([]K, 0, len(items))
+	ranked := make(map[K]struct{}, len(items))
+	if ranker, ok := c.store.(Ranker[K]); ok {
+		for _, key := range ranker.Ranked() {
+			if _, ok := items[key]; ok {
+				keys = append(keys, key)
+				ranked[key] = struct{}{}
+			}
+		}
+	}
+
+	for key := range items {
+		if _, ok := ranked[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// snapshotFile writes a snapshot of the cache to path atomically, by writing
+// it to a temporary file in the same directory which is renamed to path.
+func (c *Cache[K, V]) snapshotFile(path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err := c.Snapshot(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// restoreFile restores the snapshot at path, if there is one.
+func (c *Cache[K, V]) restoreFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Restore(bufio.NewReader(f))
+}
+
+func (c *Cache[K, V]) runPeriodicSnapshot(ticker Ticker) {
+	defer c.snapshotting.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			_ = c.snapshotFile(c.snapshotPath)
+		case <-c.closer.Ch():
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package memcache
+
+import (
+	"sync/atomic"
+)
+
+// Stats are counters of the operations of a [Cache] since it was opened or its
+// stats were last reset via [Cache.ResetStats].
+type Stats struct {
+	// Hits is the number of lookups via [Cache.Get] or [Cache.GetOrLoad]
+	// which found a value.
+	Hits uint64
+	// Misses is the number of lookups which did not find a value, including
+	// those of expired values counted by ExpiredLookups.
+	Misses uint64
+	// Sets is the number of values set in the cache, including those loaded
+	// via [Cache.GetOrLoad] or refreshed via [WithRefresh].
+	Sets uint64
+	// Deletes is the number of keys deleted via [Cache.Delete] or
+	// [Cache.CompareAndDelete], not counting keys which did not exist or had
+	// expired.
+	Deletes uint64
+	// Evictions is the number of values evicted by the policy of the cache in
+	// order to remain within its capacity. It is only counted by stores which
+	// implement [EvictionCounter], as every built-in policy bar
+	// [NoEvictionPolicy] does.
+	Evictions uint64
+	// ActiveExpirations is the number of expired keys deleted by active
+	// expiration, see [WithActiveExpiration].
+	ActiveExpirations uint64
+	// PassiveExpirations is the number of expired keys deleted by passive
+	// expiration, see [WithPassiveExpiration].
+	PassiveExpirations uint64
+	// ExpiredLookups is the number of lookups which found an expired value and
+	// reported it as missing.
+	ExpiredLookups uint64
+	// LoadSuccesses is the number of calls to the loader of
+	// [Cache.GetOrLoad] which returned a value.
+	LoadSuccesses uint64
+	// LoadFailures is the number of calls to the loader of [Cache.GetOrLoad]
+	// which returned an error.
+	LoadFailures uint64
+}
+
+// HitRatio returns the fraction of lookups which were hits, or 0 if there were
+// no lookups.
+func (s Stats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(lookups)
+}
+
+// counters of the operations of a cache, which are atomic so that counting
+// does not contend on the locks of the store.
+type counters struct {
+	hits               atomic.Uint64
+	misses             atomic.Uint64
+	sets               atomic.Uint64
+	deletes            atomic.Uint64
+	evictions          atomic.Uint64 // evictions counted by the store as of the last reset.
+	activeExpirations  atomic.Uint64
+	passiveExpirations atomic.Uint64
+	expiredLookups     atomic.Uint64
+	loadSuccesses      atomic.Uint64
+	loadFailures       atomic.Uint64
+}
+
+// snapshot returns the current value of every counter given the total number
+// of evictions counted by the store.
+func (c *counters) snapshot(evictions uint64) Stats {
+	return Stats{
+		Hits:               c.hits.Load(),
+		Misses:             c.misses.Load(),
+		Sets:               c.sets.Load(),
+		Deletes:            c.deletes.Load(),
+		Evictions:          evictions - min(c.evictions.Load(), evictions),
+		ActiveExpirations:  c.activeExpirations.Load(),
+		PassiveExpirations: c.passiveExpirations.Load(),
+		ExpiredLookups:     c.expiredLookups.Load(),
+		LoadSuccesses:      c.loadSuccesses.Load(),
+		LoadFailures:       c.loadFailures.Load(),
+	}
+}
+
+// reset every counter to zero given the total number of evictions counted by
+// the store, which cannot be reset and so is subtracted from future snapshots.
+func (c *counters) reset(evictions uint64) {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.sets.Store(0)
+	c.deletes.Store(0)
+	c.evictions.Store(evictions)
+	c.activeExpirations.Store(0)
+	c.passiveExpirations.Store(0)
+	c.expiredLookups.Store(0)
+	c.loadSuccesses.Store(0)
+	c.loadFailures.Store(0)
+}
+
+// Stats returns a snapshot of the counters of the cache. Counters are updated
+// independently, so a snapshot taken during concurrent use of the cache may be
+// slightly inconsistent between counters.
+func (c *Cache[K, V]) Stats() Stats {
+	return c.stats.snapshot(c.storeEvictions())
+}
+
+// ResetStats resets every counter of the cache to zero.
+func (c *Cache[K, V]) ResetStats() {
+	c.stats.reset(c.storeEvictions())
+}
+
+// storeEvictions returns the total number of evictions counted by the store,
+// or 0 if it does not count them.
+func (c *Cache[K, V]) storeEvictions() uint64 {
+	if counter, ok := c.store.(EvictionCounter); ok {
+		return counter.Evictions()
+	}
+
+	return 0
+}
+
+// activeExpirer is the view of a cache given to its expirer, which counts the
+// keys it deletes as active expirations rather than deletes.
+type activeExpirer[K comparable, V any] struct {
+	*Cache[K, V]
+}
+
+func (e activeExpirer[K, V]) Delete(keys ...K) {
+	e.stats.activeExpirations.Add(uint64(len(keys)))
+	e.store.Remove(keys...)
+}